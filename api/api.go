@@ -0,0 +1,269 @@
+// Package api serves the Alertmanager API v2 of a GrafanaAlertmanager, so that amtool and the upstream Alertmanager UI
+// can talk to it, along with the Grafana extensions to test receivers and templates.
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	amv2 "github.com/prometheus/alertmanager/api/v2/models"
+
+	"github.com/grafana/alerting/notify"
+)
+
+// Prefix is the path prefix of the routes of the Alertmanager API v2.
+const Prefix = "/api/v2"
+
+// API is an http.Handler that serves the routes of the Alertmanager API v2 under Prefix:
+//
+//	GET    /api/v2/status
+//	GET    /api/v2/receivers
+//	GET    /api/v2/alerts
+//	POST   /api/v2/alerts
+//	GET    /api/v2/alerts/groups
+//	GET    /api/v2/silences
+//	POST   /api/v2/silences
+//	GET    /api/v2/silence/{silenceID}
+//	DELETE /api/v2/silence/{silenceID}
+//
+// and the Grafana extensions:
+//
+//	POST   /api/v2/receivers/test
+//	POST   /api/v2/templates/test
+type API struct {
+	am     *notify.GrafanaAlertmanager
+	logger log.Logger
+	mux    *http.ServeMux
+}
+
+// New creates the API of the Alertmanager.
+func New(am *notify.GrafanaAlertmanager, logger log.Logger) *API {
+	api := &API{
+		am:     am,
+		logger: log.With(logger, "component", "api"),
+		mux:    http.NewServeMux(),
+	}
+
+	api.mux.HandleFunc("GET "+Prefix+"/status", api.getStatus)
+	api.mux.HandleFunc("GET "+Prefix+"/receivers", api.getReceivers)
+	api.mux.HandleFunc("GET "+Prefix+"/alerts", api.getAlerts)
+	api.mux.HandleFunc("POST "+Prefix+"/alerts", api.postAlerts)
+	api.mux.HandleFunc("GET "+Prefix+"/alerts/groups", api.getAlertGroups)
+	api.mux.HandleFunc("GET "+Prefix+"/silences", api.getSilences)
+	api.mux.HandleFunc("POST "+Prefix+"/silences", api.postSilences)
+	api.mux.HandleFunc("GET "+Prefix+"/silence/{silenceID}", api.getSilence)
+	api.mux.HandleFunc("DELETE "+Prefix+"/silence/{silenceID}", api.deleteSilence)
+
+	api.mux.HandleFunc("POST "+Prefix+"/receivers/test", api.testReceivers)
+	api.mux.HandleFunc("POST "+Prefix+"/templates/test", api.testTemplates)
+
+	return api
+}
+
+func (api *API) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	api.mux.ServeHTTP(w, r)
+}
+
+func (api *API) getStatus(w http.ResponseWriter, _ *http.Request) {
+	api.respond(w, http.StatusOK, api.am.GetStatus().APIStatus())
+}
+
+func (api *API) getReceivers(w http.ResponseWriter, _ *http.Request) {
+	receivers := api.am.GetReceivers()
+	res := make([]*amv2.Receiver, 0, len(receivers))
+	for _, r := range receivers {
+		name := r.Name
+		res = append(res, &amv2.Receiver{Name: &name})
+	}
+	api.respond(w, http.StatusOK, res)
+}
+
+func (api *API) getAlerts(w http.ResponseWriter, r *http.Request) {
+	active, silenced, inhibited, err := parseAlertFlags(r)
+	if err != nil {
+		api.respondError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	alerts, err := api.am.GetAlerts(active, silenced, inhibited, r.URL.Query()["filter"], r.URL.Query().Get("receiver"))
+	if err != nil {
+		api.respondError(w, statusCode(err), err)
+		return
+	}
+	api.respond(w, http.StatusOK, alerts)
+}
+
+func (api *API) postAlerts(w http.ResponseWriter, r *http.Request) {
+	var alerts amv2.PostableAlerts
+	if err := json.NewDecoder(r.Body).Decode(&alerts); err != nil {
+		api.respondError(w, http.StatusBadRequest, fmt.Errorf("failed to decode alerts: %w", err))
+		return
+	}
+
+	if err := api.am.PutAlerts(alerts); err != nil {
+		api.respondError(w, statusCode(err), err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (api *API) getAlertGroups(w http.ResponseWriter, r *http.Request) {
+	// The alert groups are only known once a configuration is applied.
+	if !api.am.Ready() {
+		api.respondError(w, http.StatusServiceUnavailable, notify.ErrGetAlertsUnavailable)
+		return
+	}
+
+	active, silenced, inhibited, err := parseAlertFlags(r)
+	if err != nil {
+		api.respondError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	groups, err := api.am.GetAlertGroups(active, silenced, inhibited, r.URL.Query()["filter"], r.URL.Query().Get("receiver"))
+	if err != nil {
+		api.respondError(w, statusCode(err), err)
+		return
+	}
+	api.respond(w, http.StatusOK, groups)
+}
+
+func (api *API) getSilences(w http.ResponseWriter, r *http.Request) {
+	silences, err := api.am.ListSilences(r.URL.Query()["filter"])
+	if err != nil {
+		api.respondError(w, statusCode(err), err)
+		return
+	}
+	api.respond(w, http.StatusOK, silences)
+}
+
+func (api *API) postSilences(w http.ResponseWriter, r *http.Request) {
+	var silence notify.PostableSilence
+	if err := json.NewDecoder(r.Body).Decode(&silence); err != nil {
+		api.respondError(w, http.StatusBadRequest, fmt.Errorf("failed to decode silence: %w", err))
+		return
+	}
+
+	// Silences with an ID update the existing silence, as in the upstream Alertmanager.
+	silenceID, err := api.am.CreateSilence(&silence)
+	if err != nil {
+		api.respondError(w, statusCode(err), err)
+		return
+	}
+	api.respond(w, http.StatusOK, struct {
+		SilenceID string `json:"silenceID"`
+	}{SilenceID: silenceID})
+}
+
+func (api *API) getSilence(w http.ResponseWriter, r *http.Request) {
+	silence, err := api.am.GetSilence(r.PathValue("silenceID"))
+	if err != nil {
+		api.respondError(w, statusCode(err), err)
+		return
+	}
+	api.respond(w, http.StatusOK, silence)
+}
+
+func (api *API) deleteSilence(w http.ResponseWriter, r *http.Request) {
+	if err := api.am.DeleteSilence(r.PathValue("silenceID")); err != nil {
+		api.respondError(w, statusCode(err), err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (api *API) testReceivers(w http.ResponseWriter, r *http.Request) {
+	var c notify.TestReceiversConfigBodyParams
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		api.respondError(w, http.StatusBadRequest, fmt.Errorf("failed to decode receivers: %w", err))
+		return
+	}
+
+	res, status, err := api.am.TestReceivers(r.Context(), c)
+	if err != nil {
+		api.respondError(w, statusCode(err), err)
+		return
+	}
+	api.respond(w, status, res)
+}
+
+func (api *API) testTemplates(w http.ResponseWriter, r *http.Request) {
+	var c notify.TestTemplatesConfigBodyParams
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		api.respondError(w, http.StatusBadRequest, fmt.Errorf("failed to decode template: %w", err))
+		return
+	}
+
+	res, err := api.am.TestTemplate(r.Context(), c)
+	if err != nil {
+		api.respondError(w, statusCode(err), err)
+		return
+	}
+	api.respond(w, http.StatusOK, res)
+}
+
+// parseAlertFlags parses the active, silenced and inhibited query parameters of the alerts and alert groups routes.
+// They default to true.
+func parseAlertFlags(r *http.Request) (active, silenced, inhibited bool, err error) {
+	parse := func(name string) (bool, error) {
+		v := r.URL.Query().Get(name)
+		if v == "" {
+			return true, nil
+		}
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return false, fmt.Errorf("invalid value %q for query parameter %s", v, name)
+		}
+		return b, nil
+	}
+
+	if active, err = parse("active"); err != nil {
+		return
+	}
+	if silenced, err = parse("silenced"); err != nil {
+		return
+	}
+	inhibited, err = parse("inhibited")
+	return
+}
+
+// statusCode returns the HTTP status code of an error of the Alertmanager.
+func statusCode(err error) int {
+	var validationErr *notify.AlertValidationError
+	switch {
+	case errors.As(err, &validationErr),
+		errors.Is(err, notify.ErrGetAlertsBadPayload),
+		errors.Is(err, notify.ErrGetAlertGroupsBadPayload),
+		errors.Is(err, notify.ErrListSilencesBadPayload),
+		errors.Is(err, notify.ErrCreateSilenceBadPayload),
+		errors.Is(err, notify.ErrNoReceivers):
+		return http.StatusBadRequest
+	case errors.Is(err, notify.ErrSilenceNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, notify.ErrGetAlertsUnavailable):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func (api *API) respond(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		level.Error(api.logger).Log("msg", "failed to write response", "err", err)
+	}
+}
+
+// respondError writes the error as a JSON string, as the upstream Alertmanager does.
+func (api *API) respondError(w http.ResponseWriter, status int, err error) {
+	if status >= http.StatusInternalServerError {
+		level.Error(api.logger).Log("msg", "request failed", "err", err)
+	}
+	api.respond(w, status, err.Error())
+}
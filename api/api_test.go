@@ -0,0 +1,223 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	httptransport "github.com/go-openapi/runtime/client"
+	"github.com/go-openapi/strfmt"
+	"github.com/prometheus/alertmanager/api/v2/client"
+	"github.com/prometheus/alertmanager/api/v2/client/alert"
+	"github.com/prometheus/alertmanager/api/v2/client/alertgroup"
+	"github.com/prometheus/alertmanager/api/v2/client/general"
+	"github.com/prometheus/alertmanager/api/v2/client/receiver"
+	"github.com/prometheus/alertmanager/api/v2/client/silence"
+	amv2 "github.com/prometheus/alertmanager/api/v2/models"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/alerting/notify"
+	"github.com/grafana/alerting/templates"
+)
+
+type fakeMaintenanceOptions struct{}
+
+func (f *fakeMaintenanceOptions) InitialState() string                        { return "" }
+func (f *fakeMaintenanceOptions) Retention() time.Duration                    { return time.Hour }
+func (f *fakeMaintenanceOptions) MaintenanceFrequency() time.Duration         { return time.Minute }
+func (f *fakeMaintenanceOptions) MaintenanceFunc(notify.State) (int64, error) { return 0, nil }
+
+type testConfiguration struct {
+	receivers []*notify.APIReceiver
+	route     *notify.Route
+}
+
+func (c *testConfiguration) DispatcherLimits() notify.DispatcherLimits    { return nilLimits{} }
+func (c *testConfiguration) InhibitRules() []notify.InhibitRule           { return nil }
+func (c *testConfiguration) TimeIntervals() []notify.TimeInterval         { return nil }
+func (c *testConfiguration) MuteTimeIntervals() []notify.MuteTimeInterval { return nil }
+func (c *testConfiguration) Receivers() []*notify.APIReceiver             { return c.receivers }
+func (c *testConfiguration) RoutingTree() *notify.Route                   { return c.route }
+func (c *testConfiguration) Templates() []templates.TemplateDefinition    { return nil }
+func (c *testConfiguration) Hash() [16]byte                               { return [16]byte{} }
+func (c *testConfiguration) Raw() []byte                                  { return []byte("{}") }
+func (c *testConfiguration) BuildReceiverIntegrationsFunc() func(*notify.APIReceiver, *templates.Template) ([]*notify.Integration, error) {
+	return func(*notify.APIReceiver, *templates.Template) ([]*notify.Integration, error) { return nil, nil }
+}
+
+type nilLimits struct{}
+
+func (n nilLimits) MaxNumberOfAggregationGroups() int { return 0 }
+
+func setupAPITest(t *testing.T) (*notify.GrafanaAlertmanager, *httptest.Server) {
+	t.Helper()
+
+	am, err := notify.NewGrafanaAlertmanager("org", 1, &notify.GrafanaAlertmanagerConfig{
+		Silences: &fakeMaintenanceOptions{},
+		Nflog:    &fakeMaintenanceOptions{},
+	}, &notify.NilPeer{}, log.NewNopLogger(), notify.NewGrafanaAlertmanagerMetrics(prometheus.NewPedanticRegistry(), log.NewNopLogger()))
+	require.NoError(t, err)
+	t.Cleanup(am.StopAndWait)
+
+	server := httptest.NewServer(New(am, log.NewNopLogger()))
+	t.Cleanup(server.Close)
+	return am, server
+}
+
+// newClient returns the client of the upstream Alertmanager API v2, which is the client that amtool uses.
+func newClient(t *testing.T, server *httptest.Server) *client.AlertmanagerAPI {
+	t.Helper()
+
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	return client.New(httptransport.New(u.Host, client.DefaultBasePath, []string{u.Scheme}), strfmt.Default)
+}
+
+func TestAPI(t *testing.T) {
+	am, server := setupAPITest(t)
+	c := newClient(t, server)
+
+	// The Alertmanager is not ready until a configuration is applied.
+	_, err := c.Alert.GetAlerts(alert.NewGetAlertsParams())
+	require.Error(t, err)
+	resp, err := http.Get(server.URL + Prefix + "/alerts/groups")
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+
+	require.NoError(t, am.ApplyConfig(&testConfiguration{
+		receivers: []*notify.APIReceiver{{ConfigReceiver: notify.ConfigReceiver{Name: "default"}}},
+		route:     &notify.Route{Receiver: "default", GroupBy: []model.LabelName{"alertname"}},
+	}))
+
+	status, err := c.General.GetStatus(general.NewGetStatusParams())
+	require.NoError(t, err)
+	require.Equal(t, "{}", *status.Payload.Config.Original)
+	require.Equal(t, amv2.ClusterStatusStatusDisabled, *status.Payload.Cluster.Status)
+
+	receivers, err := c.Receiver.GetReceivers(receiver.NewGetReceiversParams())
+	require.NoError(t, err)
+	require.Len(t, receivers.Payload, 1)
+	require.Equal(t, "default", *receivers.Payload[0].Name)
+
+	now := time.Now()
+	_, err = c.Alert.PostAlerts(alert.NewPostAlertsParams().WithAlerts(amv2.PostableAlerts{
+		{Alert: amv2.Alert{Labels: amv2.LabelSet{"alertname": "a"}}, StartsAt: strfmt.DateTime(now), EndsAt: strfmt.DateTime(now.Add(time.Hour))},
+		{Alert: amv2.Alert{Labels: amv2.LabelSet{"alertname": "b"}}, StartsAt: strfmt.DateTime(now), EndsAt: strfmt.DateTime(now.Add(time.Hour))},
+	}))
+	require.NoError(t, err)
+
+	// Invalid alerts are rejected.
+	_, err = c.Alert.PostAlerts(alert.NewPostAlertsParams().WithAlerts(amv2.PostableAlerts{{Alert: amv2.Alert{Labels: amv2.LabelSet{}}}}))
+	var postAlertsBadRequest *alert.PostAlertsBadRequest
+	require.ErrorAs(t, err, &postAlertsBadRequest)
+
+	alerts, err := c.Alert.GetAlerts(alert.NewGetAlertsParams().WithFilter([]string{`alertname="a"`}))
+	require.NoError(t, err)
+	require.Len(t, alerts.Payload, 1)
+	require.Equal(t, "a", alerts.Payload[0].Labels["alertname"])
+
+	_, err = c.Alert.GetAlerts(alert.NewGetAlertsParams().WithFilter([]string{"{"}))
+	var getAlertsBadRequest *alert.GetAlertsBadRequest
+	require.ErrorAs(t, err, &getAlertsBadRequest)
+
+	require.Eventually(t, func() bool {
+		groups, err := c.Alertgroup.GetAlertGroups(alertgroup.NewGetAlertGroupsParams())
+		return err == nil && len(groups.Payload) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	created, err := c.Silence.PostSilences(silence.NewPostSilencesParams().WithSilence(&amv2.PostableSilence{Silence: amv2.Silence{
+		Matchers:  amv2.Matchers{{Name: ptr("alertname"), Value: ptr("a"), IsEqual: ptr(true), IsRegex: ptr(false)}},
+		StartsAt:  ptr(strfmt.DateTime(now)),
+		EndsAt:    ptr(strfmt.DateTime(now.Add(time.Hour))),
+		CreatedBy: ptr("test"),
+		Comment:   ptr("test"),
+	}}))
+	require.NoError(t, err)
+	silenceID := created.Payload.SilenceID
+	require.NotEmpty(t, silenceID)
+
+	sil, err := c.Silence.GetSilence(silence.NewGetSilenceParams().WithSilenceID(strfmt.UUID(silenceID)))
+	require.NoError(t, err)
+	require.Equal(t, amv2.SilenceStatusStateActive, *sil.Payload.Status.State)
+
+	silences, err := c.Silence.GetSilences(silence.NewGetSilencesParams())
+	require.NoError(t, err)
+	require.Len(t, silences.Payload, 1)
+
+	alerts, err = c.Alert.GetAlerts(alert.NewGetAlertsParams().WithSilenced(ptr(false)))
+	require.NoError(t, err)
+	require.Len(t, alerts.Payload, 1)
+	require.Equal(t, "b", alerts.Payload[0].Labels["alertname"])
+
+	_, err = c.Silence.DeleteSilence(silence.NewDeleteSilenceParams().WithSilenceID(strfmt.UUID(silenceID)))
+	require.NoError(t, err)
+	sil, err = c.Silence.GetSilence(silence.NewGetSilenceParams().WithSilenceID(strfmt.UUID(silenceID)))
+	require.NoError(t, err)
+	require.Equal(t, amv2.SilenceStatusStateExpired, *sil.Payload.Status.State)
+
+	_, err = c.Silence.GetSilence(silence.NewGetSilenceParams().WithSilenceID("8a3b9c5e-0000-4000-8000-000000000000"))
+	var getSilenceNotFound *silence.GetSilenceNotFound
+	require.ErrorAs(t, err, &getSilenceNotFound)
+
+	// Silences with the ID of a silence that does not exist are not created.
+	_, err = c.Silence.PostSilences(silence.NewPostSilencesParams().WithSilence(&amv2.PostableSilence{ID: "8a3b9c5e-0000-4000-8000-000000000000", Silence: amv2.Silence{
+		Matchers:  amv2.Matchers{{Name: ptr("alertname"), Value: ptr("a"), IsEqual: ptr(true), IsRegex: ptr(false)}},
+		StartsAt:  ptr(strfmt.DateTime(now)),
+		EndsAt:    ptr(strfmt.DateTime(now.Add(time.Hour))),
+		CreatedBy: ptr("test"),
+		Comment:   ptr("test"),
+	}}))
+	var postSilencesNotFound *silence.PostSilencesNotFound
+	require.ErrorAs(t, err, &postSilencesNotFound)
+}
+
+func TestAPI_TestTemplates(t *testing.T) {
+	am, server := setupAPITest(t)
+	require.NoError(t, am.ApplyConfig(&testConfiguration{
+		receivers: []*notify.APIReceiver{{ConfigReceiver: notify.ConfigReceiver{Name: "default"}}},
+		route:     &notify.Route{Receiver: "default"},
+	}))
+
+	body, err := json.Marshal(map[string]any{
+		"name":     "test",
+		"template": `{{ define "test" }}{{ len .Alerts }} alerts{{ end }}`,
+		"alerts":   []map[string]any{{"labels": map[string]string{"alertname": "a"}}},
+	})
+	require.NoError(t, err)
+	resp, err := http.Post(server.URL+Prefix+"/templates/test", "application/json", bytes.NewReader(body))
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, resp.Body.Close()) })
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var res notify.TestTemplatesResults
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&res))
+	require.Equal(t, []notify.TestTemplatesResult{{Name: "test", Text: "1 alerts"}}, res.Results)
+	require.Empty(t, res.Errors)
+}
+
+func TestAPI_TestReceivers(t *testing.T) {
+	_, server := setupAPITest(t)
+
+	// There are no receivers to test.
+	resp, err := http.Post(server.URL+Prefix+"/receivers/test", "application/json", bytes.NewReader([]byte(`{"receivers": []}`)))
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp, err = http.Post(server.URL+Prefix+"/receivers/test", "application/json", bytes.NewReader([]byte(`{`)))
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
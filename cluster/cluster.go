@@ -19,5 +19,6 @@ var (
 )
 
 type ClusterChannel = cluster.ClusterChannel //nolint:revive
+type ClusterMember = cluster.ClusterMember   //nolint:revive
 type Peer = cluster.Peer
 type State = cluster.State
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/go-kit/log"
+
+	"github.com/grafana/alerting/definition"
+	"github.com/grafana/alerting/images"
+	"github.com/grafana/alerting/notify"
+	"github.com/grafana/alerting/receivers"
+	"github.com/grafana/alerting/templates"
+)
+
+// orgID is the ID of the only tenant of the daemon.
+const orgID = 1
+
+// integrationsFactory builds the integrations of the Grafana receivers.
+type integrationsFactory struct {
+	logger           log.Logger
+	newWebhookSender func(n receivers.Metadata) (receivers.WebhookSender, error)
+	newEmailSender   func(n receivers.Metadata) (receivers.EmailSender, error)
+	version          string
+}
+
+func (f *integrationsFactory) build(r *notify.APIReceiver, tmpl *templates.Template) ([]*notify.Integration, error) {
+	cfg, err := notify.BuildReceiverConfiguration(context.Background(), r, notify.NoopDecrypt)
+	if err != nil {
+		return nil, err
+	}
+	return notify.BuildReceiverIntegrations(cfg, tmpl, &images.UnavailableProvider{}, newLoggerFactory(f.logger), f.newWebhookSender, f.newEmailSender, orgID, f.version)
+}
+
+// loadConfiguration loads the configuration file and the templates it references. Template paths are globs relative
+// to the directory of the configuration file, as in the upstream Alertmanager.
+func loadConfiguration(path string, integrations *integrationsFactory) (*notify.PostableConfiguration, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := definition.LoadWithMixedReceiverTypes(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse configuration file %s: %w", path, err)
+	}
+	if err := cfg.SetUpstreamReceiverDefaults(); err != nil {
+		return nil, fmt.Errorf("invalid configuration file %s: %w", path, err)
+	}
+
+	var tmpls []templates.TemplateDefinition
+	for _, pattern := range cfg.Templates {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(path), pattern)
+		}
+		files, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid template path %s: %w", pattern, err)
+		}
+		sort.Strings(files)
+		for _, f := range files {
+			b, err := os.ReadFile(f)
+			if err != nil {
+				return nil, err
+			}
+			tmpls = append(tmpls, templates.TemplateDefinition{Name: filepath.Base(f), Template: string(b)})
+		}
+	}
+
+	return notify.NewPostableConfiguration(cfg, raw, tmpls, nil, integrations.build), nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-openapi/strfmt"
+	amv2 "github.com/prometheus/alertmanager/api/v2/models"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/alerting/notify"
+	"github.com/grafana/alerting/receivers"
+)
+
+const testConfig = `
+templates:
+  - templates/*.tmpl
+route:
+  receiver: grafana
+  group_by: [alertname]
+  group_wait: 10ms
+  routes:
+    - receiver: upstream
+      matchers: ['team="upstream"']
+      group_wait: 10ms
+receivers:
+  - name: grafana
+    grafana_managed_receiver_configs:
+      - uid: webhook
+        name: grafana
+        type: webhook
+        settings:
+          url: %s/grafana
+  - name: upstream
+    webhook_configs:
+      - url: %s/upstream
+`
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o750))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+}
+
+func TestLoadConfiguration(t *testing.T) {
+	var (
+		mtx      sync.Mutex
+		requests = map[string]int{}
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mtx.Lock()
+		requests[r.URL.Path]++
+		mtx.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "alerting.yml")
+	writeFile(t, path, fmt.Sprintf(testConfig, server.URL, server.URL))
+	writeFile(t, filepath.Join(dir, "templates", "a.tmpl"), `{{ define "a" }}a{{ end }}`)
+
+	logger := log.NewNopLogger()
+	integrations := &integrationsFactory{
+		logger:           logger,
+		newWebhookSender: newWebhookSenderFactory("test", logger),
+		newEmailSender:   receivers.NewEmailSenderFactory(receivers.EmailSenderConfig{}),
+	}
+	cfg, err := loadConfiguration(path, integrations)
+	require.NoError(t, err)
+	require.Len(t, cfg.Receivers(), 2)
+	require.Len(t, cfg.Templates(), 1)
+	require.Equal(t, "a.tmpl", cfg.Templates()[0].Name)
+
+	// Changes to templates change the hash of the configuration.
+	writeFile(t, filepath.Join(dir, "templates", "a.tmpl"), `{{ define "a" }}b{{ end }}`)
+	changed, err := loadConfiguration(path, integrations)
+	require.NoError(t, err)
+	require.NotEqual(t, cfg.Hash(), changed.Hash())
+
+	silences, err := notify.NewFileMaintenanceOptions(notify.FileMaintenanceConfig{Path: filepath.Join(dir, "silences")}, nil, logger)
+	require.NoError(t, err)
+	nflog, err := notify.NewFileMaintenanceOptions(notify.FileMaintenanceConfig{Path: filepath.Join(dir, "nflog")}, nil, logger)
+	require.NoError(t, err)
+	escalations, err := notify.NewFileMaintenanceOptions(notify.FileMaintenanceConfig{Path: filepath.Join(dir, "escalations")}, nil, logger)
+	require.NoError(t, err)
+	acknowledgements, err := notify.NewFileMaintenanceOptions(notify.FileMaintenanceConfig{Path: filepath.Join(dir, "acknowledgements")}, nil, logger)
+	require.NoError(t, err)
+	am, err := notify.NewGrafanaAlertmanager("org", orgID, &notify.GrafanaAlertmanagerConfig{
+		Silences:             silences,
+		Nflog:                nflog,
+		Escalations:          escalations,
+		Acknowledgements:     acknowledgements,
+		UpstreamIntegrations: &notify.UpstreamIntegrationsConfig{},
+	}, &notify.NilPeer{}, logger, notify.NewGrafanaAlertmanagerMetrics(prometheus.NewRegistry(), logger))
+	require.NoError(t, err)
+	t.Cleanup(am.StopAndWait)
+	require.NoError(t, am.ApplyConfig(changed))
+
+	now := time.Now()
+	require.NoError(t, am.PutAlerts(amv2.PostableAlerts{
+		{Alert: amv2.Alert{Labels: amv2.LabelSet{"alertname": "a"}}, StartsAt: strfmt.DateTime(now), EndsAt: strfmt.DateTime(now.Add(time.Hour))},
+		{Alert: amv2.Alert{Labels: amv2.LabelSet{"alertname": "b", "team": "upstream"}}, StartsAt: strfmt.DateTime(now), EndsAt: strfmt.DateTime(now.Add(time.Hour))},
+	}))
+
+	// Both the Grafana and the upstream webhook integrations are notified.
+	require.Eventually(t, func() bool {
+		mtx.Lock()
+		defer mtx.Unlock()
+		return requests["/grafana"] == 1 && requests["/upstream"] == 1
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestLoadConfiguration_Invalid(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "alerting.yml")
+
+	_, err := loadConfiguration(path, &integrationsFactory{})
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	writeFile(t, path, "route:\n  receiver: missing\n")
+	_, err = loadConfiguration(path, &integrationsFactory{})
+	require.Error(t, err)
+}
@@ -0,0 +1,228 @@
+// Command alerting runs the Grafana notification engine as a standalone daemon, outside of Grafana.
+//
+// It loads a Grafana Alertmanager configuration file, which can have both Grafana and upstream Alertmanager receivers,
+// persists silences, the notification log, escalations and acknowledgements in a storage directory, and serves the
+// Alertmanager API v2, so that alerts can be sent to it and silences managed with amtool. The configuration is
+// reloaded on SIGHUP and on POST /-/reload.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/common/version"
+
+	"github.com/grafana/alerting/api"
+	"github.com/grafana/alerting/notify"
+	"github.com/grafana/alerting/receivers"
+)
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	var (
+		configFile          = flag.String("config.file", "alerting.yml", "Alertmanager configuration file, in the Grafana format.")
+		storagePath         = flag.String("storage.path", "data/", "Directory of the silences, notification log, escalation and acknowledgement snapshots.")
+		retention           = flag.Duration("data.retention", 120*time.Hour, "How long to keep expired silences and notification log entries.")
+		maintenanceInterval = flag.Duration("data.maintenance-interval", 15*time.Minute, "How often to write the snapshots of silences and the notification log.")
+		compress            = flag.Bool("data.compress", false, "Compress the snapshots with gzip.")
+		generations         = flag.Int("data.generations", 0, "Number of previous snapshots to keep for rollback.")
+		listenAddress       = flag.String("web.listen-address", ":9093", "Address to listen on for the API.")
+		externalURL         = flag.String("web.external-url", "", "URL under which the daemon is reachable, used in notifications.")
+		logLevel            = flag.String("log.level", "info", "Log level: debug, info, warn or error.")
+
+		smtpHost           = flag.String("smtp.host", "localhost:25", "SMTP server of the email integrations, as host:port.")
+		smtpUser           = flag.String("smtp.user", "", "SMTP user of the email integrations.")
+		smtpFromAddress    = flag.String("smtp.from-address", "alerting@localhost", "Sender address of the email integrations.")
+		smtpFromName       = flag.String("smtp.from-name", "Grafana Alerting", "Sender name of the email integrations.")
+		smtpSkipVerify     = flag.Bool("smtp.skip-verify", false, "Skip the verification of the certificate of the SMTP server.")
+		smtpStartTLSPolicy = flag.String("smtp.starttls-policy", "", "STARTTLS policy: OpportunisticStartTLS, MandatoryStartTLS or NoStartTLS.")
+	)
+	flag.Parse()
+
+	logger, err := newLogger(*logLevel)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(*storagePath, 0o750); err != nil {
+		return fmt.Errorf("failed to create the storage directory: %w", err)
+	}
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	integrations := &integrationsFactory{
+		logger:           logger,
+		newWebhookSender: newWebhookSenderFactory("Grafana-Alerting/"+version.Version, logger),
+		newEmailSender: receivers.NewEmailSenderFactory(receivers.EmailSenderConfig{
+			AuthPassword:   os.Getenv("SMTP_PASSWORD"),
+			AuthUser:       *smtpUser,
+			ContentTypes:   []string{"text/html"},
+			ExternalURL:    *externalURL,
+			FromAddress:    *smtpFromAddress,
+			FromName:       *smtpFromName,
+			Host:           *smtpHost,
+			SkipVerify:     *smtpSkipVerify,
+			StartTLSPolicy: *smtpStartTLSPolicy,
+			Version:        version.Version,
+		}),
+		version: version.Version,
+	}
+
+	fileMetrics := notify.NewFileMaintenanceMetrics(reg)
+	newFileMaintenanceOptions := func(name string) (*notify.FileMaintenanceOptions, error) {
+		return notify.NewFileMaintenanceOptions(notify.FileMaintenanceConfig{
+			Path:                 filepath.Join(*storagePath, name),
+			Retention:            *retention,
+			MaintenanceFrequency: *maintenanceInterval,
+			Compress:             *compress,
+			Generations:          *generations,
+		}, fileMetrics, logger)
+	}
+	silences, err := newFileMaintenanceOptions("silences")
+	if err != nil {
+		return err
+	}
+	nflog, err := newFileMaintenanceOptions("nflog")
+	if err != nil {
+		return err
+	}
+	escalations, err := newFileMaintenanceOptions("escalations")
+	if err != nil {
+		return err
+	}
+	acknowledgements, err := newFileMaintenanceOptions("acknowledgements")
+	if err != nil {
+		return err
+	}
+
+	am, err := notify.NewGrafanaAlertmanager("org", orgID, &notify.GrafanaAlertmanagerConfig{
+		ExternalURL:          *externalURL,
+		Silences:             silences,
+		Nflog:                nflog,
+		Escalations:          escalations,
+		Acknowledgements:     acknowledgements,
+		UpstreamIntegrations: &notify.UpstreamIntegrationsConfig{},
+	}, &notify.NilPeer{}, logger, notify.NewGrafanaAlertmanagerMetrics(reg, logger))
+	if err != nil {
+		return err
+	}
+	// Stopping the Alertmanager writes the final snapshots.
+	defer am.StopAndWait()
+
+	applyConfig := func() error {
+		cfg, err := loadConfiguration(*configFile, integrations)
+		if err != nil {
+			return err
+		}
+		am.WithLock(func() {
+			err = am.ApplyConfig(cfg)
+		})
+		return err
+	}
+	if err := applyConfig(); err != nil {
+		return fmt.Errorf("failed to load the configuration: %w", err)
+	}
+	level.Info(logger).Log("msg", "Loaded the configuration", "file", *configFile)
+
+	// reload applies the configuration file again. A configuration that fails to load or apply is logged, and the
+	// running configuration is kept.
+	reload := func() error {
+		if err := applyConfig(); err != nil {
+			level.Error(logger).Log("msg", "Failed to reload the configuration", "err", err)
+			return err
+		}
+		level.Info(logger).Log("msg", "Reloaded the configuration", "file", *configFile)
+		return nil
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(api.Prefix+"/", api.New(am, logger))
+	mux.Handle("GET /metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
+	mux.HandleFunc("GET /-/healthy", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("GET /-/ready", func(w http.ResponseWriter, _ *http.Request) {
+		if !am.Ready() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("POST /-/reload", func(w http.ResponseWriter, _ *http.Request) {
+		if err := reload(); err != nil {
+			http.Error(w, fmt.Sprintf("failed to reload the configuration: %s", err), http.StatusInternalServerError)
+		}
+	})
+
+	srv := &http.Server{Addr: *listenAddress, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	srvc := make(chan error, 1)
+	go func() {
+		level.Info(logger).Log("msg", "Listening", "address", *listenAddress)
+		srvc <- srv.ListenAndServe()
+	}()
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	term := make(chan os.Signal, 1)
+	signal.Notify(term, os.Interrupt, syscall.SIGTERM)
+
+	for {
+		select {
+		case <-hup:
+			_ = reload()
+		case <-term:
+			level.Info(logger).Log("msg", "Shutting down")
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			return srv.Shutdown(ctx)
+		case err := <-srvc:
+			if errors.Is(err, http.ErrServerClosed) {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+func newLogger(lvl string) (log.Logger, error) {
+	var opt level.Option
+	switch lvl {
+	case "debug":
+		opt = level.AllowDebug()
+	case "info":
+		opt = level.AllowInfo()
+	case "warn":
+		opt = level.AllowWarn()
+	case "error":
+		opt = level.AllowError()
+	default:
+		return nil, fmt.Errorf("unknown log level %q", lvl)
+	}
+
+	logger := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+	logger = level.NewFilter(logger, opt)
+	return log.With(logger, "ts", log.DefaultTimestampUTC, "caller", log.DefaultCaller), nil
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+
+	"github.com/grafana/alerting/logging"
+	"github.com/grafana/alerting/receivers"
+)
+
+// webhookTimeout is the timeout of the requests of the webhook sender.
+const webhookTimeout = 30 * time.Second
+
+// webhookSender sends the webhooks of the Grafana integrations with the default HTTP transport.
+type webhookSender struct {
+	userAgent string
+	logger    log.Logger
+}
+
+func newWebhookSenderFactory(userAgent string, logger log.Logger) func(receivers.Metadata) (receivers.WebhookSender, error) {
+	return func(n receivers.Metadata) (receivers.WebhookSender, error) {
+		return &webhookSender{
+			userAgent: userAgent,
+			logger:    log.With(logger, "notifier", n.Type, "uid", n.UID),
+		}, nil
+	}
+}
+
+func (s *webhookSender) SendWebhook(ctx context.Context, cmd *receivers.SendWebhookSettings) error {
+	method := cmd.HTTPMethod
+	if method == "" {
+		method = http.MethodPost
+	}
+	contentType := cmd.ContentType
+	if contentType == "" {
+		contentType = "application/json"
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, cmd.URL, bytes.NewReader([]byte(cmd.Body)))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("User-Agent", s.userAgent)
+	if cmd.User != "" && cmd.Password != "" {
+		req.SetBasicAuth(cmd.User, cmd.Password)
+	}
+	for k, v := range cmd.HTTPHeader {
+		req.Header.Set(k, v)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cmd.TLSConfig != nil {
+		transport.TLSClientConfig = cmd.TLSConfig
+	}
+	client := &http.Client{Timeout: webhookTimeout, Transport: transport}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			level.Warn(s.logger).Log("msg", "failed to close response body", "err", err)
+		}
+	}()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if cmd.Validation != nil {
+		return cmd.Validation(body, resp.StatusCode)
+	}
+	if resp.StatusCode/100 != 2 {
+		level.Debug(s.logger).Log("msg", "webhook failed", "url", cmd.URL, "status", resp.Status, "body", string(body))
+		return fmt.Errorf("webhook response status %v", resp.Status)
+	}
+	return nil
+}
+
+// gokitLogger is a logging.Logger that logs with a go-kit logger.
+type gokitLogger struct {
+	logger log.Logger
+}
+
+// newLoggerFactory returns the factory of the loggers of the Grafana integrations.
+func newLoggerFactory(logger log.Logger) logging.LoggerFactory {
+	return func(loggerName string, ctx ...interface{}) logging.Logger {
+		return gokitLogger{logger: log.With(log.With(logger, "logger", loggerName), ctx...)}
+	}
+}
+
+func (l gokitLogger) New(ctx ...interface{}) logging.Logger {
+	return gokitLogger{logger: log.With(l.logger, ctx...)}
+}
+
+func (l gokitLogger) Log(keyvals ...interface{}) error {
+	return l.logger.Log(keyvals...)
+}
+
+func (l gokitLogger) Debug(msg string, ctx ...interface{}) {
+	level.Debug(l.logger).Log(append([]interface{}{"msg", msg}, ctx...)...)
+}
+
+func (l gokitLogger) Info(msg string, ctx ...interface{}) {
+	level.Info(l.logger).Log(append([]interface{}{"msg", msg}, ctx...)...)
+}
+
+func (l gokitLogger) Warn(msg string, ctx ...interface{}) {
+	level.Warn(l.logger).Log(append([]interface{}{"msg", msg}, ctx...)...)
+}
+
+func (l gokitLogger) Error(msg string, ctx ...interface{}) {
+	level.Error(l.logger).Log(append([]interface{}{"msg", msg}, ctx...)...)
+}
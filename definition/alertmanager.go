@@ -26,6 +26,22 @@ type Config struct {
 	MuteTimeIntervals []config.MuteTimeInterval `yaml:"mute_time_intervals,omitempty" json:"mute_time_intervals,omitempty"`
 	TimeIntervals     []config.TimeInterval     `yaml:"time_intervals,omitempty" json:"time_intervals,omitempty"`
 	Templates         []string                  `yaml:"templates,omitempty" json:"templates,omitempty"`
+	// EscalationPolicies are the escalation policies that routes can reference.
+	EscalationPolicies []EscalationPolicy `yaml:"escalation_policies,omitempty" json:"escalation_policies,omitempty"`
+}
+
+// EscalationPolicy notifies additional receivers, level after level, while an alert group keeps firing.
+type EscalationPolicy struct {
+	Name   string            `yaml:"name" json:"name"`
+	Levels []EscalationLevel `yaml:"levels" json:"levels"`
+}
+
+// EscalationLevel is a level of an escalation policy.
+type EscalationLevel struct {
+	// Receiver is the receiver notified once the level is reached.
+	Receiver string `yaml:"receiver" json:"receiver"`
+	// After is for how long the alert group must have been firing for the level to be reached.
+	After model.Duration `yaml:"after" json:"after"`
 }
 
 // A Route is a node that contains definitions of how to handle alerts. This is modified
@@ -51,6 +67,10 @@ type Route struct {
 	GroupInterval  *model.Duration `yaml:"group_interval,omitempty" json:"group_interval,omitempty"`
 	RepeatInterval *model.Duration `yaml:"repeat_interval,omitempty" json:"repeat_interval,omitempty"`
 
+	// EscalationPolicy is the name of the escalation policy of the alert groups of the route. It is inherited by the
+	// child routes that do not have their own.
+	EscalationPolicy string `yaml:"escalation_policy,omitempty" json:"escalation_policy,omitempty"`
+
 	Provenance Provenance `yaml:"provenance,omitempty" json:"provenance,omitempty"`
 }
 
@@ -215,6 +235,27 @@ func Load(rawCfg []byte) (*PostableApiAlertingConfig, error) {
 	return &cfg, nil
 }
 
+// LoadWithMixedReceiverTypes is like Load, but allows Grafana and Alertmanager receivers in the same configuration.
+// It is meant for Alertmanagers that build the integrations of both, see notify.GrafanaAlertmanagerConfig.
+func LoadWithMixedReceiverTypes(rawCfg []byte) (*PostableApiAlertingConfig, error) {
+	var cfg mixedPostableApiAlertingConfig
+	if err := yaml.Unmarshal(rawCfg, &cfg); err != nil {
+		return nil, err
+	}
+	return (*PostableApiAlertingConfig)(&cfg), nil
+}
+
+// mixedPostableApiAlertingConfig unmarshals a PostableApiAlertingConfig that can mix receiver types.
+type mixedPostableApiAlertingConfig PostableApiAlertingConfig
+
+func (c *mixedPostableApiAlertingConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	cfg := (*PostableApiAlertingConfig)(c)
+	if err := cfg.unmarshalYAML(unmarshal); err != nil {
+		return err
+	}
+	return cfg.validate()
+}
+
 func (c *PostableApiAlertingConfig) GetReceivers() []*PostableApiReceiver {
 	return c.Receivers
 }
@@ -234,6 +275,14 @@ func (c *PostableApiAlertingConfig) UnmarshalJSON(b []byte) error {
 }
 
 func (c *PostableApiAlertingConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if err := c.unmarshalYAML(unmarshal); err != nil {
+		return err
+	}
+	return c.Validate()
+}
+
+// unmarshalYAML unmarshals the configuration without validating it.
+func (c *PostableApiAlertingConfig) unmarshalYAML(unmarshal func(interface{}) error) error {
 	type plain PostableApiAlertingConfig
 	if err := unmarshal((*plain)(c)); err != nil {
 		return err
@@ -246,32 +295,22 @@ func (c *PostableApiAlertingConfig) UnmarshalYAML(unmarshal func(interface{}) er
 		Receivers *[]*PostableApiReceiver `yaml:"receivers" json:"receivers,omitempty"`
 	}
 
-	if err := unmarshal(&overrides{Receivers: &c.Receivers}); err != nil {
-		return err
-	}
-
-	return c.Validate()
+	return unmarshal(&overrides{Receivers: &c.Receivers})
 }
 
 // Validate ensures that the two routing trees use the correct receiver types.
 func (c *PostableApiAlertingConfig) Validate() error {
-	receivers := make(map[string]struct{}, len(c.Receivers))
+	if c.HasMixedReceiverTypes() {
+		return fmt.Errorf("cannot mix Alertmanager & Grafana receiver types")
+	}
+	return c.validate()
+}
 
-	var hasGrafReceivers, hasAMReceivers bool
+// validate ensures that the routing tree only uses receivers that exist, whatever their types.
+func (c *PostableApiAlertingConfig) validate() error {
+	receivers := make(map[string]struct{}, len(c.Receivers))
 	for _, r := range c.Receivers {
 		receivers[r.Name] = struct{}{}
-		switch r.Type() {
-		case GrafanaReceiverType:
-			hasGrafReceivers = true
-		case AlertmanagerReceiverType:
-			hasAMReceivers = true
-		default:
-			continue
-		}
-	}
-
-	if hasGrafReceivers && hasAMReceivers {
-		return fmt.Errorf("cannot mix Alertmanager & Grafana receiver types")
 	}
 
 	// Taken from https://github.com/prometheus/alertmanager/blob/14cbe6301c732658d6fe877ec55ad5b738abcf06/config/config.go#L171-L192
@@ -294,10 +333,115 @@ func (c *PostableApiAlertingConfig) Validate() error {
 		}
 	}
 
+	for _, r := range c.Receivers {
+		if r.FallbackReceiver == "" {
+			continue
+		}
+		if r.FallbackReceiver == r.Name {
+			return fmt.Errorf("receiver (%s) cannot be its own fallback receiver", r.Name)
+		}
+		if _, ok := receivers[r.FallbackReceiver]; !ok {
+			return fmt.Errorf("fallback receiver (%s) of receiver (%s) is undefined", r.FallbackReceiver, r.Name)
+		}
+	}
+
+	if err := ValidateEscalationPolicies(c.EscalationPolicies, c.Route, receivers); err != nil {
+		return err
+	}
+
+	for _, r := range c.Receivers {
+		if r.Digest == nil {
+			continue
+		}
+		if len(r.Digest.TimeIntervals) == 0 {
+			return fmt.Errorf("digest of receiver (%s) has no time intervals", r.Name)
+		}
+		for _, name := range r.Digest.TimeIntervals {
+			if !c.hasTimeInterval(name) {
+				return fmt.Errorf("undefined time interval %q used in digest of receiver (%s)", name, r.Name)
+			}
+		}
+	}
+
+	for _, r := range c.Receivers {
+		if r.RateLimit != nil {
+			if err := r.RateLimit.Validate(); err != nil {
+				return fmt.Errorf("invalid rate limit of receiver (%s): %w", r.Name, err)
+			}
+		}
+		for _, gr := range r.GrafanaManagedReceivers {
+			if gr.RateLimit == nil {
+				continue
+			}
+			if err := gr.RateLimit.Validate(); err != nil {
+				return fmt.Errorf("invalid rate limit of integration (%s) of receiver (%s): %w", gr.Name, r.Name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// ValidateEscalationPolicies checks that the escalation policies are valid, that their receivers are defined, and
+// that the routes of the routing tree only use escalation policies that are defined. The route is optional.
+func ValidateEscalationPolicies(escalationPolicies []EscalationPolicy, route *Route, receivers map[string]struct{}) error {
+	policies := make(map[string]struct{}, len(escalationPolicies))
+	for _, p := range escalationPolicies {
+		if p.Name == "" {
+			return fmt.Errorf("missing name in escalation policy")
+		}
+		if _, ok := policies[p.Name]; ok {
+			return fmt.Errorf("escalation policy %q is not unique", p.Name)
+		}
+		policies[p.Name] = struct{}{}
+		if len(p.Levels) == 0 {
+			return fmt.Errorf("escalation policy %q has no levels", p.Name)
+		}
+		for _, l := range p.Levels {
+			if _, ok := receivers[l.Receiver]; !ok {
+				return fmt.Errorf("receiver (%s) of escalation policy %q is undefined", l.Receiver, p.Name)
+			}
+			if l.After <= 0 {
+				return fmt.Errorf("levels of escalation policy %q must have a positive duration", p.Name)
+			}
+		}
+	}
+	if route == nil {
+		return nil
+	}
+	return checkEscalationPolicy(route, policies)
+}
+
+func checkEscalationPolicy(r *Route, policies map[string]struct{}) error {
+	if r.EscalationPolicy != "" {
+		if _, ok := policies[r.EscalationPolicy]; !ok {
+			return fmt.Errorf("undefined escalation policy %q used in route", r.EscalationPolicy)
+		}
+	}
+	for _, sr := range r.Routes {
+		if err := checkEscalationPolicy(sr, policies); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-// Type requires validate has been called and just checks the first receiver type
+func (c *PostableApiAlertingConfig) hasTimeInterval(name string) bool {
+	for _, ti := range c.TimeIntervals {
+		if ti.Name == name {
+			return true
+		}
+	}
+	for _, mt := range c.MuteTimeIntervals {
+		if mt.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Type requires validate has been called and just checks the first receiver type. Use HasMixedReceiverTypes to know
+// whether the configuration has receivers of both types.
 func (c *PostableApiAlertingConfig) ReceiverType() ReceiverType {
 	for _, r := range c.Receivers {
 		switch r.Type() {
@@ -312,6 +456,21 @@ func (c *PostableApiAlertingConfig) ReceiverType() ReceiverType {
 	return EmptyReceiverType
 }
 
+// HasMixedReceiverTypes returns true if the configuration has both Grafana and Alertmanager receivers. Such
+// configurations are only valid if loaded with LoadWithMixedReceiverTypes.
+func (c *PostableApiAlertingConfig) HasMixedReceiverTypes() bool {
+	var hasGrafReceivers, hasAMReceivers bool
+	for _, r := range c.Receivers {
+		switch r.Type() {
+		case GrafanaReceiverType:
+			hasGrafReceivers = true
+		case AlertmanagerReceiverType:
+			hasAMReceivers = true
+		}
+	}
+	return hasGrafReceivers && hasAMReceivers
+}
+
 // AllReceivers will recursively walk a routing tree and return a list of all the
 // referenced receiver names.
 func AllReceivers(route *config.Route) (res []string) {
@@ -392,6 +551,8 @@ type PostableGrafanaReceiver struct {
 	DisableResolveMessage bool              `json:"disableResolveMessage" yaml:"disableResolveMessage"`
 	Settings              RawMessage        `json:"settings,omitempty" yaml:"settings,omitempty"`
 	SecureSettings        map[string]string `json:"secureSettings,omitempty" yaml:"secureSettings,omitempty"`
+	// RateLimit limits the notifications of the integration. It overrides the rate limit of the receiver.
+	RateLimit *RateLimit `json:"rateLimit,omitempty" yaml:"rateLimit,omitempty"`
 }
 
 type ReceiverType int
@@ -606,6 +767,46 @@ func (r *PostableApiReceiver) GetName() string {
 
 type PostableGrafanaReceivers struct {
 	GrafanaManagedReceivers []*PostableGrafanaReceiver `yaml:"grafana_managed_receiver_configs,omitempty" json:"grafana_managed_receiver_configs,omitempty"`
+	// FallbackReceiver is the name of the receiver that is notified when all the integrations fail. Some of the time of
+	// the notification is kept for it: half of it, but no more than the fallback timeout of the Alertmanager, so the
+	// integrations retry for a little less time than without a fallback receiver.
+	FallbackReceiver string `yaml:"fallback_receiver,omitempty" json:"fallback_receiver,omitempty"`
+	// RateLimit limits the notifications of the integrations that do not have their own rate limit.
+	RateLimit *RateLimit `yaml:"rate_limit,omitempty" json:"rate_limit,omitempty"`
+	// Digest sends the notifications of the receiver as periodic digests instead of one notification per alert group.
+	Digest *Digest `yaml:"digest,omitempty" json:"digest,omitempty"`
+}
+
+// Digest buffers the notifications of the alert groups of a receiver and sends them as a single notification whenever
+// one of the time intervals of its schedule becomes active.
+type Digest struct {
+	// TimeIntervals are the names of the time intervals that schedule the digest.
+	TimeIntervals []string `yaml:"time_intervals" json:"time_intervals"`
+}
+
+// DefaultRateLimitSummaryTemplate is the template of the summary notification of a rate limit when none is configured.
+const DefaultRateLimitSummaryTemplate = `{{ .Count }} more alert groups suppressed`
+
+// RateLimit limits the number of alert groups notified per interval. The alert groups over the limit are not notified
+// individually, they are folded into a single summary notification sent at the end of the interval.
+type RateLimit struct {
+	// Limit is the number of alert groups that can be notified per interval.
+	Limit int `yaml:"limit" json:"limit"`
+	// Interval is the length of the window the limit applies to.
+	Interval model.Duration `yaml:"interval" json:"interval"`
+	// SummaryTemplate is the template of the text of the summary notification. Defaults to DefaultRateLimitSummaryTemplate.
+	SummaryTemplate string `yaml:"summary_template,omitempty" json:"summary_template,omitempty"`
+}
+
+// Validate checks that the limit and the interval are positive.
+func (r *RateLimit) Validate() error {
+	if r.Limit <= 0 {
+		return fmt.Errorf("rate limit must be positive")
+	}
+	if r.Interval <= 0 {
+		return fmt.Errorf("rate limit interval must be positive")
+	}
+	return nil
 }
 
 // DecryptSecureSettings returns a map containing the decoded and decrypted secure settings.
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -12,6 +13,8 @@ import (
 
 	"github.com/prometheus/alertmanager/config"
 	"github.com/prometheus/alertmanager/pkg/labels"
+	"github.com/prometheus/alertmanager/timeinterval"
+	"github.com/prometheus/common/model"
 )
 
 func Test_ApiReceiver_Marshaling(t *testing.T) {
@@ -214,6 +217,43 @@ func Test_ApiAlertingConfig_Marshaling(t *testing.T) {
 				},
 			},
 		},
+		{
+			desc: "failure mixed",
+			input: PostableApiAlertingConfig{
+				Config: Config{
+					Global: &defaultGlobalConfig,
+					Route: &Route{
+						Receiver: "graf",
+						Routes: []*Route{
+							{
+								Receiver: "am",
+							},
+						},
+					},
+				},
+				Receivers: []*PostableApiReceiver{
+					{
+						Receiver: config.Receiver{
+							Name: "graf",
+						},
+						PostableGrafanaReceivers: PostableGrafanaReceivers{
+							GrafanaManagedReceivers: []*PostableGrafanaReceiver{{}},
+						},
+					},
+					{
+						Receiver: config.Receiver{
+							Name: "am",
+							EmailConfigs: []*config.EmailConfig{{
+								To:      "test@test.com",
+								HTML:    config.DefaultEmailConfig.HTML,
+								Headers: map[string]string{},
+							}},
+						},
+					},
+				},
+			},
+			err: true,
+		},
 		{
 			desc: "failure undefined am receiver",
 			input: PostableApiAlertingConfig{
@@ -270,6 +310,279 @@ func Test_ApiAlertingConfig_Marshaling(t *testing.T) {
 			},
 			err: true,
 		},
+		{
+			desc: "success graf fallback receiver",
+			input: PostableApiAlertingConfig{
+				Config: Config{
+					Global: &defaultGlobalConfig,
+					Route: &Route{
+						Receiver: "graf",
+					},
+				},
+				Receivers: []*PostableApiReceiver{
+					{
+						Receiver: config.Receiver{
+							Name: "graf",
+						},
+						PostableGrafanaReceivers: PostableGrafanaReceivers{
+							GrafanaManagedReceivers: []*PostableGrafanaReceiver{{}},
+							FallbackReceiver:        "fallback",
+						},
+					},
+					{
+						Receiver: config.Receiver{
+							Name: "fallback",
+						},
+						PostableGrafanaReceivers: PostableGrafanaReceivers{
+							GrafanaManagedReceivers: []*PostableGrafanaReceiver{{}},
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "failure undefined graf fallback receiver",
+			input: PostableApiAlertingConfig{
+				Config: Config{
+					Global: &defaultGlobalConfig,
+					Route: &Route{
+						Receiver: "graf",
+					},
+				},
+				Receivers: []*PostableApiReceiver{
+					{
+						Receiver: config.Receiver{
+							Name: "graf",
+						},
+						PostableGrafanaReceivers: PostableGrafanaReceivers{
+							GrafanaManagedReceivers: []*PostableGrafanaReceiver{{}},
+							FallbackReceiver:        "unmentioned",
+						},
+					},
+				},
+			},
+			err: true,
+		},
+		{
+			desc: "failure graf own fallback receiver",
+			input: PostableApiAlertingConfig{
+				Config: Config{
+					Global: &defaultGlobalConfig,
+					Route: &Route{
+						Receiver: "graf",
+					},
+				},
+				Receivers: []*PostableApiReceiver{
+					{
+						Receiver: config.Receiver{
+							Name: "graf",
+						},
+						PostableGrafanaReceivers: PostableGrafanaReceivers{
+							GrafanaManagedReceivers: []*PostableGrafanaReceiver{{}},
+							FallbackReceiver:        "graf",
+						},
+					},
+				},
+			},
+			err: true,
+		},
+		{
+			desc: "success graf rate limits",
+			input: PostableApiAlertingConfig{
+				Config: Config{
+					Global: &defaultGlobalConfig,
+					Route: &Route{
+						Receiver: "graf",
+					},
+				},
+				Receivers: []*PostableApiReceiver{
+					{
+						Receiver: config.Receiver{
+							Name: "graf",
+						},
+						PostableGrafanaReceivers: PostableGrafanaReceivers{
+							GrafanaManagedReceivers: []*PostableGrafanaReceiver{{
+								RateLimit: &RateLimit{Limit: 1, Interval: model.Duration(time.Minute)},
+							}},
+							RateLimit: &RateLimit{Limit: 10, Interval: model.Duration(time.Hour)},
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "failure graf invalid receiver rate limit",
+			input: PostableApiAlertingConfig{
+				Config: Config{
+					Global: &defaultGlobalConfig,
+					Route: &Route{
+						Receiver: "graf",
+					},
+				},
+				Receivers: []*PostableApiReceiver{
+					{
+						Receiver: config.Receiver{
+							Name: "graf",
+						},
+						PostableGrafanaReceivers: PostableGrafanaReceivers{
+							GrafanaManagedReceivers: []*PostableGrafanaReceiver{{}},
+							RateLimit:               &RateLimit{Limit: 10},
+						},
+					},
+				},
+			},
+			err: true,
+		},
+		{
+			desc: "failure graf invalid integration rate limit",
+			input: PostableApiAlertingConfig{
+				Config: Config{
+					Global: &defaultGlobalConfig,
+					Route: &Route{
+						Receiver: "graf",
+					},
+				},
+				Receivers: []*PostableApiReceiver{
+					{
+						Receiver: config.Receiver{
+							Name: "graf",
+						},
+						PostableGrafanaReceivers: PostableGrafanaReceivers{
+							GrafanaManagedReceivers: []*PostableGrafanaReceiver{{
+								RateLimit: &RateLimit{Interval: model.Duration(time.Minute)},
+							}},
+						},
+					},
+				},
+			},
+			err: true,
+		},
+		{
+			desc: "success graf digest",
+			input: PostableApiAlertingConfig{
+				Config: Config{
+					Global: &defaultGlobalConfig,
+					Route: &Route{
+						Receiver: "graf",
+					},
+					TimeIntervals: []config.TimeInterval{{Name: "mornings", TimeIntervals: []timeinterval.TimeInterval{}}},
+				},
+				Receivers: []*PostableApiReceiver{
+					{
+						Receiver: config.Receiver{
+							Name: "graf",
+						},
+						PostableGrafanaReceivers: PostableGrafanaReceivers{
+							GrafanaManagedReceivers: []*PostableGrafanaReceiver{{}},
+							Digest:                  &Digest{TimeIntervals: []string{"mornings"}},
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "failure graf digest undefined time interval",
+			input: PostableApiAlertingConfig{
+				Config: Config{
+					Global: &defaultGlobalConfig,
+					Route: &Route{
+						Receiver: "graf",
+					},
+				},
+				Receivers: []*PostableApiReceiver{
+					{
+						Receiver: config.Receiver{
+							Name: "graf",
+						},
+						PostableGrafanaReceivers: PostableGrafanaReceivers{
+							GrafanaManagedReceivers: []*PostableGrafanaReceiver{{}},
+							Digest:                  &Digest{TimeIntervals: []string{"mornings"}},
+						},
+					},
+				},
+			},
+			err: true,
+		},
+		{
+			desc: "success graf escalation policy",
+			input: PostableApiAlertingConfig{
+				Config: Config{
+					Global: &defaultGlobalConfig,
+					Route: &Route{
+						Receiver:         "graf",
+						EscalationPolicy: "policy",
+					},
+					EscalationPolicies: []EscalationPolicy{{
+						Name:   "policy",
+						Levels: []EscalationLevel{{Receiver: "graf", After: model.Duration(time.Minute)}},
+					}},
+				},
+				Receivers: []*PostableApiReceiver{
+					{
+						Receiver: config.Receiver{
+							Name: "graf",
+						},
+						PostableGrafanaReceivers: PostableGrafanaReceivers{
+							GrafanaManagedReceivers: []*PostableGrafanaReceiver{{}},
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "failure graf undefined escalation policy",
+			input: PostableApiAlertingConfig{
+				Config: Config{
+					Global: &defaultGlobalConfig,
+					Route: &Route{
+						Receiver:         "graf",
+						EscalationPolicy: "unknown",
+					},
+					EscalationPolicies: []EscalationPolicy{{
+						Name:   "policy",
+						Levels: []EscalationLevel{{Receiver: "graf", After: model.Duration(time.Minute)}},
+					}},
+				},
+				Receivers: []*PostableApiReceiver{
+					{
+						Receiver: config.Receiver{
+							Name: "graf",
+						},
+						PostableGrafanaReceivers: PostableGrafanaReceivers{
+							GrafanaManagedReceivers: []*PostableGrafanaReceiver{{}},
+						},
+					},
+				},
+			},
+			err: true,
+		},
+		{
+			desc: "failure graf escalation policy undefined receiver",
+			input: PostableApiAlertingConfig{
+				Config: Config{
+					Global: &defaultGlobalConfig,
+					Route: &Route{
+						Receiver:         "graf",
+						EscalationPolicy: "policy",
+					},
+					EscalationPolicies: []EscalationPolicy{{
+						Name:   "policy",
+						Levels: []EscalationLevel{{Receiver: "unknown", After: model.Duration(time.Minute)}},
+					}},
+				},
+				Receivers: []*PostableApiReceiver{
+					{
+						Receiver: config.Receiver{
+							Name: "graf",
+						},
+						PostableGrafanaReceivers: PostableGrafanaReceivers{
+							GrafanaManagedReceivers: []*PostableGrafanaReceiver{{}},
+						},
+					},
+				},
+			},
+			err: true,
+		},
 		{
 			desc: "failure graf no route",
 			input: PostableApiAlertingConfig{
@@ -475,6 +788,50 @@ func Test_ApiAlertingConfig_Marshaling(t *testing.T) {
 	}
 }
 
+func Test_LoadWithMixedReceiverTypes(t *testing.T) {
+	cfg := `
+route:
+  receiver: graf
+  routes:
+    - receiver: am
+receivers:
+  - name: graf
+    grafana_managed_receiver_configs:
+      - uid: webhook
+        name: graf
+        type: webhook
+  - name: am
+    webhook_configs:
+      - url: http://localhost/am
+`
+	_, err := Load([]byte(cfg))
+	require.EqualError(t, err, "cannot mix Alertmanager & Grafana receiver types")
+
+	c, err := LoadWithMixedReceiverTypes([]byte(cfg))
+	require.NoError(t, err)
+	require.Len(t, c.Receivers, 2)
+	require.True(t, c.HasMixedReceiverTypes())
+
+	// The configuration is still validated.
+	_, err = LoadWithMixedReceiverTypes([]byte("route:\n  receiver: missing\n"))
+	require.EqualError(t, err, "unexpected receiver (missing) is undefined")
+}
+
+func Test_HasMixedReceiverTypes(t *testing.T) {
+	graf := &PostableApiReceiver{
+		Receiver:                 config.Receiver{Name: "graf"},
+		PostableGrafanaReceivers: PostableGrafanaReceivers{GrafanaManagedReceivers: []*PostableGrafanaReceiver{{}}},
+	}
+	am := &PostableApiReceiver{
+		Receiver: config.Receiver{Name: "am", EmailConfigs: []*config.EmailConfig{{To: "test@test.com"}}},
+	}
+	empty := &PostableApiReceiver{Receiver: config.Receiver{Name: "empty"}}
+
+	require.False(t, (&PostableApiAlertingConfig{Receivers: []*PostableApiReceiver{graf, empty}}).HasMixedReceiverTypes())
+	require.False(t, (&PostableApiAlertingConfig{Receivers: []*PostableApiReceiver{am, empty}}).HasMixedReceiverTypes())
+	require.True(t, (&PostableApiAlertingConfig{Receivers: []*PostableApiReceiver{graf, am, empty}}).HasMixedReceiverTypes())
+}
+
 func Test_PostableApiReceiver_Unmarshaling_YAML(t *testing.T) {
 	for _, tc := range []struct {
 		desc  string
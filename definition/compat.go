@@ -20,6 +20,17 @@ func LoadCompat(rawCfg []byte) (*PostableApiAlertingConfig, error) {
 		return nil, err
 	}
 
+	if err := c.SetUpstreamReceiverDefaults(); err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+// SetUpstreamReceiverDefaults sets the global configuration to its defaults if it is missing, and sets the settings
+// that the upstream Alertmanager receivers leave empty to those of the global configuration. It returns an error if
+// a receiver name is not unique or a required setting is missing from both.
+func (c *PostableApiAlertingConfig) SetUpstreamReceiverDefaults() error {
 	// Having a nil global config causes panics in the Alertmanager codebase.
 	if c.Global == nil {
 		c.Global = &config.GlobalConfig{}
@@ -32,7 +43,7 @@ func LoadCompat(rawCfg []byte) (*PostableApiAlertingConfig, error) {
 	names := map[string]struct{}{}
 	for _, rcv := range c.Receivers {
 		if _, ok := names[rcv.Name]; ok {
-			return nil, fmt.Errorf("notification config name %q is not unique", rcv.Name)
+			return fmt.Errorf("notification config name %q is not unique", rcv.Name)
 		}
 		for _, wh := range rcv.WebhookConfigs {
 			if wh.HTTPConfig == nil {
@@ -42,13 +53,13 @@ func LoadCompat(rawCfg []byte) (*PostableApiAlertingConfig, error) {
 		for _, ec := range rcv.EmailConfigs {
 			if ec.Smarthost.String() == "" {
 				if c.Global.SMTPSmarthost.String() == "" {
-					return nil, fmt.Errorf("no global SMTP smarthost set")
+					return fmt.Errorf("no global SMTP smarthost set")
 				}
 				ec.Smarthost = c.Global.SMTPSmarthost
 			}
 			if ec.From == "" {
 				if c.Global.SMTPFrom == "" {
-					return nil, fmt.Errorf("no global SMTP from set")
+					return fmt.Errorf("no global SMTP from set")
 				}
 				ec.From = c.Global.SMTPFrom
 			}
@@ -78,7 +89,7 @@ func LoadCompat(rawCfg []byte) (*PostableApiAlertingConfig, error) {
 			}
 			if sc.APIURL == nil {
 				if c.Global.SlackAPIURL == nil {
-					return nil, fmt.Errorf("no global Slack API URL set")
+					return fmt.Errorf("no global Slack API URL set")
 				}
 				sc.APIURL = c.Global.SlackAPIURL
 			}
@@ -94,7 +105,7 @@ func LoadCompat(rawCfg []byte) (*PostableApiAlertingConfig, error) {
 			}
 			if pdc.URL == nil {
 				if c.Global.PagerdutyURL == nil {
-					return nil, fmt.Errorf("no global PagerDuty URL set")
+					return fmt.Errorf("no global PagerDuty URL set")
 				}
 				pdc.URL = c.Global.PagerdutyURL
 			}
@@ -105,7 +116,7 @@ func LoadCompat(rawCfg []byte) (*PostableApiAlertingConfig, error) {
 			}
 			if ogc.APIURL == nil {
 				if c.Global.OpsGenieAPIURL == nil {
-					return nil, fmt.Errorf("no global OpsGenie URL set")
+					return fmt.Errorf("no global OpsGenie URL set")
 				}
 				ogc.APIURL = c.Global.OpsGenieAPIURL
 			}
@@ -114,7 +125,7 @@ func LoadCompat(rawCfg []byte) (*PostableApiAlertingConfig, error) {
 			}
 			if ogc.APIKey == "" {
 				if c.Global.OpsGenieAPIKey == "" {
-					return nil, fmt.Errorf("no global OpsGenie API Key set")
+					return fmt.Errorf("no global OpsGenie API Key set")
 				}
 				ogc.APIKey = c.Global.OpsGenieAPIKey
 			}
@@ -126,21 +137,21 @@ func LoadCompat(rawCfg []byte) (*PostableApiAlertingConfig, error) {
 
 			if wcc.APIURL == nil {
 				if c.Global.WeChatAPIURL == nil {
-					return nil, fmt.Errorf("no global Wechat URL set")
+					return fmt.Errorf("no global Wechat URL set")
 				}
 				wcc.APIURL = c.Global.WeChatAPIURL
 			}
 
 			if wcc.APISecret == "" {
 				if c.Global.WeChatAPISecret == "" {
-					return nil, fmt.Errorf("no global Wechat ApiSecret set")
+					return fmt.Errorf("no global Wechat ApiSecret set")
 				}
 				wcc.APISecret = c.Global.WeChatAPISecret
 			}
 
 			if wcc.CorpID == "" {
 				if c.Global.WeChatAPICorpID == "" {
-					return nil, fmt.Errorf("no global Wechat CorpID set")
+					return fmt.Errorf("no global Wechat CorpID set")
 				}
 				wcc.CorpID = c.Global.WeChatAPICorpID
 			}
@@ -155,7 +166,7 @@ func LoadCompat(rawCfg []byte) (*PostableApiAlertingConfig, error) {
 			}
 			if voc.APIURL == nil {
 				if c.Global.VictorOpsAPIURL == nil {
-					return nil, fmt.Errorf("no global VictorOps URL set")
+					return fmt.Errorf("no global VictorOps URL set")
 				}
 				voc.APIURL = c.Global.VictorOpsAPIURL
 			}
@@ -164,7 +175,7 @@ func LoadCompat(rawCfg []byte) (*PostableApiAlertingConfig, error) {
 			}
 			if voc.APIKey == "" {
 				if c.Global.VictorOpsAPIKey == "" {
-					return nil, fmt.Errorf("no global VictorOps API Key set")
+					return fmt.Errorf("no global VictorOps API Key set")
 				}
 				voc.APIKey = c.Global.VictorOpsAPIKey
 			}
@@ -188,13 +199,13 @@ func LoadCompat(rawCfg []byte) (*PostableApiAlertingConfig, error) {
 				discord.HTTPConfig = c.Global.HTTPConfig
 			}
 			if discord.WebhookURL == nil {
-				return nil, fmt.Errorf("no discord webhook URL provided")
+				return fmt.Errorf("no discord webhook URL provided")
 			}
 		}
 		for _, webex := range rcv.WebexConfigs {
 			if webex.APIURL == nil {
 				if c.Global.WebexAPIURL == nil {
-					return nil, fmt.Errorf("no global Webex URL set")
+					return fmt.Errorf("no global Webex URL set")
 				}
 
 				webex.APIURL = c.Global.WebexAPIURL
@@ -205,13 +216,13 @@ func LoadCompat(rawCfg []byte) (*PostableApiAlertingConfig, error) {
 				msteams.HTTPConfig = c.Global.HTTPConfig
 			}
 			if msteams.WebhookURL == nil {
-				return nil, fmt.Errorf("no msteams webhook URL provided")
+				return fmt.Errorf("no msteams webhook URL provided")
 			}
 		}
 		names[rcv.Name] = struct{}{}
 	}
 
-	return &c, nil
+	return nil
 }
 
 // GrafanaToUpstreamConfig converts a Grafana alerting configuration into an upstream Alertmanager configuration.
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+)
+
+// Acknowledgement of an alert group. Until it expires, the alert group is neither notified again after its repeat
+// interval nor escalated, but it is still notified when its alerts change.
+type Acknowledgement struct {
+	// Key of the acknowledged alert group.
+	GroupKey string `json:"groupKey"`
+
+	// User who acknowledged the alert group.
+	User string `json:"user"`
+
+	// Comment of the user.
+	Comment string `json:"comment,omitempty"`
+
+	// A timestamp indicating when the alert group was acknowledged.
+	CreatedAt time.Time `json:"createdAt"`
+
+	// A timestamp indicating when the acknowledgement expires.
+	Until time.Time `json:"until"`
+}
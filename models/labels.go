@@ -26,4 +26,8 @@ const (
 
 	ValuesAnnotation      = "__values__"
 	ValueStringAnnotation = "__value_string__"
+
+	// AcknowledgementAnnotation holds the JSON-encoded acknowledgement of an alert group on every alert of the group,
+	// in the alert groups returned by the Alertmanager.
+	AcknowledgementAnnotation = "__acknowledgement__"
 )
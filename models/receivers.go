@@ -15,6 +15,13 @@ type Receiver struct {
 
 	// Name of the receiver.
 	Name string `json:"name"`
+
+	// Name of the receiver that is notified when all the integrations of this receiver fail.
+	FallbackReceiver string `json:"fallbackReceiver,omitempty"`
+
+	// A timestamp indicating the last time the fallback receiver was notified.
+	// Format: date-time
+	LastFallback strfmt.DateTime `json:"lastFallback,omitempty"`
 }
 
 type Integration struct {
@@ -28,6 +35,25 @@ type Integration struct {
 	// Error string for the last attempt to deliver a notification. Empty if the last attempt was successful.
 	LastNotifyAttemptError string `json:"lastNotifyAttemptError,omitempty"`
 
+	// A timestamp indicating the last successful attempt to deliver a notification.
+	// Format: date-time
+	LastSuccessfulNotifyAttempt strfmt.DateTime `json:"lastSuccessfulNotifyAttempt,omitempty"`
+
+	// Number of attempts to deliver a notification that failed since the last successful one.
+	ConsecutiveFailures int `json:"consecutiveFailures"`
+
+	// Total number of successful attempts to deliver a notification.
+	TotalSuccesses uint64 `json:"totalSuccesses"`
+
+	// Total number of failed attempts to deliver a notification.
+	TotalFailures uint64 `json:"totalFailures"`
+
+	// Moving average of the failed attempts to deliver a notification, between 0 and 1.
+	ErrorRate float64 `json:"errorRate"`
+
+	// State of the circuit breaker of the integration: closed, open or half-open. Empty if the integration has no circuit breaker.
+	CircuitBreakerState string `json:"circuitBreakerState,omitempty"`
+
 	// Name of the integration.
 	Name string `json:"name"`
 
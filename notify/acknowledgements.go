@@ -0,0 +1,343 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/alertmanager/dispatch"
+	"github.com/prometheus/alertmanager/notify"
+	"github.com/prometheus/alertmanager/types"
+	"github.com/prometheus/common/model"
+
+	"github.com/grafana/alerting/models"
+	"github.com/grafana/alerting/templates"
+)
+
+var (
+	ErrAcknowledgeGroupBadPayload  = fmt.Errorf("unable to acknowledge alert group")
+	ErrAcknowledgeGroupUnavailable = fmt.Errorf("unable to acknowledge alert group as alertmanager is not initialised yet")
+	ErrAlertGroupNotFound          = fmt.Errorf("alert group not found")
+)
+
+// acknowledgedRepeatInterval replaces the repeat interval of acknowledged alert groups, so that the notification log
+// only lets notifications through when their alerts change. The notification log has no way to disable repeat
+// notifications, so they are pushed past any acknowledgement instead: the acknowledgement is checked on every flush
+// of the alert group, and the repeat interval of the route applies again as soon as it expires. 100 years is far
+// beyond any acknowledgement, and far enough from the limit of time.Duration (about 292 years) for the notification
+// log to subtract it from the current time without overflowing.
+const acknowledgedRepeatInterval = 100 * 365 * 24 * time.Hour
+
+type Acknowledgement = models.Acknowledgement
+
+// AcknowledgeGroup acknowledges the alert group with the given key until the given time. Until then, the alert group is
+// neither notified again after its repeat interval nor escalated, but it is still notified when its alerts change.
+// Acknowledging an alert group again replaces its acknowledgement.
+func (am *GrafanaAlertmanager) AcknowledgeGroup(groupKey, user, comment string, until time.Time) error {
+	now := time.Now()
+	if groupKey == "" {
+		return fmt.Errorf("group key is required: %w", ErrAcknowledgeGroupBadPayload)
+	}
+	if user == "" {
+		return fmt.Errorf("user is required: %w", ErrAcknowledgeGroupBadPayload)
+	}
+	if !until.After(now) {
+		return fmt.Errorf("acknowledgement must end in the future: %w", ErrAcknowledgeGroupBadPayload)
+	}
+
+	am.reloadConfigMtx.RLock()
+	defer am.reloadConfigMtx.RUnlock()
+
+	if !am.ready() {
+		return ErrAcknowledgeGroupUnavailable
+	}
+
+	found := false
+	groups, _ := am.dispatcher.Groups(func(*dispatch.Route) bool { return true }, func(*types.Alert, time.Time) bool { return true })
+	for _, g := range groups {
+		if len(g.Alerts) == 0 {
+			continue
+		}
+		for _, key := range groupKeys(am.route, g.Receiver, g.Labels, g.Alerts[0].Labels) {
+			if key == groupKey {
+				found = true
+			}
+		}
+	}
+	if !found {
+		return ErrAlertGroupNotFound
+	}
+
+	am.acknowledgements.add(Acknowledgement{
+		GroupKey:  groupKey,
+		User:      user,
+		Comment:   comment,
+		CreatedAt: now,
+		Until:     until,
+	})
+	level.Info(am.logger).Log("msg", "Alert group acknowledged", "aggrGroup", groupKey, "user", user, "until", until)
+	return nil
+}
+
+// AlertGroupAcknowledgement returns the acknowledgement of the alert group, as returned by GetAlertGroups, if it is
+// acknowledged. The acknowledgement is in the models.AcknowledgementAnnotation annotation of the alerts of the group.
+func AlertGroupAcknowledgement(g *AlertGroup) (Acknowledgement, bool) {
+	if g == nil || len(g.Alerts) == 0 {
+		return Acknowledgement{}, false
+	}
+	v, ok := g.Alerts[0].Annotations[models.AcknowledgementAnnotation]
+	if !ok {
+		return Acknowledgement{}, false
+	}
+	var ack Acknowledgement
+	if err := json.Unmarshal([]byte(v), &ack); err != nil {
+		return Acknowledgement{}, false
+	}
+	return ack, true
+}
+
+// groupAcknowledgement returns the acknowledgement of the alert group of the dispatcher if it has one that has not
+// expired. It must be called with the configuration lock held.
+func (am *GrafanaAlertmanager) groupAcknowledgement(g *dispatch.AlertGroup, now time.Time) (Acknowledgement, bool) {
+	if len(g.Alerts) == 0 {
+		return Acknowledgement{}, false
+	}
+	for _, key := range groupKeys(am.route, g.Receiver, g.Labels, g.Alerts[0].Labels) {
+		if ack, ok := am.acknowledgements.active(key, now); ok {
+			return ack, true
+		}
+	}
+	return Acknowledgement{}, false
+}
+
+// GetAcknowledgements returns the acknowledgements that have not expired, sorted by group key.
+func (am *GrafanaAlertmanager) GetAcknowledgements() []Acknowledgement {
+	return am.acknowledgements.list(time.Now())
+}
+
+// groupKeys returns the keys of the aggregation groups of the alert group, which is identified by its receiver and
+// group labels. There is more than one only if several routes with the same receiver group the alerts the same way.
+func groupKeys(route *dispatch.Route, receiver string, labels, alertLabels model.LabelSet) []string {
+	if route == nil {
+		return nil
+	}
+	var keys []string
+	for _, r := range route.Match(alertLabels) {
+		if r.RouteOpts.Receiver != receiver || !groupLabels(r, alertLabels).Equal(labels) {
+			continue
+		}
+		keys = append(keys, fmt.Sprintf("%s:%s", r.Key(), labels))
+	}
+	return keys
+}
+
+// groupLabels returns the labels the route groups the alert by, the same way as the dispatcher.
+func groupLabels(r *dispatch.Route, lset model.LabelSet) model.LabelSet {
+	if r.RouteOpts.GroupByAll {
+		return lset.Clone()
+	}
+	res := model.LabelSet{}
+	for ln := range r.RouteOpts.GroupBy {
+		if v, ok := lset[ln]; ok {
+			res[ln] = v
+		}
+	}
+	return res
+}
+
+// acknowledgements holds the acknowledgements of alert groups. They are replicated to peers, and the most recent
+// acknowledgement of an alert group wins. Expired acknowledgements are removed by the maintenance.
+type acknowledgements struct {
+	mtx       sync.Mutex
+	entries   map[string]Acknowledgement
+	broadcast func([]byte)
+}
+
+func newAcknowledgements() *acknowledgements {
+	return &acknowledgements{
+		entries:   make(map[string]Acknowledgement),
+		broadcast: func([]byte) {},
+	}
+}
+
+// loadAcknowledgements restores the acknowledgements from a snapshot. The snapshot is empty if there is none.
+func loadAcknowledgements(r io.Reader) (*acknowledgements, error) {
+	a := newAcknowledgements()
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return a, nil
+	}
+	if err := a.Merge(b); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// SetBroadcast sets the function used to send updates to peers.
+func (a *acknowledgements) SetBroadcast(f func([]byte)) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	a.broadcast = f
+}
+
+// MarshalBinary implements the cluster.State interface.
+func (a *acknowledgements) MarshalBinary() ([]byte, error) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	entries := make([]Acknowledgement, 0, len(a.entries))
+	for _, ack := range a.entries {
+		entries = append(entries, ack)
+	}
+	return json.Marshal(entries)
+}
+
+// Merge implements the cluster.State interface.
+func (a *acknowledgements) Merge(b []byte) error {
+	// Removed Alertmanagers of peers send empty states.
+	if len(b) == 0 {
+		return nil
+	}
+	var entries []Acknowledgement
+	if err := json.Unmarshal(b, &entries); err != nil {
+		return err
+	}
+
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	for _, ack := range entries {
+		if existing, ok := a.entries[ack.GroupKey]; !ok || ack.CreatedAt.After(existing.CreatedAt) {
+			a.entries[ack.GroupKey] = ack
+		}
+	}
+	return nil
+}
+
+// add stores the acknowledgement and sends it to peers.
+func (a *acknowledgements) add(ack Acknowledgement) {
+	a.mtx.Lock()
+	a.entries[ack.GroupKey] = ack
+	broadcast := a.broadcast
+	a.mtx.Unlock()
+
+	b, err := json.Marshal([]Acknowledgement{ack})
+	if err != nil {
+		return
+	}
+	broadcast(b)
+}
+
+// gc removes the acknowledgements that expired longer than the retention ago.
+func (a *acknowledgements) gc(now time.Time, retention time.Duration) int {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	n := 0
+	for key, ack := range a.entries {
+		if now.Sub(ack.Until) > retention {
+			delete(a.entries, key)
+			n++
+		}
+	}
+	return n
+}
+
+// list returns the acknowledgements that have not expired, sorted by group key.
+func (a *acknowledgements) list(now time.Time) []Acknowledgement {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	res := make([]Acknowledgement, 0, len(a.entries))
+	for _, ack := range a.entries {
+		if ack.Until.After(now) {
+			res = append(res, ack)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].GroupKey < res[j].GroupKey
+	})
+	return res
+}
+
+// active returns the acknowledgement of the alert group if it has one that has not expired.
+func (a *acknowledgements) active(groupKey string, now time.Time) (Acknowledgement, bool) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	ack, ok := a.entries[groupKey]
+	if !ok || !ack.Until.After(now) {
+		return Acknowledgement{}, false
+	}
+	return ack, true
+}
+
+// acknowledgementsMaintenance removes the expired acknowledgements and, if snapshot is not nil, snapshots them with the
+// given frequency, and one last time when the Alertmanager stops.
+func (am *GrafanaAlertmanager) acknowledgementsMaintenance(frequency, retention time.Duration, snapshot func(State) (int64, error)) {
+	t := time.NewTicker(frequency)
+	defer t.Stop()
+
+	runMaintenance := func() {
+		start := time.Now()
+		am.acknowledgements.gc(start, retention)
+		if snapshot == nil {
+			return
+		}
+		size, err := snapshot(am.acknowledgements)
+		if err != nil {
+			level.Error(am.logger).Log("msg", "running acknowledgements maintenance failed", "err", err)
+			return
+		}
+		level.Debug(am.logger).Log("msg", "acknowledgements maintenance done", "duration", time.Since(start), "size", size)
+	}
+
+	for {
+		select {
+		case <-am.stopc:
+			runMaintenance()
+			return
+		case <-t.C:
+			runMaintenance()
+		}
+	}
+}
+
+// acknowledgementStage makes the notification log suppress the repeat notifications of acknowledged alert groups, and
+// adds their acknowledgement to the context for the escalation stage and the templates.
+type acknowledgementStage struct {
+	next notify.Stage
+	acks *acknowledgements
+}
+
+func newAcknowledgementStage(next notify.Stage, acks *acknowledgements) *acknowledgementStage {
+	return &acknowledgementStage{
+		next: next,
+		acks: acks,
+	}
+}
+
+// Exec implements the Stage interface.
+func (s *acknowledgementStage) Exec(ctx context.Context, l log.Logger, alerts ...*types.Alert) (context.Context, []*types.Alert, error) {
+	groupKey, ok := notify.GroupKey(ctx)
+	if !ok {
+		return s.next.Exec(ctx, l, alerts...)
+	}
+	now, ok := notify.Now(ctx)
+	if !ok {
+		now = time.Now()
+	}
+	if ack, ok := s.acks.active(groupKey, now); ok {
+		ctx = notify.WithRepeatInterval(ctx, acknowledgedRepeatInterval)
+		ctx = templates.WithAcknowledgement(ctx, &ack)
+	}
+	return s.next.Exec(ctx, l, alerts...)
+}
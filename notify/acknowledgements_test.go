@@ -0,0 +1,222 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-openapi/strfmt"
+	amv2 "github.com/prometheus/alertmanager/api/v2/models"
+	"github.com/prometheus/alertmanager/notify"
+	"github.com/prometheus/alertmanager/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/alerting/models"
+	"github.com/grafana/alerting/templates"
+)
+
+func TestAcknowledgeGroup(t *testing.T) {
+	am, _ := setupAMTest(t)
+
+	until := time.Now().Add(time.Hour)
+	require.ErrorIs(t, am.AcknowledgeGroup(`{}:{alertname="test"}`, "user", "", until), ErrAcknowledgeGroupUnavailable)
+
+	cfg := &testConfiguration{
+		receivers: []*APIReceiver{{ConfigReceiver: ConfigReceiver{Name: "default"}}},
+		route:     &Route{Receiver: "default", GroupBy: []model.LabelName{"alertname"}},
+	}
+	require.NoError(t, am.ApplyConfig(cfg))
+
+	now := time.Now()
+	require.NoError(t, am.PutAlerts(amv2.PostableAlerts{{
+		Alert:    amv2.Alert{Labels: amv2.LabelSet{"alertname": "test", "team": "a"}},
+		StartsAt: strfmt.DateTime(now),
+		EndsAt:   strfmt.DateTime(now.Add(time.Hour)),
+	}}))
+	require.Eventually(t, func() bool {
+		groups, err := am.GetAlertGroups(true, true, true, nil, "")
+		return err == nil && len(groups) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	groupKey := `{}:{alertname="test"}`
+	require.ErrorIs(t, am.AcknowledgeGroup("", "user", "", until), ErrAcknowledgeGroupBadPayload)
+	require.ErrorIs(t, am.AcknowledgeGroup(groupKey, "", "", until), ErrAcknowledgeGroupBadPayload)
+	require.ErrorIs(t, am.AcknowledgeGroup(groupKey, "user", "", now.Add(-time.Minute)), ErrAcknowledgeGroupBadPayload)
+	require.ErrorIs(t, am.AcknowledgeGroup(`{}:{alertname="unknown"}`, "user", "", until), ErrAlertGroupNotFound)
+
+	groups, err := am.GetAlertGroups(true, true, true, nil, "")
+	require.NoError(t, err)
+	_, ok := AlertGroupAcknowledgement(groups[0])
+	require.False(t, ok)
+	require.NotContains(t, groups[0].Alerts[0].Annotations, models.AcknowledgementAnnotation)
+	require.Empty(t, am.GetAcknowledgements())
+
+	require.NoError(t, am.AcknowledgeGroup(groupKey, "user", "looking into it", until))
+
+	groups, err = am.GetAlertGroups(true, true, true, nil, "")
+	require.NoError(t, err)
+	require.Contains(t, groups[0].Alerts[0].Annotations, models.AcknowledgementAnnotation)
+	ack, ok := AlertGroupAcknowledgement(groups[0])
+	require.True(t, ok)
+	require.Equal(t, groupKey, ack.GroupKey)
+	require.Equal(t, "user", ack.User)
+	require.Equal(t, "looking into it", ack.Comment)
+	require.True(t, until.Equal(ack.Until))
+	acks := am.GetAcknowledgements()
+	require.Len(t, acks, 1)
+	require.True(t, acks[0].CreatedAt.Equal(ack.CreatedAt))
+}
+
+func TestAcknowledgementStage(t *testing.T) {
+	acks := newAcknowledgements()
+	now := time.Now()
+	acks.add(Acknowledgement{GroupKey: "acknowledged", User: "user", CreatedAt: now, Until: now.Add(time.Hour)})
+	acks.add(Acknowledgement{GroupKey: "expired", User: "user", CreatedAt: now.Add(-time.Hour), Until: now.Add(-time.Minute)})
+
+	var (
+		repeatInterval time.Duration
+		ack            *Acknowledgement
+	)
+	next := notify.StageFunc(func(ctx context.Context, _ log.Logger, alerts ...*types.Alert) (context.Context, []*types.Alert, error) {
+		repeatInterval, _ = notify.RepeatInterval(ctx)
+		ack, _ = templates.Acknowledgement(ctx)
+		return ctx, alerts, nil
+	})
+	stage := newAcknowledgementStage(next, acks)
+
+	exec := func(groupKey string) {
+		ctx := notify.WithGroupKey(context.Background(), groupKey)
+		ctx = notify.WithRepeatInterval(ctx, time.Hour)
+		ctx = notify.WithNow(ctx, now)
+		_, _, err := stage.Exec(ctx, log.NewNopLogger())
+		require.NoError(t, err)
+	}
+
+	exec("acknowledged")
+	require.Equal(t, acknowledgedRepeatInterval, repeatInterval)
+	require.NotNil(t, ack)
+	require.Equal(t, "user", ack.User)
+
+	for _, groupKey := range []string{"expired", "unknown"} {
+		exec(groupKey)
+		require.Equal(t, time.Hour, repeatInterval)
+		require.Nil(t, ack)
+	}
+}
+
+func TestAcknowledgementStage_Escalation(t *testing.T) {
+	primary, level1 := &recordingStage{}, &recordingStage{}
+	e := &escalator{
+		routePolicies: map[string]EscalationPolicy{
+			`{}`: {Name: "policy", Levels: []EscalationLevel{{Receiver: "level1", After: model.Duration(10 * time.Minute)}}},
+		},
+		stages: map[string]notify.Stage{"level1": level1},
+		state:  newEscalations(),
+	}
+	acks := newAcknowledgements()
+	stage := newAcknowledgementStage(newEscalationStage(primary, e), acks)
+
+	start := time.Now()
+	groupKey := `{}:{alertname="test"}`
+	exec := func(now time.Time) {
+		alert := &types.Alert{Alert: model.Alert{
+			Labels:   model.LabelSet{"alertname": "test"},
+			StartsAt: start.Add(-time.Hour),
+			EndsAt:   now.Add(time.Hour),
+		}}
+		ctx := notify.WithGroupKey(context.Background(), groupKey)
+		ctx = notify.WithNow(ctx, now)
+		_, _, err := stage.Exec(ctx, log.NewNopLogger(), alert)
+		require.NoError(t, err)
+	}
+
+	exec(start)
+	acks.add(Acknowledgement{GroupKey: groupKey, User: "user", CreatedAt: start, Until: start.Add(20 * time.Minute)})
+
+	// Acknowledged alert groups are not escalated.
+	exec(start.Add(10 * time.Minute))
+	keys, _ := level1.calls()
+	require.Empty(t, keys)
+
+	// They are escalated once the acknowledgement expires.
+	exec(start.Add(20 * time.Minute))
+	keys, _ = level1.calls()
+	require.Len(t, keys, 1)
+}
+
+func TestAcknowledgements_Merge(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	a := newAcknowledgements()
+	a.add(Acknowledgement{GroupKey: "group", User: "older", CreatedAt: now, Until: now.Add(time.Hour)})
+
+	b := newAcknowledgements()
+	var broadcast []byte
+	b.SetBroadcast(func(msg []byte) { broadcast = msg })
+	b.add(Acknowledgement{GroupKey: "group", User: "newer", CreatedAt: now.Add(time.Minute), Until: now.Add(time.Hour)})
+
+	// The most recent acknowledgement wins.
+	state, err := a.MarshalBinary()
+	require.NoError(t, err)
+	require.NoError(t, b.Merge(state))
+	ack, ok := b.active("group", now)
+	require.True(t, ok)
+	require.Equal(t, "newer", ack.User)
+
+	// Acknowledgements are sent to peers.
+	require.NoError(t, a.Merge(broadcast))
+	ack, _ = a.active("group", now)
+	require.Equal(t, "newer", ack.User)
+}
+
+func TestAcknowledgements_Maintenance(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	a := newAcknowledgements()
+	a.add(Acknowledgement{GroupKey: "active", User: "user", CreatedAt: now, Until: now.Add(time.Hour)})
+	a.add(Acknowledgement{GroupKey: "expired", User: "user", CreatedAt: now.Add(-2 * time.Hour), Until: now.Add(-time.Hour)})
+
+	// Acknowledgements are restored from their snapshot.
+	state, err := a.MarshalBinary()
+	require.NoError(t, err)
+	restored, err := loadAcknowledgements(bytes.NewReader(state))
+	require.NoError(t, err)
+	ack, ok := restored.active("active", now)
+	require.True(t, ok)
+	require.Equal(t, "user", ack.User)
+
+	empty, err := loadAcknowledgements(bytes.NewReader(nil))
+	require.NoError(t, err)
+	require.Empty(t, empty.list(now))
+
+	// Expired acknowledgements are kept for the retention.
+	require.Equal(t, 0, restored.gc(now, 2*time.Hour))
+	require.Equal(t, 1, restored.gc(now, 30*time.Minute))
+	require.Equal(t, []Acknowledgement{ack}, restored.list(now))
+}
+
+func TestGrafanaAlertmanager_AcknowledgementsSnapshot(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	state, err := json.Marshal([]Acknowledgement{{GroupKey: "group", User: "user", CreatedAt: now, Until: now.Add(time.Hour)}})
+	require.NoError(t, err)
+
+	opts := &recordingMaintenanceOptions{initialState: string(state)}
+	cfg := &GrafanaAlertmanagerConfig{
+		Silences:         newFakeMaintanenceOptions(t),
+		Nflog:            newFakeMaintanenceOptions(t),
+		Acknowledgements: opts,
+	}
+	am, err := NewGrafanaAlertmanager("org", 1, cfg, &NilPeer{}, log.NewNopLogger(), NewGrafanaAlertmanagerMetrics(prometheus.NewPedanticRegistry(), log.NewNopLogger()))
+	require.NoError(t, err)
+	require.Len(t, am.GetAcknowledgements(), 1)
+
+	// The acknowledgements are snapshotted when the Alertmanager stops.
+	am.StopAndWait()
+	var snapshot []Acknowledgement
+	require.NoError(t, json.Unmarshal([]byte(opts.last()), &snapshot))
+	require.Len(t, snapshot, 1)
+	require.Equal(t, "group", snapshot[0].GroupKey)
+}
@@ -0,0 +1,114 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/alertmanager/provider"
+	"github.com/prometheus/alertmanager/provider/mem"
+	"github.com/prometheus/alertmanager/types"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// AlertStore gives access to the set of alerts received by the Alertmanager. The dispatcher and the inhibitor
+// subscribe to it, and it must keep the marker up to date and call the PostDelete of the callback when alerts are
+// removed. All methods are goroutine-safe.
+type AlertStore interface {
+	provider.Alerts
+	// Close stops any background processing done by the store, e.g. garbage collection.
+	Close()
+}
+
+// AlertStoreFactory creates the AlertStore used by a GrafanaAlertmanager.
+type AlertStoreFactory func(marker types.Marker, callback mem.AlertStoreCallback, logger log.Logger, r prometheus.Registerer) (AlertStore, error)
+
+// NewMemAlertStore is the default AlertStoreFactory. It keeps the alerts in memory and periodically removes the resolved ones.
+func NewMemAlertStore(marker types.Marker, callback mem.AlertStoreCallback, logger log.Logger, r prometheus.Registerer) (AlertStore, error) {
+	return mem.NewAlerts(context.Background(), marker, memoryAlertsGCInterval, callback, logger, r)
+}
+
+// AlertsState is a snapshot of the alerts held by the AlertStore.
+type AlertsState []*types.Alert
+
+func (s AlertsState) MarshalBinary() ([]byte, error) {
+	if s == nil {
+		s = AlertsState{}
+	}
+	return json.Marshal(s)
+}
+
+// DecodeAlertsState decodes a snapshot produced by AlertsState.MarshalBinary. An empty snapshot results in an empty state.
+func DecodeAlertsState(r io.Reader) (AlertsState, error) {
+	st := AlertsState{}
+	if err := json.NewDecoder(r).Decode(&st); err != nil {
+		if errors.Is(err, io.EOF) {
+			return AlertsState{}, nil
+		}
+		return nil, err
+	}
+	return st, nil
+}
+
+// AlertsState returns a snapshot of all the alerts currently held by the AlertStore.
+func (am *GrafanaAlertmanager) AlertsState() (AlertsState, error) {
+	it := am.alerts.GetPending()
+	defer it.Close()
+
+	st := AlertsState{}
+	for a := range it.Next() {
+		st = append(st, a)
+	}
+
+	return st, it.Err()
+}
+
+// restoreAlerts puts the alerts of the snapshot back into the AlertStore. Alerts that were resolved for longer than the retention are discarded.
+func (am *GrafanaAlertmanager) restoreAlerts(st AlertsState, retention time.Duration) error {
+	cutoff := time.Now().Add(-retention)
+	alerts := make([]*types.Alert, 0, len(st))
+	for _, a := range st {
+		if a.Resolved() && a.EndsAt.Before(cutoff) {
+			continue
+		}
+		alerts = append(alerts, a)
+	}
+
+	level.Debug(am.logger).Log("msg", "restoring alerts from snapshot", "restored", len(alerts), "discarded", len(st)-len(alerts))
+	return am.alerts.Put(alerts...)
+}
+
+// alertsMaintenance periodically snapshots the alerts until the Alertmanager is stopped, and takes a final snapshot on shutdown.
+func (am *GrafanaAlertmanager) alertsMaintenance(opts MaintenanceOptions) {
+	t := time.NewTicker(opts.MaintenanceFrequency())
+	defer t.Stop()
+
+	runMaintenance := func() {
+		start := time.Now()
+		st, err := am.AlertsState()
+		if err != nil {
+			level.Error(am.logger).Log("msg", "unable to take a snapshot of the alerts", "err", err)
+			return
+		}
+		size, err := opts.MaintenanceFunc(st)
+		if err != nil {
+			level.Error(am.logger).Log("msg", "running alerts maintenance failed", "err", err)
+			return
+		}
+		level.Debug(am.logger).Log("msg", "alerts maintenance done", "duration", time.Since(start), "size", size)
+	}
+
+	for {
+		select {
+		case <-am.stopc:
+			runMaintenance()
+			return
+		case <-t.C:
+			runMaintenance()
+		}
+	}
+}
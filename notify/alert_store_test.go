@@ -0,0 +1,169 @@
+package notify
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/alertmanager/provider"
+	"github.com/prometheus/alertmanager/provider/mem"
+	"github.com/prometheus/alertmanager/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlertsState(t *testing.T) {
+	now := time.Now()
+	newAlert := func(name string, startsAt, endsAt time.Time) *types.Alert {
+		return &types.Alert{
+			Alert: model.Alert{
+				Labels:      model.LabelSet{"alertname": model.LabelValue(name)},
+				Annotations: model.LabelSet{"summary": "test"},
+				StartsAt:    startsAt,
+				EndsAt:      endsAt,
+			},
+			UpdatedAt: now,
+		}
+	}
+
+	firing := newAlert("firing", now.Add(-time.Hour), now.Add(time.Hour))
+	longResolved := newAlert("long-resolved", now.Add(-3*time.Hour), now.Add(-2*time.Hour))
+
+	am, _ := setupAMTest(t)
+	require.NoError(t, am.alerts.Put(firing, longResolved))
+
+	st, err := am.AlertsState()
+	require.NoError(t, err)
+	require.Len(t, st, 2)
+
+	b, err := st.MarshalBinary()
+	require.NoError(t, err)
+
+	t.Run("decoding an empty snapshot results in an empty state", func(t *testing.T) {
+		st, err := DecodeAlertsState(bytes.NewReader(nil))
+		require.NoError(t, err)
+		require.Empty(t, st)
+	})
+
+	t.Run("alerts are restored on startup", func(t *testing.T) {
+		reg := prometheus.NewPedanticRegistry()
+		cfg := &GrafanaAlertmanagerConfig{
+			Silences: newFakeMaintanenceOptions(t),
+			Nflog:    newFakeMaintanenceOptions(t),
+			Alerts:   &fakeMaintenanceOptions{initialState: string(b)},
+		}
+		restored, err := NewGrafanaAlertmanager("org", 1, cfg, &NilPeer{}, log.NewNopLogger(), NewGrafanaAlertmanagerMetrics(reg, log.NewNopLogger()))
+		require.NoError(t, err)
+		t.Cleanup(restored.StopAndWait)
+
+		a, err := restored.alerts.Get(firing.Fingerprint())
+		require.NoError(t, err)
+		require.Equal(t, firing.Labels, a.Labels)
+		require.Equal(t, firing.Annotations, a.Annotations)
+		require.True(t, firing.StartsAt.Equal(a.StartsAt))
+		require.True(t, firing.EndsAt.Equal(a.EndsAt))
+
+		// Alerts resolved before the retention period are discarded.
+		_, err = restored.alerts.Get(longResolved.Fingerprint())
+		require.Error(t, err)
+	})
+
+	t.Run("an invalid snapshot fails to start the Alertmanager", func(t *testing.T) {
+		reg := prometheus.NewPedanticRegistry()
+		cfg := &GrafanaAlertmanagerConfig{
+			Silences: newFakeMaintanenceOptions(t),
+			Nflog:    newFakeMaintanenceOptions(t),
+			Alerts:   &fakeMaintenanceOptions{initialState: "not a snapshot"},
+		}
+		_, err := NewGrafanaAlertmanager("org", 1, cfg, &NilPeer{}, log.NewNopLogger(), NewGrafanaAlertmanagerMetrics(reg, log.NewNopLogger()))
+		require.ErrorContains(t, err, "unable to decode the alerts snapshot")
+	})
+}
+
+// closeTrackingAlertStore fails to iterate over alerts once closed, as stores backed by external systems would.
+type closeTrackingAlertStore struct {
+	AlertStore
+	closed atomic.Bool
+}
+
+func (s *closeTrackingAlertStore) GetPending() provider.AlertIterator {
+	if s.closed.Load() {
+		ch := make(chan *types.Alert)
+		close(ch)
+		return provider.NewAlertIterator(ch, make(chan struct{}), errors.New("alert store is closed"))
+	}
+	return s.AlertStore.GetPending()
+}
+
+func (s *closeTrackingAlertStore) Close() {
+	s.closed.Store(true)
+	s.AlertStore.Close()
+}
+
+func TestStopAndWait_FinalAlertsSnapshot(t *testing.T) {
+	snapshots := &recordingMaintenanceOptions{}
+	cfg := &GrafanaAlertmanagerConfig{
+		Silences: newFakeMaintanenceOptions(t),
+		Nflog:    newFakeMaintanenceOptions(t),
+		Alerts:   snapshots,
+		AlertStore: func(marker types.Marker, callback mem.AlertStoreCallback, logger log.Logger, r prometheus.Registerer) (AlertStore, error) {
+			s, err := NewMemAlertStore(marker, callback, logger, r)
+			if err != nil {
+				return nil, err
+			}
+			return &closeTrackingAlertStore{AlertStore: s}, nil
+		},
+	}
+	am, err := NewGrafanaAlertmanager("org", 1, cfg, &NilPeer{}, log.NewNopLogger(), NewGrafanaAlertmanagerMetrics(prometheus.NewPedanticRegistry(), log.NewNopLogger()))
+	require.NoError(t, err)
+
+	now := time.Now()
+	require.NoError(t, am.alerts.Put(&types.Alert{Alert: model.Alert{
+		Labels:   model.LabelSet{"alertname": "a"},
+		StartsAt: now,
+		EndsAt:   now.Add(time.Hour),
+	}}))
+	am.StopAndWait()
+
+	// The final snapshot is taken before the store is closed.
+	st, err := DecodeAlertsState(strings.NewReader(snapshots.last()))
+	require.NoError(t, err)
+	require.Len(t, st, 1)
+}
+
+type recordingMaintenanceOptions struct {
+	initialState string
+
+	mtx       sync.Mutex
+	snapshots []string
+}
+
+func (o *recordingMaintenanceOptions) InitialState() string                { return o.initialState }
+func (o *recordingMaintenanceOptions) Retention() time.Duration            { return time.Hour }
+func (o *recordingMaintenanceOptions) MaintenanceFrequency() time.Duration { return time.Hour }
+
+func (o *recordingMaintenanceOptions) MaintenanceFunc(state State) (int64, error) {
+	b, err := state.MarshalBinary()
+	if err != nil {
+		return 0, err
+	}
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+	o.snapshots = append(o.snapshots, string(b))
+	return int64(len(b)), nil
+}
+
+func (o *recordingMaintenanceOptions) last() string {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+	if len(o.snapshots) == 0 {
+		return ""
+	}
+	return o.snapshots[len(o.snapshots)-1]
+}
@@ -1,6 +1,7 @@
 package notify
 
 import (
+	"encoding/json"
 	"fmt"
 	"regexp"
 	"sort"
@@ -14,6 +15,8 @@ import (
 	"github.com/prometheus/alertmanager/pkg/labels"
 	"github.com/prometheus/alertmanager/types"
 	prometheus_model "github.com/prometheus/common/model"
+
+	"github.com/grafana/alerting/models"
 )
 
 var (
@@ -124,10 +127,21 @@ func (am *GrafanaAlertmanager) GetAlertGroups(active, silenced, inhibited bool,
 		}
 	}(receiverFilter)
 
+	am.reloadConfigMtx.RLock()
+	defer am.reloadConfigMtx.RUnlock()
+
 	af := am.alertFilter(matchers, silenced, inhibited, active)
 	alertGroups, allReceivers := am.dispatcher.Groups(rf, af)
 
+	return am.toAlertGroups(alertGroups, allReceivers), nil
+}
+
+// toAlertGroups converts the alert groups of the dispatcher to alert groups of the API. The alerts of acknowledged alert
+// groups have the acknowledgement in the models.AcknowledgementAnnotation annotation. It must be called with the
+// configuration lock held.
+func (am *GrafanaAlertmanager) toAlertGroups(alertGroups dispatch.AlertGroups, allReceivers map[prometheus_model.Fingerprint][]string) AlertGroups {
 	res := make(AlertGroups, 0, len(alertGroups))
+	now := time.Now()
 
 	for _, alertGroup := range alertGroups {
 		ag := &AlertGroup{
@@ -136,17 +150,30 @@ func (am *GrafanaAlertmanager) GetAlertGroups(active, silenced, inhibited bool,
 			Alerts:   make([]*GettableAlert, 0, len(alertGroup.Alerts)),
 		}
 
+		var ackAnnotation string
+		if ack, ok := am.groupAcknowledgement(alertGroup, now); ok {
+			b, err := json.Marshal(ack)
+			if err != nil {
+				level.Error(am.logger).Log("msg", "failed to encode the acknowledgement of the alert group", "err", err)
+			} else {
+				ackAnnotation = string(b)
+			}
+		}
+
 		for _, alert := range alertGroup.Alerts {
 			fp := alert.Fingerprint()
 			receivers := allReceivers[fp]
 			status := am.marker.Status(fp)
 			apiAlert := v2.AlertToOpenAPIAlert(alert, status, receivers)
+			if ackAnnotation != "" {
+				apiAlert.Annotations[models.AcknowledgementAnnotation] = ackAnnotation
+			}
 			ag.Alerts = append(ag.Alerts, apiAlert)
 		}
 		res = append(res, ag)
 	}
 
-	return res, nil
+	return res
 }
 
 func (am *GrafanaAlertmanager) alertFilter(matchers []*labels.Matcher, silenced, inhibited, active bool) func(a *types.Alert, now time.Time) bool {
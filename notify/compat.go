@@ -1,14 +1,19 @@
 package notify
 
 import (
+	"crypto/md5"
 	"encoding/json"
 
 	"github.com/grafana/alerting/definition"
+	"github.com/grafana/alerting/templates"
 )
 
 func PostableAPIReceiverToAPIReceiver(r *definition.PostableApiReceiver) *APIReceiver {
 	integrations := GrafanaIntegrations{
-		Integrations: make([]*GrafanaIntegrationConfig, 0, len(r.GrafanaManagedReceivers)),
+		Integrations:     make([]*GrafanaIntegrationConfig, 0, len(r.GrafanaManagedReceivers)),
+		FallbackReceiver: r.FallbackReceiver,
+		RateLimit:        r.RateLimit,
+		Digest:           r.Digest,
 	}
 	for _, p := range r.GrafanaManagedReceivers {
 		integrations.Integrations = append(integrations.Integrations, &GrafanaIntegrationConfig{
@@ -18,6 +23,7 @@ func PostableAPIReceiverToAPIReceiver(r *definition.PostableApiReceiver) *APIRec
 			DisableResolveMessage: p.DisableResolveMessage,
 			Settings:              json.RawMessage(p.Settings),
 			SecureSettings:        p.SecureSettings,
+			RateLimit:             p.RateLimit,
 		})
 	}
 
@@ -26,3 +32,76 @@ func PostableAPIReceiverToAPIReceiver(r *definition.PostableApiReceiver) *APIRec
 		GrafanaIntegrations: integrations,
 	}
 }
+
+// PostableConfiguration is a Configuration of a Grafana Alertmanager configuration. It implements
+// EscalationConfiguration, so that the escalation policies of the configuration are applied.
+type PostableConfiguration struct {
+	cfg               *definition.PostableApiAlertingConfig
+	receivers         []*APIReceiver
+	templates         []templates.TemplateDefinition
+	limits            DispatcherLimits
+	buildIntegrations func(*APIReceiver, *templates.Template) ([]*Integration, error)
+	raw               []byte
+	hash              [16]byte
+}
+
+// NewPostableConfiguration creates the Configuration of the Grafana Alertmanager configuration. The raw configuration
+// and the content of the templates are hashed, so that changes to templates are detected. The number of aggregation
+// groups is not limited if the limits are not present.
+func NewPostableConfiguration(
+	cfg *definition.PostableApiAlertingConfig,
+	raw []byte,
+	tmpls []templates.TemplateDefinition,
+	limits DispatcherLimits,
+	buildIntegrations func(*APIReceiver, *templates.Template) ([]*Integration, error),
+) *PostableConfiguration {
+	if limits == nil {
+		limits = noDispatcherLimits{}
+	}
+
+	h := md5.New()
+	_, _ = h.Write(raw)
+	for _, t := range tmpls {
+		_, _ = h.Write([]byte(t.Template))
+	}
+
+	res := &PostableConfiguration{
+		cfg:               cfg,
+		receivers:         make([]*APIReceiver, 0, len(cfg.Receivers)),
+		templates:         tmpls,
+		limits:            limits,
+		buildIntegrations: buildIntegrations,
+		raw:               raw,
+	}
+	copy(res.hash[:], h.Sum(nil))
+	for _, r := range cfg.Receivers {
+		res.receivers = append(res.receivers, PostableAPIReceiverToAPIReceiver(r))
+	}
+	return res
+}
+
+func (c *PostableConfiguration) DispatcherLimits() DispatcherLimits { return c.limits }
+func (c *PostableConfiguration) InhibitRules() []InhibitRule        { return c.cfg.InhibitRules }
+func (c *PostableConfiguration) TimeIntervals() []TimeInterval      { return c.cfg.TimeIntervals }
+func (c *PostableConfiguration) MuteTimeIntervals() []MuteTimeInterval {
+	return c.cfg.MuteTimeIntervals
+}
+func (c *PostableConfiguration) Receivers() []*APIReceiver                 { return c.receivers }
+func (c *PostableConfiguration) RoutingTree() *Route                       { return c.cfg.Route.AsAMRoute() }
+func (c *PostableConfiguration) Templates() []templates.TemplateDefinition { return c.templates }
+func (c *PostableConfiguration) Hash() [16]byte                            { return c.hash }
+func (c *PostableConfiguration) Raw() []byte                               { return c.raw }
+
+func (c *PostableConfiguration) BuildReceiverIntegrationsFunc() func(next *APIReceiver, tmpl *templates.Template) ([]*Integration, error) {
+	return c.buildIntegrations
+}
+
+func (c *PostableConfiguration) EscalationPolicies() []EscalationPolicy {
+	return c.cfg.EscalationPolicies
+}
+func (c *PostableConfiguration) GrafanaRoutingTree() *definition.Route { return c.cfg.Route }
+
+// noDispatcherLimits does not limit the number of aggregation groups.
+type noDispatcherLimits struct{}
+
+func (noDispatcherLimits) MaxNumberOfAggregationGroups() int { return 0 }
@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/grafana/alerting/definition"
+	"github.com/grafana/alerting/templates"
 	"github.com/prometheus/alertmanager/config"
 	"github.com/stretchr/testify/require"
 )
@@ -38,3 +39,44 @@ func TestPostableApiReceiverToApiReceiver(t *testing.T) {
 	require.Equal(t, json.RawMessage{'b', 'y', 't', 'e', 's'}, i.Settings)
 	require.Equal(t, map[string]string{"key": "value"}, i.SecureSettings)
 }
+
+func TestPostableConfiguration(t *testing.T) {
+	cfg, err := definition.Load([]byte(`
+route:
+  receiver: oncall
+  routes:
+    - receiver: oncall
+      matchers: ['team="a"']
+      escalation_policy: policy
+receivers:
+  - name: oncall
+    grafana_managed_receiver_configs:
+      - uid: webhook
+        name: oncall
+        type: webhook
+        settings:
+          url: http://localhost
+escalation_policies:
+  - name: policy
+    levels:
+      - receiver: oncall
+        after: 1m
+`))
+	require.NoError(t, err)
+
+	tmpls := []templates.TemplateDefinition{{Name: "a", Template: `{{ define "a" }}a{{ end }}`}}
+	c := NewPostableConfiguration(cfg, []byte("raw"), tmpls, nil, nil)
+	require.Len(t, c.Receivers(), 1)
+	require.Equal(t, "webhook", c.Receivers()[0].Integrations[0].UID)
+	require.Equal(t, 0, c.DispatcherLimits().MaxNumberOfAggregationGroups())
+	require.Equal(t, []byte("raw"), c.Raw())
+
+	// The configuration implements EscalationConfiguration, so escalation policies are applied.
+	var ecfg EscalationConfiguration = c
+	require.Len(t, ecfg.EscalationPolicies(), 1)
+	require.Equal(t, "policy", ecfg.GrafanaRoutingTree().Routes[0].EscalationPolicy)
+
+	// Changes to templates change the hash of the configuration.
+	changed := NewPostableConfiguration(cfg, []byte("raw"), []templates.TemplateDefinition{{Name: "a", Template: `{{ define "a" }}b{{ end }}`}}, nil, nil)
+	require.NotEqual(t, c.Hash(), changed.Hash())
+}
@@ -0,0 +1,387 @@
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prometheus/alertmanager/dispatch"
+	"github.com/prometheus/alertmanager/timeinterval"
+	"github.com/prometheus/alertmanager/types"
+	"github.com/prometheus/common/model"
+)
+
+type ChangeType string
+
+const (
+	ChangeTypeAdded   ChangeType = "added"
+	ChangeTypeRemoved ChangeType = "removed"
+	ChangeTypeChanged ChangeType = "changed"
+)
+
+// ConfigPlan describes the changes that applying a configuration would make to the running Alertmanager.
+type ConfigPlan struct {
+	Receivers     []ReceiverChange     `json:"receivers"`
+	Routes        []RouteChange        `json:"routes"`
+	TimeIntervals []TimeIntervalChange `json:"timeIntervals"`
+	InhibitRules  []InhibitRuleChange  `json:"inhibitRules"`
+	// AlertGroups are the active alert groups with at least one alert that would no longer be routed to the group's receiver.
+	AlertGroups []AlertGroupChange `json:"alertGroups"`
+}
+
+type ReceiverChange struct {
+	Name   string     `json:"name"`
+	Change ChangeType `json:"change"`
+	// Integrations contains the integrations that changed. A receiver can change without any integration changing,
+	// e.g. when its upstream Alertmanager configuration changes.
+	Integrations []IntegrationChange `json:"integrations,omitempty"`
+}
+
+type IntegrationChange struct {
+	UID    string     `json:"uid"`
+	Name   string     `json:"name"`
+	Type   string     `json:"type"`
+	Change ChangeType `json:"change"`
+}
+
+type RouteChange struct {
+	// ID uniquely identifies the route in the routing tree by its matchers and those of its parents. Sibling routes with
+	// the same matchers are told apart by a suffix with their order amongst them, e.g. {}/{team="a"}/1.
+	ID     string     `json:"id"`
+	Change ChangeType `json:"change"`
+	// Old and New are the options of the route before and after the change. Old is nil for added routes and New is nil for removed ones.
+	Old *dispatch.RouteOpts `json:"old,omitempty"`
+	New *dispatch.RouteOpts `json:"new,omitempty"`
+}
+
+type TimeIntervalChange struct {
+	Name   string     `json:"name"`
+	Change ChangeType `json:"change"`
+}
+
+type InhibitRuleChange struct {
+	Rule   InhibitRule `json:"rule"`
+	Change ChangeType  `json:"change"`
+}
+
+type AlertGroupChange struct {
+	GroupKey     string         `json:"groupKey"`
+	Labels       model.LabelSet `json:"labels"`
+	Receiver     string         `json:"receiver"`
+	NewReceivers []string       `json:"newReceivers"`
+}
+
+// PlanConfig validates the configuration the same way ApplyConfig does, without applying it, and returns the changes
+// that applying it would make. It is safe to call concurrently, but not while holding the configuration lock.
+func (am *GrafanaAlertmanager) PlanConfig(cfg Configuration) (*ConfigPlan, error) {
+	tmpl, err := am.buildTemplate(cfg.Templates())
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse templates: %w", err)
+	}
+
+	integrationsMap, err := am.buildIntegrationsMap(cfg, tmpl)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build integrations: %w", err)
+	}
+
+	if err := validateFallbackReceivers(cfg.Receivers(), integrationsMap); err != nil {
+		return nil, err
+	}
+	if err := validateRateLimits(cfg.Receivers()); err != nil {
+		return nil, err
+	}
+
+	newRoute := dispatch.NewRoute(cfg.RoutingTree(), nil)
+	newTimeIntervals := am.buildTimeIntervals(cfg.TimeIntervals(), cfg.MuteTimeIntervals())
+	if err := validateDigests(cfg.Receivers(), newTimeIntervals); err != nil {
+		return nil, err
+	}
+	if err := validateRoutes(newRoute, integrationsMap, newTimeIntervals); err != nil {
+		return nil, err
+	}
+	if _, err := escalationPolicies(cfg, newRoute, integrationsMap); err != nil {
+		return nil, err
+	}
+
+	am.reloadConfigMtx.RLock()
+	defer am.reloadConfigMtx.RUnlock()
+
+	plan := &ConfigPlan{
+		Receivers:     diffReceivers(am.apiReceivers, cfg.Receivers()),
+		Routes:        diffRoutes(am.route, newRoute),
+		TimeIntervals: diffTimeIntervals(am.timeIntervals, newTimeIntervals),
+		InhibitRules:  diffInhibitRules(am.inhibitRules, cfg.InhibitRules()),
+	}
+	if am.ready() {
+		plan.AlertGroups = am.diffAlertGroups(newRoute)
+	}
+
+	return plan, nil
+}
+
+// validateRoutes checks that every receiver and time interval referenced in the routing tree exists.
+func validateRoutes(route *dispatch.Route, integrationsMap map[string][]*Integration, timeIntervals map[string][]timeinterval.TimeInterval) error {
+	var err error
+	route.Walk(func(r *dispatch.Route) {
+		if err != nil {
+			return
+		}
+		if _, ok := integrationsMap[r.RouteOpts.Receiver]; !ok {
+			err = fmt.Errorf("route %s references undefined receiver %q", r.ID(), r.RouteOpts.Receiver)
+			return
+		}
+		for _, name := range append(r.RouteOpts.MuteTimeIntervals, r.RouteOpts.ActiveTimeIntervals...) {
+			if _, ok := timeIntervals[name]; !ok {
+				err = fmt.Errorf("route %s references undefined time interval %q", r.ID(), name)
+				return
+			}
+		}
+	})
+	return err
+}
+
+func diffReceivers(old, new []*APIReceiver) []ReceiverChange {
+	oldByName := make(map[string]*APIReceiver, len(old))
+	for _, r := range old {
+		oldByName[r.Name] = r
+	}
+
+	changes := []ReceiverChange{}
+	seen := make(map[string]struct{}, len(new))
+	for _, r := range new {
+		seen[r.Name] = struct{}{}
+		o, ok := oldByName[r.Name]
+		if !ok {
+			changes = append(changes, ReceiverChange{Name: r.Name, Change: ChangeTypeAdded, Integrations: diffIntegrations(nil, r.Integrations)})
+			continue
+		}
+		integrations := diffIntegrations(o.Integrations, r.Integrations)
+		if len(integrations) > 0 || o.FallbackReceiver != r.FallbackReceiver || !reflect.DeepEqual(o.RateLimit, r.RateLimit) || !reflect.DeepEqual(o.Digest, r.Digest) || !reflect.DeepEqual(o.ConfigReceiver, r.ConfigReceiver) {
+			changes = append(changes, ReceiverChange{Name: r.Name, Change: ChangeTypeChanged, Integrations: integrations})
+		}
+	}
+	for _, r := range old {
+		if _, ok := seen[r.Name]; !ok {
+			changes = append(changes, ReceiverChange{Name: r.Name, Change: ChangeTypeRemoved, Integrations: diffIntegrations(r.Integrations, nil)})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Name < changes[j].Name
+	})
+	return changes
+}
+
+func diffIntegrations(old, new []*GrafanaIntegrationConfig) []IntegrationChange {
+	// Integrations are identified by their UID. Those without one are identified by their type and position amongst integrations of the same type.
+	keys := func(integrations []*GrafanaIntegrationConfig) ([]string, map[string]*GrafanaIntegrationConfig) {
+		byKey := make(map[string]*GrafanaIntegrationConfig, len(integrations))
+		ordered := make([]string, 0, len(integrations))
+		idx := make(map[string]int)
+		for _, i := range integrations {
+			key := i.UID
+			if key == "" {
+				key = fmt.Sprintf("%s/%d", i.Type, idx[i.Type])
+				idx[i.Type]++
+			}
+			byKey[key] = i
+			ordered = append(ordered, key)
+		}
+		return ordered, byKey
+	}
+	oldKeys, oldByKey := keys(old)
+	newKeys, newByKey := keys(new)
+
+	var changes []IntegrationChange
+	for _, k := range newKeys {
+		n := newByKey[k]
+		o, ok := oldByKey[k]
+		switch {
+		case !ok:
+			changes = append(changes, IntegrationChange{UID: n.UID, Name: n.Name, Type: n.Type, Change: ChangeTypeAdded})
+		case !integrationConfigsEqual(o, n):
+			changes = append(changes, IntegrationChange{UID: n.UID, Name: n.Name, Type: n.Type, Change: ChangeTypeChanged})
+		}
+	}
+	for _, k := range oldKeys {
+		if _, ok := newByKey[k]; !ok {
+			o := oldByKey[k]
+			changes = append(changes, IntegrationChange{UID: o.UID, Name: o.Name, Type: o.Type, Change: ChangeTypeRemoved})
+		}
+	}
+	return changes
+}
+
+func integrationConfigsEqual(a, b *GrafanaIntegrationConfig) bool {
+	if a.Name != b.Name || a.Type != b.Type || a.DisableResolveMessage != b.DisableResolveMessage || !reflect.DeepEqual(a.SecureSettings, b.SecureSettings) || !reflect.DeepEqual(a.RateLimit, b.RateLimit) {
+		return false
+	}
+	// Settings that only differ in formatting are equal.
+	var as, bs interface{}
+	if json.Unmarshal(a.Settings, &as) != nil || json.Unmarshal(b.Settings, &bs) != nil {
+		return string(a.Settings) == string(b.Settings)
+	}
+	return reflect.DeepEqual(as, bs)
+}
+
+func diffRoutes(old, new *dispatch.Route) []RouteChange {
+	oldIDs, oldByID := routesByID(old)
+	newIDs, newByID := routesByID(new)
+
+	changes := []RouteChange{}
+	for _, id := range newIDs {
+		n := newByID[id]
+		o, ok := oldByID[id]
+		switch {
+		case !ok:
+			changes = append(changes, RouteChange{ID: id, Change: ChangeTypeAdded, New: &n.RouteOpts})
+		case o.Continue != n.Continue || !reflect.DeepEqual(o.RouteOpts, n.RouteOpts):
+			changes = append(changes, RouteChange{ID: id, Change: ChangeTypeChanged, Old: &o.RouteOpts, New: &n.RouteOpts})
+		}
+	}
+	for _, id := range oldIDs {
+		if _, ok := newByID[id]; !ok {
+			changes = append(changes, RouteChange{ID: id, Change: ChangeTypeRemoved, Old: &oldByID[id].RouteOpts})
+		}
+	}
+	return changes
+}
+
+// routesByID returns the IDs of the routes of the routing tree in depth-first order, and the routes by ID. Routes are
+// identified by their matchers and those of their parents rather than by their position, so that adding or removing a
+// route does not change the ID of its siblings. Sibling routes with the same matchers are told apart by their order.
+func routesByID(root *dispatch.Route) ([]string, map[string]*dispatch.Route) {
+	byID := map[string]*dispatch.Route{}
+	var ordered []string
+	if root == nil {
+		return ordered, byID
+	}
+
+	var walk func(r *dispatch.Route, id string)
+	walk = func(r *dispatch.Route, id string) {
+		byID[id] = r
+		ordered = append(ordered, id)
+		seen := make(map[string]int, len(r.Routes))
+		for _, child := range r.Routes {
+			matchers := child.Matchers.String()
+			childID := fmt.Sprintf("%s/%s", id, matchers)
+			if n := seen[matchers]; n > 0 {
+				childID = fmt.Sprintf("%s/%d", childID, n)
+			}
+			seen[matchers]++
+			walk(child, childID)
+		}
+	}
+	walk(root, root.Matchers.String())
+	return ordered, byID
+}
+
+func diffTimeIntervals(old, new map[string][]timeinterval.TimeInterval) []TimeIntervalChange {
+	changes := []TimeIntervalChange{}
+	for name, n := range new {
+		o, ok := old[name]
+		switch {
+		case !ok:
+			changes = append(changes, TimeIntervalChange{Name: name, Change: ChangeTypeAdded})
+		case !reflect.DeepEqual(o, n):
+			changes = append(changes, TimeIntervalChange{Name: name, Change: ChangeTypeChanged})
+		}
+	}
+	for name := range old {
+		if _, ok := new[name]; !ok {
+			changes = append(changes, TimeIntervalChange{Name: name, Change: ChangeTypeRemoved})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Name < changes[j].Name
+	})
+	return changes
+}
+
+// diffInhibitRules compares inhibition rules by value. A modified rule is reported as removed and added.
+func diffInhibitRules(old, new []InhibitRule) []InhibitRuleChange {
+	key := func(r InhibitRule) string {
+		b, err := json.Marshal(r)
+		if err != nil {
+			return fmt.Sprintf("%v", r)
+		}
+		return string(b)
+	}
+	count := func(rules []InhibitRule) map[string]int {
+		res := make(map[string]int, len(rules))
+		for _, r := range rules {
+			res[key(r)]++
+		}
+		return res
+	}
+	oldCount, newCount := count(old), count(new)
+
+	changes := []InhibitRuleChange{}
+	for _, r := range new {
+		k := key(r)
+		if oldCount[k] > 0 {
+			oldCount[k]--
+			continue
+		}
+		changes = append(changes, InhibitRuleChange{Rule: r, Change: ChangeTypeAdded})
+	}
+	for _, r := range old {
+		k := key(r)
+		if newCount[k] > 0 {
+			newCount[k]--
+			continue
+		}
+		changes = append(changes, InhibitRuleChange{Rule: r, Change: ChangeTypeRemoved})
+	}
+	return changes
+}
+
+// diffAlertGroups returns the active alert groups with at least one alert that the new routing tree would not route to the group's receiver.
+// It must be called with the configuration lock held.
+func (am *GrafanaAlertmanager) diffAlertGroups(newRoute *dispatch.Route) []AlertGroupChange {
+	changes := []AlertGroupChange{}
+	allAlerts := func(*types.Alert, time.Time) bool { return true }
+
+	am.route.Walk(func(route *dispatch.Route) {
+		groups, _ := am.dispatcher.Groups(func(r *dispatch.Route) bool { return r == route }, allAlerts)
+		for _, g := range groups {
+			changed := false
+			newReceivers := map[string]struct{}{}
+			for _, a := range g.Alerts {
+				found := false
+				for _, r := range newRoute.Match(a.Labels) {
+					newReceivers[r.RouteOpts.Receiver] = struct{}{}
+					if r.RouteOpts.Receiver == g.Receiver {
+						found = true
+					}
+				}
+				if !found {
+					changed = true
+				}
+			}
+			if !changed {
+				continue
+			}
+
+			receivers := make([]string, 0, len(newReceivers))
+			for r := range newReceivers {
+				receivers = append(receivers, r)
+			}
+			sort.Strings(receivers)
+			changes = append(changes, AlertGroupChange{
+				GroupKey:     fmt.Sprintf("%s:%s", route.Key(), g.Labels),
+				Labels:       g.Labels,
+				Receiver:     g.Receiver,
+				NewReceivers: receivers,
+			})
+		}
+	})
+
+	sort.Slice(changes, func(i, j int) bool {
+		return strings.Compare(changes[i].GroupKey, changes[j].GroupKey) < 0
+	})
+	return changes
+}
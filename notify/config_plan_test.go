@@ -0,0 +1,193 @@
+package notify
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/go-openapi/strfmt"
+	amv2 "github.com/prometheus/alertmanager/api/v2/models"
+	"github.com/prometheus/alertmanager/config"
+	"github.com/prometheus/alertmanager/dispatch"
+	"github.com/prometheus/alertmanager/pkg/labels"
+	"github.com/prometheus/alertmanager/types"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/alerting/notify/nfstatus"
+	"github.com/grafana/alerting/templates"
+)
+
+// testConfiguration is a Configuration whose integrations all use a fakeNotifier.
+type testConfiguration struct {
+	receivers     []*APIReceiver
+	route         *Route
+	inhibitRules  []InhibitRule
+	timeIntervals []TimeInterval
+	templates     []templates.TemplateDefinition
+}
+
+func (c *testConfiguration) DispatcherLimits() DispatcherLimits        { return &nilLimits{} }
+func (c *testConfiguration) InhibitRules() []InhibitRule               { return c.inhibitRules }
+func (c *testConfiguration) TimeIntervals() []TimeInterval             { return c.timeIntervals }
+func (c *testConfiguration) MuteTimeIntervals() []MuteTimeInterval     { return nil }
+func (c *testConfiguration) Receivers() []*APIReceiver                 { return c.receivers }
+func (c *testConfiguration) RoutingTree() *Route                       { return c.route }
+func (c *testConfiguration) Templates() []templates.TemplateDefinition { return c.templates }
+func (c *testConfiguration) Hash() [16]byte                            { return [16]byte{} }
+func (c *testConfiguration) Raw() []byte                               { return []byte("{}") }
+
+func (c *testConfiguration) BuildReceiverIntegrationsFunc() func(next *APIReceiver, tmpl *templates.Template) ([]*Integration, error) {
+	return func(next *APIReceiver, _ *templates.Template) ([]*Integration, error) {
+		integrations := make([]*Integration, 0, len(next.Integrations))
+		for i, cfg := range next.Integrations {
+			n := &fakeNotifier{}
+			integrations = append(integrations, nfstatus.NewIntegrationWithUID(n, n, cfg.Type, i, next.Name, cfg.UID))
+		}
+		return integrations, nil
+	}
+}
+
+type nilLimits struct{}
+
+func (n nilLimits) MaxNumberOfAggregationGroups() int { return 0 }
+
+func TestPlanConfig(t *testing.T) {
+	am, _ := setupAMTest(t)
+	t.Cleanup(am.StopAndWait)
+
+	receiver := func(name string, integrations ...*GrafanaIntegrationConfig) *APIReceiver {
+		return &APIReceiver{
+			ConfigReceiver:      ConfigReceiver{Name: name},
+			GrafanaIntegrations: GrafanaIntegrations{Integrations: integrations},
+		}
+	}
+	matchers := func(name, value string) config.Matchers {
+		m, err := labels.NewMatcher(labels.MatchEqual, name, value)
+		require.NoError(t, err)
+		return config.Matchers{m}
+	}
+
+	current := &testConfiguration{
+		receivers: []*APIReceiver{
+			receiver("default", &GrafanaIntegrationConfig{UID: "a", Type: "email", Settings: json.RawMessage(`{"addresses": "a@example.com"}`)}),
+			receiver("team-a", &GrafanaIntegrationConfig{UID: "b", Type: "slack", Settings: json.RawMessage(`{"recipient": "#a"}`)}),
+			receiver("team-b", &GrafanaIntegrationConfig{UID: "c", Type: "webhook", Settings: json.RawMessage(`{"url": "http://b"}`)}),
+		},
+		route: &Route{
+			Receiver: "default",
+			GroupBy:  []model.LabelName{"alertname"},
+			Routes: []*Route{
+				{Receiver: "team-a", Matchers: matchers("team", "a")},
+				{Receiver: "team-b", Matchers: matchers("team", "b")},
+			},
+		},
+		inhibitRules: []InhibitRule{{SourceMatchers: matchers("severity", "critical"), TargetMatchers: matchers("severity", "warning")}},
+	}
+	require.NoError(t, am.ApplyConfig(current))
+
+	require.NoError(t, am.PutAlerts(amv2.PostableAlerts{{
+		Alert:    amv2.Alert{Labels: amv2.LabelSet{"alertname": "test", "team": "a"}},
+		StartsAt: strfmt.DateTime(time.Now()),
+		EndsAt:   strfmt.DateTime(time.Now().Add(time.Hour)),
+	}}))
+	require.Eventually(t, func() bool {
+		groups, _ := am.dispatcher.Groups(func(*dispatch.Route) bool { return true }, func(*types.Alert, time.Time) bool { return true })
+		return len(groups) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	t.Run("no changes", func(t *testing.T) {
+		plan, err := am.PlanConfig(current)
+		require.NoError(t, err)
+		require.Equal(t, &ConfigPlan{
+			Receivers:     []ReceiverChange{},
+			Routes:        []RouteChange{},
+			TimeIntervals: []TimeIntervalChange{},
+			InhibitRules:  []InhibitRuleChange{},
+			AlertGroups:   []AlertGroupChange{},
+		}, plan)
+	})
+
+	t.Run("changes", func(t *testing.T) {
+		next := &testConfiguration{
+			receivers: []*APIReceiver{
+				// Formatting changes to the settings are not changes.
+				receiver("default", &GrafanaIntegrationConfig{UID: "a", Type: "email", Settings: json.RawMessage(`{"addresses":"a@example.com"}`)}),
+				receiver("team-a", &GrafanaIntegrationConfig{UID: "b", Type: "slack", Settings: json.RawMessage(`{"recipient": "#team-a"}`)}),
+				receiver("team-c", &GrafanaIntegrationConfig{UID: "d", Type: "webhook", Settings: json.RawMessage(`{"url": "http://c"}`)}),
+			},
+			route: &Route{
+				Receiver: "default",
+				GroupBy:  []model.LabelName{"alertname"},
+				Routes: []*Route{
+					{Receiver: "team-c", Matchers: matchers("team", "a")},
+				},
+			},
+		}
+
+		plan, err := am.PlanConfig(next)
+		require.NoError(t, err)
+
+		require.Equal(t, []ReceiverChange{
+			{Name: "team-a", Change: ChangeTypeChanged, Integrations: []IntegrationChange{{UID: "b", Type: "slack", Change: ChangeTypeChanged}}},
+			{Name: "team-b", Change: ChangeTypeRemoved, Integrations: []IntegrationChange{{UID: "c", Type: "webhook", Change: ChangeTypeRemoved}}},
+			{Name: "team-c", Change: ChangeTypeAdded, Integrations: []IntegrationChange{{UID: "d", Type: "webhook", Change: ChangeTypeAdded}}},
+		}, plan.Receivers)
+
+		require.Len(t, plan.Routes, 2)
+		require.Equal(t, ChangeTypeChanged, plan.Routes[0].Change)
+		require.Equal(t, "team-a", plan.Routes[0].Old.Receiver)
+		require.Equal(t, "team-c", plan.Routes[0].New.Receiver)
+		require.Equal(t, ChangeTypeRemoved, plan.Routes[1].Change)
+		require.Nil(t, plan.Routes[1].New)
+
+		require.Equal(t, []InhibitRuleChange{{Rule: current.inhibitRules[0], Change: ChangeTypeRemoved}}, plan.InhibitRules)
+
+		require.Len(t, plan.AlertGroups, 1)
+		require.Equal(t, "team-a", plan.AlertGroups[0].Receiver)
+		require.Equal(t, []string{"team-c"}, plan.AlertGroups[0].NewReceivers)
+		require.Equal(t, model.LabelSet{"alertname": "test"}, plan.AlertGroups[0].Labels)
+	})
+
+	t.Run("inserted route", func(t *testing.T) {
+		next := &testConfiguration{
+			receivers: current.receivers,
+			route: &Route{
+				Receiver: "default",
+				GroupBy:  []model.LabelName{"alertname"},
+				Routes: []*Route{
+					{Receiver: "team-b", Matchers: matchers("team", "c")},
+					{Receiver: "team-a", Matchers: matchers("team", "a")},
+					{Receiver: "team-b", Matchers: matchers("team", "b")},
+				},
+			},
+			inhibitRules: current.inhibitRules,
+		}
+
+		// Routes are matched by their matchers, so the routes after the inserted one are unchanged.
+		plan, err := am.PlanConfig(next)
+		require.NoError(t, err)
+		require.Len(t, plan.Routes, 1)
+		require.Equal(t, ChangeTypeAdded, plan.Routes[0].Change)
+		require.Equal(t, `{}/{team="c"}`, plan.Routes[0].ID)
+	})
+
+	t.Run("invalid configuration", func(t *testing.T) {
+		next := &testConfiguration{
+			receivers: current.receivers,
+			route:     &Route{Receiver: "unknown"},
+		}
+		_, err := am.PlanConfig(next)
+		require.ErrorContains(t, err, `undefined receiver "unknown"`)
+
+		next = &testConfiguration{
+			receivers: current.receivers,
+			route:     &Route{Receiver: "default", MuteTimeIntervals: []string{"unknown"}},
+		}
+		_, err = am.PlanConfig(next)
+		require.ErrorContains(t, err, `undefined time interval "unknown"`)
+
+		// ApplyConfig rejects the same configurations.
+		require.ErrorContains(t, am.ApplyConfig(next), `undefined time interval "unknown"`)
+	})
+}
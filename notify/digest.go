@@ -0,0 +1,284 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/alertmanager/nflog/nflogpb"
+	"github.com/prometheus/alertmanager/notify"
+	"github.com/prometheus/alertmanager/timeinterval"
+	"github.com/prometheus/alertmanager/types"
+	"github.com/prometheus/common/model"
+
+	"github.com/grafana/alerting/notify/nfstatus"
+	"github.com/grafana/alerting/templates"
+)
+
+// digestTimeout is how long a digest notification is retried for.
+const digestTimeout = time.Minute
+
+// validateDigests checks that the time intervals of the digest of every receiver exist.
+func validateDigests(receivers []*APIReceiver, timeIntervals map[string][]timeinterval.TimeInterval) error {
+	for _, r := range receivers {
+		if r.Digest == nil {
+			continue
+		}
+		if len(r.Digest.TimeIntervals) == 0 {
+			return fmt.Errorf("digest of receiver %q has no time intervals", r.Name)
+		}
+		for _, name := range r.Digest.TimeIntervals {
+			if _, ok := timeIntervals[name]; !ok {
+				return fmt.Errorf("time interval %s of the digest of receiver %q doesn't exist in config", name, r.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// buildDigestSchedule returns the time intervals that schedule the digest of the receiver, or nil if it has no digest.
+func buildDigestSchedule(receiver *APIReceiver, timeIntervals map[string][]timeinterval.TimeInterval) []timeinterval.TimeInterval {
+	if receiver == nil || receiver.Digest == nil {
+		return nil
+	}
+	var schedule []timeinterval.TimeInterval
+	for _, name := range receiver.Digest.TimeIntervals {
+		schedule = append(schedule, timeIntervals[name]...)
+	}
+	return schedule
+}
+
+// nextActivation returns the first time after now at which the time intervals become active, or nil if they do not
+// within timeIntervalTransitionHorizon.
+func nextActivation(intervals []timeinterval.TimeInterval, now time.Time) *time.Time {
+	// If the time intervals are active, the next transition is when they become inactive and the one after is when
+	// they become active again.
+	for t, i := now, 0; i < 2; i++ {
+		next := nextTransition(intervals, t)
+		if next == nil {
+			return nil
+		}
+		if containsTime(intervals, *next) {
+			return next
+		}
+		t = *next
+	}
+	return nil
+}
+
+type digestGroup struct {
+	groupKey    string
+	groupLabels model.LabelSet
+	alerts      []*types.Alert
+	// firing, resolved and expiry are recorded in the notification log once the digest is sent.
+	firing   []uint64
+	resolved []uint64
+	expiry   time.Duration
+}
+
+// digestStage buffers the notifications of the alert groups of an integration and sends them as a single digest
+// notification when the time intervals of its schedule become active. Only the last notification of each alert group
+// is part of the digest. Templates get the data of every alert group of the digest in the Groups field.
+//
+// Buffered alert groups are only recorded in the notification log once the digest is sent. Until then, the pipeline
+// notifies them again at every group interval, which updates the buffer. Alert groups of a digest that fails to be
+// sent are kept for the next digest. As the summaries of rate limits, the buffer is dropped when the configuration is
+// replaced or the Alertmanager stops, as the alert groups it holds were not recorded and are buffered again by the next
+// pipeline. A digest being sent when the Alertmanager stops is canceled, so that stopping does not wait for the
+// integration.
+type digestStage struct {
+	next        notify.Stage
+	tmpl        *templates.Template
+	schedule    []timeinterval.TimeInterval
+	receiver    string
+	integration *nfstatus.Integration
+	nflog       notify.NotificationLog
+	recv        *nflogpb.Receiver
+	lifecycle   stageLifecycle
+	logger      log.Logger
+
+	mtx    sync.Mutex
+	groups map[string]digestGroup
+	// scheduled is whether the next digest is scheduled.
+	scheduled bool
+	// neverActive is whether the schedule was found to never become active.
+	neverActive bool
+}
+
+func newDigestStage(next notify.Stage, tmpl *templates.Template, schedule []timeinterval.TimeInterval, receiver string, integration *nfstatus.Integration, nflog notify.NotificationLog, recv *nflogpb.Receiver, lifecycle stageLifecycle, logger log.Logger) *digestStage {
+	return &digestStage{
+		next:        next,
+		tmpl:        tmpl,
+		schedule:    schedule,
+		receiver:    receiver,
+		integration: integration,
+		nflog:       nflog,
+		recv:        recv,
+		lifecycle:   lifecycle,
+		logger:      logger,
+		groups:      make(map[string]digestGroup),
+	}
+}
+
+// Exec implements the Stage interface.
+func (s *digestStage) Exec(ctx context.Context, l log.Logger, alerts ...*types.Alert) (context.Context, []*types.Alert, error) {
+	groupKey, ok := notify.GroupKey(ctx)
+	if !ok {
+		return ctx, nil, fmt.Errorf("group key missing")
+	}
+	firing, ok := notify.FiringAlerts(ctx)
+	if !ok {
+		return ctx, nil, fmt.Errorf("firing alerts missing")
+	}
+	resolved, ok := notify.ResolvedAlerts(ctx)
+	if !ok {
+		return ctx, nil, fmt.Errorf("resolved alerts missing")
+	}
+	repeat, ok := notify.RepeatInterval(ctx)
+	if !ok {
+		return ctx, nil, fmt.Errorf("repeat interval missing")
+	}
+	groupLabels, _ := notify.GroupLabels(ctx)
+
+	s.mtx.Lock()
+	if !s.scheduled && !s.scheduleNext() {
+		s.mtx.Unlock()
+		// The lock is not held while notifying, which retries until the context is done.
+		level.Warn(l).Log("msg", "Digest schedule never becomes active, notifying the alert group directly", "receiver", s.receiver, "integration", s.integration.String())
+		return s.next.Exec(ctx, l, alerts...)
+	}
+	defer s.mtx.Unlock()
+
+	level.Debug(l).Log("msg", "Adding alert group to digest", "receiver", s.receiver, "integration", s.integration.String(), "aggrGroup", groupKey)
+	s.groups[groupKey] = digestGroup{
+		groupKey:    groupKey,
+		groupLabels: groupLabels,
+		alerts:      append([]*types.Alert(nil), alerts...),
+		firing:      firing,
+		resolved:    resolved,
+		// The same expiry as the entries of the notification log written by the pipeline.
+		expiry: 2 * repeat,
+	}
+	// No alerts are passed on, so that the notification log does not record the alert group until the digest is sent.
+	return ctx, nil, nil
+}
+
+// scheduleNext schedules the next digest when the schedule next becomes active. It returns false if it does not
+// within timeIntervalTransitionHorizon, which is only searched once for the configuration, as the stage does not
+// outlive it for that long. It must be called with the lock held.
+func (s *digestStage) scheduleNext() bool {
+	if s.neverActive {
+		return false
+	}
+	next := nextActivation(s.schedule, time.Now())
+	if next == nil {
+		s.neverActive = true
+		return false
+	}
+	s.scheduled = true
+	s.lifecycle.after(time.Until(*next), s.onActivation)
+	return true
+}
+
+// onActivation sends the digest when the schedule becomes active, and drops the buffer when the configuration is
+// replaced or the Alertmanager stops.
+func (s *digestStage) onActivation(e lifecycleEvent) {
+	if e == lifecycleTimer {
+		s.sendDigest()
+		return
+	}
+
+	s.mtx.Lock()
+	dropped := len(s.groups)
+	s.groups = make(map[string]digestGroup)
+	s.scheduled = false
+	s.mtx.Unlock()
+	level.Debug(s.logger).Log("msg", "Dropping buffered digest", "receiver", s.receiver, "integration", s.integration.String(), "groups", dropped)
+}
+
+// sendDigest sends the digest notification of the alert groups buffered since the previous digest, and records them
+// in the notification log once sent.
+func (s *digestStage) sendDigest() {
+	s.mtx.Lock()
+	buffered := s.groups
+	s.groups = make(map[string]digestGroup)
+	s.scheduled = false
+	s.mtx.Unlock()
+
+	if len(buffered) == 0 {
+		return
+	}
+
+	ctx, cancel := s.lifecycle.context(digestTimeout)
+	defer cancel()
+
+	ctx, alerts := s.digestNotification(ctx, buffered)
+	if _, _, err := s.next.Exec(ctx, s.logger, alerts...); err != nil {
+		level.Error(s.logger).Log("msg", "Failed to send digest notification, keeping the alert groups for the next digest", "receiver", s.receiver, "integration", s.integration.String(), "err", err)
+		s.mtx.Lock()
+		defer s.mtx.Unlock()
+		for k, g := range buffered {
+			// Alert groups notified again since are more recent.
+			if _, ok := s.groups[k]; !ok {
+				s.groups[k] = g
+			}
+		}
+		if !s.scheduled && !s.scheduleNext() {
+			level.Error(s.logger).Log("msg", "Digest schedule never becomes active, dropping the alert groups", "receiver", s.receiver, "integration", s.integration.String())
+			s.groups = make(map[string]digestGroup)
+		}
+		return
+	}
+
+	for _, g := range buffered {
+		if err := s.nflog.Log(s.recv, g.groupKey, g.firing, g.resolved, g.expiry); err != nil {
+			level.Error(s.logger).Log("msg", "Failed to record digest notification in the notification log", "receiver", s.receiver, "integration", s.integration.String(), "aggrGroup", g.groupKey, "err", err)
+		}
+	}
+}
+
+// digestNotification returns the alerts of the digest notification of the alert groups and the context to send them with.
+func (s *digestStage) digestNotification(ctx context.Context, buffered map[string]digestGroup) (context.Context, []*types.Alert) {
+	groups := make([]digestGroup, 0, len(buffered))
+	for _, g := range buffered {
+		groups = append(groups, g)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].groupKey < groups[j].groupKey
+	})
+
+	var (
+		alerts   []*types.Alert
+		firing   []uint64
+		resolved []uint64
+		data     = make([]*templates.ExtendedData, 0, len(groups))
+	)
+	for _, g := range groups {
+		groupCtx := notify.WithReceiverName(ctx, s.receiver)
+		groupCtx = notify.WithGroupLabels(groupCtx, g.groupLabels)
+		data = append(data, templates.ExtendData(notify.GetTemplateData(groupCtx, s.tmpl, g.alerts, s.logger), s.logger))
+
+		for _, a := range g.alerts {
+			alerts = append(alerts, a)
+			if a.Resolved() {
+				resolved = append(resolved, uint64(a.Fingerprint()))
+			} else {
+				firing = append(firing, uint64(a.Fingerprint()))
+			}
+		}
+	}
+
+	labels := model.LabelSet{"receiver": model.LabelValue(s.receiver)}
+	ctx = notify.WithGroupKey(ctx, fmt.Sprintf("{}/digest:%s", labels))
+	ctx = notify.WithGroupLabels(ctx, labels)
+	ctx = notify.WithReceiverName(ctx, s.receiver)
+	ctx = notify.WithNow(ctx, time.Now())
+	ctx = notify.WithFiringAlerts(ctx, firing)
+	ctx = notify.WithResolvedAlerts(ctx, resolved)
+	ctx = templates.WithDigestGroups(ctx, data)
+	return ctx, alerts
+}
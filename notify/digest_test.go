@@ -0,0 +1,252 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/alertmanager/nflog"
+	"github.com/prometheus/alertmanager/nflog/nflogpb"
+	"github.com/prometheus/alertmanager/notify"
+	"github.com/prometheus/alertmanager/timeinterval"
+	"github.com/prometheus/alertmanager/types"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/alerting/notify/nfstatus"
+	"github.com/grafana/alerting/templates"
+)
+
+type templatingStage struct {
+	tmpl *templates.Template
+	text string
+	out  []string
+	err  error
+}
+
+func (s *templatingStage) Exec(ctx context.Context, l log.Logger, alerts ...*types.Alert) (context.Context, []*types.Alert, error) {
+	var tmplErr error
+	tmpl, _ := templates.TmplText(ctx, s.tmpl, alerts, l, &tmplErr)
+	if s.err != nil {
+		return ctx, nil, s.err
+	}
+	s.out = append(s.out, tmpl(s.text))
+	return ctx, alerts, tmplErr
+}
+
+func TestNextActivation(t *testing.T) {
+	// Every day from 09:00 to 10:00 UTC.
+	intervals := []timeinterval.TimeInterval{{
+		Times: []timeinterval.TimeRange{{StartMinute: 9 * 60, EndMinute: 10 * 60}},
+	}}
+
+	now := time.Date(2024, 3, 4, 8, 0, 0, 0, time.UTC)
+	require.Equal(t, time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC), *nextActivation(intervals, now))
+
+	// While the time interval is active, it next becomes active the day after.
+	now = time.Date(2024, 3, 4, 9, 30, 0, 0, time.UTC)
+	require.Equal(t, time.Date(2024, 3, 5, 9, 0, 0, 0, time.UTC), *nextActivation(intervals, now))
+
+	// An empty time interval is always active.
+	require.Nil(t, nextActivation([]timeinterval.TimeInterval{{}}, now))
+}
+
+func TestDigestStage(t *testing.T) {
+	am, _ := setupAMTest(t)
+	tmpl, err := am.buildTemplate(nil)
+	require.NoError(t, err)
+
+	schedule := []timeinterval.TimeInterval{{
+		Times: []timeinterval.TimeRange{{StartMinute: 0, EndMinute: 1}},
+	}}
+	next := &templatingStage{
+		tmpl: tmpl,
+		text: `{{ len .Alerts }} alerts in {{ len .Groups }} groups:{{ range .Groups }} {{ .GroupLabels.alertname }}={{ .Status }}{{ end }}`,
+	}
+	integration := nfstatus.NewIntegration(&fakeNotifier{}, &fakeNotifier{}, "webhook", 0, "receiver")
+	nflog := &recordingNotificationLog{}
+	recv := &nflogpb.Receiver{GroupName: "receiver", Integration: "webhook"}
+	stage := newDigestStage(next, tmpl, schedule, "receiver", integration, nflog, recv, am.stageLifecycle(), log.NewNopLogger())
+
+	now := time.Now()
+	firing := func(name string) *types.Alert {
+		return &types.Alert{Alert: model.Alert{
+			Labels:   model.LabelSet{"alertname": model.LabelValue(name)},
+			StartsAt: now,
+			EndsAt:   now.Add(time.Hour),
+		}}
+	}
+	resolved := func(name string) *types.Alert {
+		return &types.Alert{Alert: model.Alert{
+			Labels:   model.LabelSet{"alertname": model.LabelValue(name)},
+			StartsAt: now.Add(-time.Hour),
+			EndsAt:   now.Add(-time.Minute),
+		}}
+	}
+	exec := func(name string, alerts ...*types.Alert) {
+		ctx := notify.WithGroupKey(context.Background(), "group:"+name)
+		ctx = notify.WithGroupLabels(ctx, model.LabelSet{"alertname": model.LabelValue(name)})
+		ctx = notify.WithFiringAlerts(ctx, []uint64{})
+		ctx = notify.WithResolvedAlerts(ctx, []uint64{})
+		ctx = notify.WithRepeatInterval(ctx, time.Hour)
+		_, sent, err := stage.Exec(ctx, log.NewNopLogger(), alerts...)
+		require.NoError(t, err)
+		// Buffered notifications are not reported as notified, so that the notification log does not record them
+		// until the digest is sent.
+		require.Empty(t, sent)
+	}
+
+	exec("a", firing("a"))
+	exec("b", firing("b"))
+	// Only the last notification of an alert group is part of the digest.
+	exec("a", resolved("a"))
+	require.Empty(t, next.out)
+	require.Empty(t, nflog.groupKeys())
+
+	stage.mtx.Lock()
+	require.True(t, stage.scheduled)
+	stage.mtx.Unlock()
+
+	// The alert groups of a digest that fails to be sent are kept for the next digest.
+	next.err = errors.New("failed to send")
+	stage.sendDigest()
+	require.Empty(t, nflog.groupKeys())
+	stage.mtx.Lock()
+	require.Len(t, stage.groups, 2)
+	require.True(t, stage.scheduled)
+	stage.mtx.Unlock()
+
+	next.err = nil
+	next.out = nil
+	stage.sendDigest()
+	require.Equal(t, []string{"2 alerts in 2 groups: a=resolved b=firing"}, next.out)
+	// The alert groups are recorded in the notification log once the digest is sent.
+	require.ElementsMatch(t, []string{"group:a", "group:b"}, nflog.groupKeys())
+
+	// Nothing is sent when no alert group was notified since the previous digest.
+	stage.sendDigest()
+	require.Len(t, next.out, 1)
+}
+
+func TestDigestStage_Lifecycle(t *testing.T) {
+	am, _ := setupAMTest(t)
+	tmpl, err := am.buildTemplate(nil)
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	reloadc, stopc := make(chan struct{}), make(chan struct{})
+	schedule := []timeinterval.TimeInterval{{Times: []timeinterval.TimeRange{{StartMinute: 0, EndMinute: 1}}}}
+	integration := nfstatus.NewIntegration(&fakeNotifier{}, &fakeNotifier{}, "webhook", 0, "receiver")
+	next := &recordingStage{}
+	stage := newDigestStage(next, tmpl, schedule, "receiver", integration, &recordingNotificationLog{}, &nflogpb.Receiver{}, stageLifecycle{wg: &wg, reloadc: reloadc, stopc: stopc}, log.NewNopLogger())
+
+	ctx := notify.WithGroupKey(context.Background(), "group")
+	ctx = notify.WithFiringAlerts(ctx, []uint64{})
+	ctx = notify.WithResolvedAlerts(ctx, []uint64{})
+	ctx = notify.WithRepeatInterval(ctx, time.Hour)
+	_, _, err = stage.Exec(ctx, log.NewNopLogger(), &types.Alert{Alert: model.Alert{Labels: model.LabelSet{"alertname": "a"}}})
+	require.NoError(t, err)
+
+	// The buffer is dropped without sending the digest when the configuration is replaced.
+	close(reloadc)
+	wg.Wait()
+	keys, _ := next.calls()
+	require.Empty(t, keys)
+	stage.mtx.Lock()
+	require.Empty(t, stage.groups)
+	require.False(t, stage.scheduled)
+	stage.mtx.Unlock()
+}
+
+// blockingStage blocks until it is released.
+type blockingStage struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s *blockingStage) Exec(ctx context.Context, _ log.Logger, alerts ...*types.Alert) (context.Context, []*types.Alert, error) {
+	close(s.started)
+	<-s.release
+	return ctx, alerts, nil
+}
+
+func TestDigestStage_NeverActive(t *testing.T) {
+	am, _ := setupAMTest(t)
+	tmpl, err := am.buildTemplate(nil)
+	require.NoError(t, err)
+
+	schedule := []timeinterval.TimeInterval{{Years: []timeinterval.YearRange{{InclusiveRange: timeinterval.InclusiveRange{Begin: 1990, End: 1990}}}}}
+	integration := nfstatus.NewIntegration(&fakeNotifier{}, &fakeNotifier{}, "webhook", 0, "receiver")
+	next := &blockingStage{started: make(chan struct{}), release: make(chan struct{})}
+	stage := newDigestStage(next, tmpl, schedule, "receiver", integration, &recordingNotificationLog{}, &nflogpb.Receiver{}, am.stageLifecycle(), log.NewNopLogger())
+
+	ctx := notify.WithGroupKey(context.Background(), "group")
+	ctx = notify.WithFiringAlerts(ctx, []uint64{})
+	ctx = notify.WithResolvedAlerts(ctx, []uint64{})
+	ctx = notify.WithRepeatInterval(ctx, time.Hour)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, alerts, err := stage.Exec(ctx, log.NewNopLogger(), &types.Alert{Alert: model.Alert{Labels: model.LabelSet{"alertname": "a"}}})
+		assert.NoError(t, err)
+		assert.Len(t, alerts, 1)
+	}()
+
+	// The alert group is notified directly, without holding the lock of the stage.
+	<-next.started
+	require.True(t, stage.mtx.TryLock())
+	// That the schedule never becomes active is only searched once.
+	require.True(t, stage.neverActive)
+	stage.mtx.Unlock()
+	close(next.release)
+	<-done
+}
+
+type recordingNotificationLog struct {
+	mtx  sync.Mutex
+	keys []string
+}
+
+func (l *recordingNotificationLog) Log(_ *nflogpb.Receiver, gkey string, _, _ []uint64, _ time.Duration) error {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	l.keys = append(l.keys, gkey)
+	return nil
+}
+
+func (l *recordingNotificationLog) Query(_ ...nflog.QueryParam) ([]*nflogpb.Entry, error) {
+	return nil, nflog.ErrNotFound
+}
+
+func (l *recordingNotificationLog) groupKeys() []string {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	return append([]string{}, l.keys...)
+}
+
+func TestApplyConfig_Digest(t *testing.T) {
+	am, _ := setupAMTest(t)
+
+	cfg := &testConfiguration{
+		receivers: []*APIReceiver{{
+			ConfigReceiver: ConfigReceiver{Name: "digest"},
+			GrafanaIntegrations: GrafanaIntegrations{
+				Integrations: []*GrafanaIntegrationConfig{{Type: "webhook"}},
+				Digest:       &Digest{TimeIntervals: []string{"mornings"}},
+			},
+		}},
+		route: &Route{Receiver: "digest"},
+		timeIntervals: []TimeInterval{{
+			Name:          "mornings",
+			TimeIntervals: []timeinterval.TimeInterval{{Times: []timeinterval.TimeRange{{StartMinute: 9 * 60, EndMinute: 9*60 + 1}}}},
+		}},
+	}
+	require.NoError(t, am.ApplyConfig(cfg))
+
+	cfg.receivers[0].Digest.TimeIntervals = []string{"unknown"}
+	require.ErrorContains(t, am.ApplyConfig(cfg), `time interval unknown of the digest of receiver "digest" doesn't exist in config`)
+}
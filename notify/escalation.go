@@ -0,0 +1,342 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/alertmanager/dispatch"
+	"github.com/prometheus/alertmanager/notify"
+	"github.com/prometheus/alertmanager/types"
+
+	"github.com/grafana/alerting/definition"
+	"github.com/grafana/alerting/templates"
+)
+
+type EscalationPolicy = definition.EscalationPolicy
+type EscalationLevel = definition.EscalationLevel
+
+// EscalationConfiguration is implemented by configurations with escalation policies. Escalation policies are
+// referenced from the routes of the Grafana routing tree, which must have the same routes as RoutingTree.
+type EscalationConfiguration interface {
+	EscalationPolicies() []EscalationPolicy
+	GrafanaRoutingTree() *definition.Route
+}
+
+// escalationPolicies returns the escalation policies of the configuration, and the escalation policy of every route
+// that has one, by route key.
+func escalationPolicies(cfg Configuration, route *dispatch.Route, integrationsMap map[string][]*Integration) (map[string]EscalationPolicy, error) {
+	ecfg, ok := cfg.(EscalationConfiguration)
+	if !ok || len(ecfg.EscalationPolicies()) == 0 {
+		return nil, nil
+	}
+
+	receivers := make(map[string]struct{}, len(integrationsMap))
+	for name := range integrationsMap {
+		receivers[name] = struct{}{}
+	}
+	if err := definition.ValidateEscalationPolicies(ecfg.EscalationPolicies(), ecfg.GrafanaRoutingTree(), receivers); err != nil {
+		return nil, err
+	}
+	policies := make(map[string]EscalationPolicy, len(ecfg.EscalationPolicies()))
+	for _, p := range ecfg.EscalationPolicies() {
+		policies[p.Name] = p
+	}
+
+	routePolicies := make(map[string]EscalationPolicy)
+	var walk func(r *dispatch.Route, gr *definition.Route, inherited string) error
+	walk = func(r *dispatch.Route, gr *definition.Route, inherited string) error {
+		name := inherited
+		if gr.EscalationPolicy != "" {
+			name = gr.EscalationPolicy
+		}
+		if name != "" {
+			p := policies[name]
+			// Group keys only identify routes by key, which siblings with the same matchers share.
+			if other, ok := routePolicies[r.Key()]; ok && other.Name != p.Name {
+				return fmt.Errorf("routes with key %s have different escalation policies", r.Key())
+			}
+			routePolicies[r.Key()] = p
+		}
+		if len(r.Routes) != len(gr.Routes) {
+			return fmt.Errorf("the Grafana routing tree does not match the routing tree")
+		}
+		for i := range r.Routes {
+			if err := walk(r.Routes[i], gr.Routes[i], name); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if route == nil || ecfg.GrafanaRoutingTree() == nil {
+		return routePolicies, nil
+	}
+	if err := walk(route, ecfg.GrafanaRoutingTree(), ""); err != nil {
+		return nil, err
+	}
+	return routePolicies, nil
+}
+
+// escalationEntry is the escalation state of an alert group.
+type escalationEntry struct {
+	GroupKey string `json:"groupKey"`
+	Policy   string `json:"policy"`
+	// FiringSince is when the alert group started firing.
+	FiringSince time.Time `json:"firingSince"`
+	// Level is the number of levels of the policy that were reached.
+	Level int `json:"level"`
+	// Resolved is true once the alert group stopped firing. The entry is kept until it is garbage collected so that
+	// peers learn about it.
+	Resolved  bool      `json:"resolved,omitempty"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// escalations holds the escalation state of every alert group with an escalation policy. It is replicated to peers,
+// and the most recently updated entry of an alert group wins.
+type escalations struct {
+	mtx       sync.Mutex
+	entries   map[string]escalationEntry
+	broadcast func([]byte)
+}
+
+func newEscalations() *escalations {
+	return &escalations{
+		entries:   make(map[string]escalationEntry),
+		broadcast: func([]byte) {},
+	}
+}
+
+// SetBroadcast sets the function used to send updates to peers.
+func (e *escalations) SetBroadcast(f func([]byte)) {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+	e.broadcast = f
+}
+
+// MarshalBinary implements the cluster.State interface.
+func (e *escalations) MarshalBinary() ([]byte, error) {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+
+	entries := make([]escalationEntry, 0, len(e.entries))
+	for _, entry := range e.entries {
+		entries = append(entries, entry)
+	}
+	return json.Marshal(entries)
+}
+
+// Merge implements the cluster.State interface.
+func (e *escalations) Merge(b []byte) error {
+	// Removed Alertmanagers of peers send empty states.
+	if len(b) == 0 {
+		return nil
+	}
+	var entries []escalationEntry
+	if err := json.Unmarshal(b, &entries); err != nil {
+		return err
+	}
+
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+	for _, entry := range entries {
+		if existing, ok := e.entries[entry.GroupKey]; !ok || entry.UpdatedAt.After(existing.UpdatedAt) {
+			e.entries[entry.GroupKey] = entry
+		}
+	}
+	return nil
+}
+
+// loadEscalations decodes a snapshot produced by MarshalBinary. An empty snapshot results in an empty state.
+func loadEscalations(r io.Reader) (*escalations, error) {
+	e := newEscalations()
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return e, nil
+	}
+	if err := e.Merge(b); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (e *escalations) get(groupKey string) (escalationEntry, bool) {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+	entry, ok := e.entries[groupKey]
+	return entry, ok
+}
+
+// set stores the entry and sends it to peers.
+func (e *escalations) set(entry escalationEntry) {
+	e.mtx.Lock()
+	e.entries[entry.GroupKey] = entry
+	broadcast := e.broadcast
+	e.mtx.Unlock()
+
+	b, err := json.Marshal([]escalationEntry{entry})
+	if err != nil {
+		return
+	}
+	broadcast(b)
+}
+
+// gc removes the entries that were not updated for longer than the retention.
+func (e *escalations) gc(now time.Time, retention time.Duration) int {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+
+	n := 0
+	for key, entry := range e.entries {
+		if now.Sub(entry.UpdatedAt) > retention {
+			delete(e.entries, key)
+			n++
+		}
+	}
+	return n
+}
+
+// escalationsMaintenance periodically garbage collects the escalation state and, if snapshot is not nil, snapshots it.
+// It runs until the Alertmanager is stopped, and takes a final snapshot on shutdown.
+func (am *GrafanaAlertmanager) escalationsMaintenance(frequency, retention time.Duration, snapshot func(State) (int64, error)) {
+	t := time.NewTicker(frequency)
+	defer t.Stop()
+
+	runMaintenance := func() {
+		start := time.Now()
+		am.escalations.gc(start, retention)
+		if snapshot == nil {
+			return
+		}
+		size, err := snapshot(am.escalations)
+		if err != nil {
+			level.Error(am.logger).Log("msg", "running escalations maintenance failed", "err", err)
+			return
+		}
+		level.Debug(am.logger).Log("msg", "escalations maintenance done", "duration", time.Since(start), "size", size)
+	}
+
+	for {
+		select {
+		case <-am.stopc:
+			runMaintenance()
+			return
+		case <-t.C:
+			runMaintenance()
+		}
+	}
+}
+
+// escalator holds what the escalation stages of all receivers share.
+type escalator struct {
+	// routePolicies are the escalation policies by route key.
+	routePolicies map[string]EscalationPolicy
+	// stages are the pipelines of the receivers of the levels, by receiver name.
+	stages map[string]notify.Stage
+	state  *escalations
+}
+
+// policy returns the escalation policy of the route of the alert group.
+func (e *escalator) policy(groupKey string) (EscalationPolicy, bool) {
+	var (
+		res     EscalationPolicy
+		found   bool
+		longest int
+	)
+	// A group key is the key of its route followed by its group labels.
+	for key, p := range e.routePolicies {
+		if strings.HasPrefix(groupKey, key+":") && len(key) >= longest {
+			res, found, longest = p, true, len(key)
+		}
+	}
+	return res, found
+}
+
+// escalationStage notifies a receiver and, while the alert group keeps firing, the receivers of the levels of the
+// escalation policy of its route. Levels are checked every time the alert group is flushed, so they are reached
+// with the precision of the group interval. Once reached, the receivers of a level get the notifications of the
+// alert group until it resolves, deduplicated by the notification log as usual. Acknowledged alert groups do not reach
+// new levels.
+type escalationStage struct {
+	primary   notify.Stage
+	escalator *escalator
+}
+
+func newEscalationStage(primary notify.Stage, e *escalator) *escalationStage {
+	return &escalationStage{
+		primary:   primary,
+		escalator: e,
+	}
+}
+
+// Exec implements the Stage interface.
+func (s *escalationStage) Exec(ctx context.Context, l log.Logger, alerts ...*types.Alert) (context.Context, []*types.Alert, error) {
+	ctx, sent, err := s.primary.Exec(ctx, l, alerts...)
+
+	groupKey, ok := notify.GroupKey(ctx)
+	if !ok {
+		return ctx, sent, err
+	}
+	policy, ok := s.escalator.policy(groupKey)
+	if !ok {
+		return ctx, sent, err
+	}
+	now, ok := notify.Now(ctx)
+	if !ok {
+		now = time.Now()
+	}
+
+	entry, ok := s.escalator.state.get(groupKey)
+	if !types.Alerts(alerts...).HasFiring() {
+		if ok && !entry.Resolved {
+			entry.Resolved = true
+			entry.UpdatedAt = now
+			s.escalator.state.set(entry)
+		}
+		return ctx, sent, err
+	}
+
+	changed := false
+	if !ok || entry.Resolved || entry.Policy != policy.Name {
+		entry = escalationEntry{GroupKey: groupKey, Policy: policy.Name, FiringSince: now}
+		changed = true
+	}
+
+	// Acknowledged alert groups do not reach new levels.
+	ack, acknowledged := templates.Acknowledgement(ctx)
+	acknowledged = acknowledged && ack != nil
+
+	var errs types.MultiError
+	for i, lvl := range policy.Levels {
+		if i >= entry.Level && (acknowledged || now.Sub(entry.FiringSince) < time.Duration(lvl.After)) {
+			break
+		}
+		if i >= entry.Level {
+			level.Info(l).Log("msg", "Escalating alert group", "aggrGroup", groupKey, "policy", policy.Name, "level", i+1, "receiver", lvl.Receiver)
+			entry.Level = i + 1
+			changed = true
+		}
+		if _, _, lerr := s.escalator.stages[lvl.Receiver].Exec(notify.WithReceiverName(ctx, lvl.Receiver), l, alerts...); lerr != nil {
+			errs.Add(fmt.Errorf("escalation level %d (%s): %w", i+1, lvl.Receiver, lerr))
+		}
+	}
+
+	if changed {
+		entry.UpdatedAt = now
+		s.escalator.state.set(entry)
+	}
+
+	if errs.Len() > 0 {
+		err = errors.Join(err, &errs)
+	}
+	return ctx, sent, err
+}
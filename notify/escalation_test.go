@@ -0,0 +1,218 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/alertmanager/config"
+	"github.com/prometheus/alertmanager/dispatch"
+	"github.com/prometheus/alertmanager/notify"
+	"github.com/prometheus/alertmanager/pkg/labels"
+	"github.com/prometheus/alertmanager/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/alerting/definition"
+)
+
+type escalationTestConfiguration struct {
+	testConfiguration
+	policies    []EscalationPolicy
+	grafanaTree *definition.Route
+}
+
+func (c *escalationTestConfiguration) EscalationPolicies() []EscalationPolicy { return c.policies }
+func (c *escalationTestConfiguration) GrafanaRoutingTree() *definition.Route  { return c.grafanaTree }
+
+func TestEscalationPolicies(t *testing.T) {
+	matchers := func(team string) config.Matchers {
+		m, err := labels.NewMatcher(labels.MatchEqual, "team", team)
+		require.NoError(t, err)
+		return config.Matchers{m}
+	}
+	policy := EscalationPolicy{Name: "policy", Levels: []EscalationLevel{{Receiver: "oncall", After: model.Duration(time.Minute)}}}
+	cfg := &escalationTestConfiguration{
+		testConfiguration: testConfiguration{
+			route: &Route{
+				Receiver: "default",
+				Routes: []*Route{{
+					Receiver: "team-a",
+					Matchers: matchers("a"),
+					Routes:   []*Route{{Receiver: "team-a", Matchers: matchers("a-child")}},
+				}, {
+					Receiver: "team-b",
+					Matchers: matchers("b"),
+				}},
+			},
+		},
+		policies: []EscalationPolicy{policy},
+		grafanaTree: &definition.Route{
+			Receiver: "default",
+			Routes: []*definition.Route{{
+				Receiver:         "team-a",
+				EscalationPolicy: "policy",
+				Routes:           []*definition.Route{{Receiver: "team-a"}},
+			}, {
+				Receiver: "team-b",
+			}},
+		},
+	}
+	integrationsMap := map[string][]*Integration{"default": nil, "team-a": nil, "team-b": nil, "oncall": nil}
+
+	route := dispatch.NewRoute(cfg.RoutingTree(), nil)
+	policies, err := escalationPolicies(cfg, route, integrationsMap)
+	require.NoError(t, err)
+	// Child routes inherit the escalation policy of their parent.
+	require.Equal(t, map[string]EscalationPolicy{
+		`{}/{team="a"}`:                  policy,
+		`{}/{team="a"}/{team="a-child"}`: policy,
+	}, policies)
+
+	cfg.grafanaTree.Routes[1].EscalationPolicy = "unknown"
+	_, err = escalationPolicies(cfg, route, integrationsMap)
+	require.ErrorContains(t, err, `escalation policy "unknown"`)
+
+	cfg.grafanaTree.Routes[1].EscalationPolicy = ""
+	delete(integrationsMap, "oncall")
+	_, err = escalationPolicies(cfg, route, integrationsMap)
+	require.ErrorContains(t, err, `receiver (oncall) of escalation policy "policy" is undefined`)
+}
+
+func TestEscalationStage(t *testing.T) {
+	primary, level1, level2 := &recordingStage{}, &recordingStage{}, &recordingStage{}
+	var broadcasts int
+	state := newEscalations()
+	state.SetBroadcast(func([]byte) { broadcasts++ })
+
+	e := &escalator{
+		routePolicies: map[string]EscalationPolicy{
+			`{}/{team="a"}`: {Name: "policy", Levels: []EscalationLevel{
+				{Receiver: "level1", After: model.Duration(10 * time.Minute)},
+				{Receiver: "level2", After: model.Duration(30 * time.Minute)},
+			}},
+		},
+		stages: map[string]notify.Stage{"level1": level1, "level2": level2},
+		state:  state,
+	}
+	stage := newEscalationStage(primary, e)
+
+	start := time.Now()
+	groupKey := `{}/{team="a"}:{alertname="test"}`
+	exec := func(groupKey string, now time.Time, resolved bool) {
+		alert := &types.Alert{Alert: model.Alert{
+			Labels:   model.LabelSet{"alertname": "test", "team": "a"},
+			StartsAt: start.Add(-time.Hour),
+			EndsAt:   now.Add(time.Hour),
+		}}
+		if resolved {
+			alert.EndsAt = time.Now().Add(-time.Second)
+		}
+		ctx := notify.WithGroupKey(context.Background(), groupKey)
+		ctx = notify.WithNow(ctx, now)
+		_, _, err := stage.Exec(ctx, log.NewNopLogger(), alert)
+		require.NoError(t, err)
+	}
+	calls := func(s *recordingStage) int {
+		keys, _ := s.calls()
+		return len(keys)
+	}
+
+	exec(groupKey, start, false)
+	require.Equal(t, 1, calls(primary))
+	require.Zero(t, calls(level1))
+
+	exec(groupKey, start.Add(10*time.Minute), false)
+	require.Equal(t, 1, calls(level1))
+	require.Zero(t, calls(level2))
+	entry, ok := state.get(groupKey)
+	require.True(t, ok)
+	require.Equal(t, 1, entry.Level)
+
+	// Reached levels keep getting the notifications of the alert group.
+	exec(groupKey, start.Add(30*time.Minute), false)
+	require.Equal(t, 2, calls(level1))
+	require.Equal(t, 1, calls(level2))
+
+	// The escalation is reset once the alert group resolves.
+	exec(groupKey, start.Add(40*time.Minute), true)
+	entry, _ = state.get(groupKey)
+	require.True(t, entry.Resolved)
+
+	exec(groupKey, start.Add(50*time.Minute), false)
+	entry, _ = state.get(groupKey)
+	require.False(t, entry.Resolved)
+	require.Zero(t, entry.Level)
+	require.Equal(t, start.Add(50*time.Minute), entry.FiringSince)
+	require.Equal(t, 2, calls(level1))
+
+	// Every change was sent to peers.
+	require.Equal(t, 5, broadcasts)
+
+	// Alert groups of routes without an escalation policy are not escalated.
+	exec(`{}:{alertname="test"}`, start.Add(time.Hour), false)
+	_, ok = state.get(`{}:{alertname="test"}`)
+	require.False(t, ok)
+}
+
+func TestEscalations_MergeAndSnapshot(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	a := newEscalations()
+	a.set(escalationEntry{GroupKey: "group-1", Policy: "policy", FiringSince: now, Level: 1, UpdatedAt: now})
+	a.set(escalationEntry{GroupKey: "group-2", Policy: "policy", FiringSince: now, UpdatedAt: now.Add(-time.Hour)})
+
+	b := newEscalations()
+	b.set(escalationEntry{GroupKey: "group-1", Policy: "policy", FiringSince: now, Level: 2, UpdatedAt: now.Add(time.Minute)})
+
+	// The most recently updated entry wins.
+	state, err := a.MarshalBinary()
+	require.NoError(t, err)
+	require.NoError(t, b.Merge(state))
+	entry, _ := b.get("group-1")
+	require.Equal(t, 2, entry.Level)
+	_, ok := b.get("group-2")
+	require.True(t, ok)
+
+	// Snapshots are restored.
+	state, err = b.MarshalBinary()
+	require.NoError(t, err)
+	restored, err := loadEscalations(bytes.NewReader(state))
+	require.NoError(t, err)
+	require.Equal(t, b.entries, restored.entries)
+
+	empty, err := loadEscalations(bytes.NewReader(nil))
+	require.NoError(t, err)
+	require.Empty(t, empty.entries)
+
+	// Entries that were not updated within the retention are garbage collected.
+	require.Equal(t, 1, restored.gc(now.Add(30*time.Minute), 45*time.Minute))
+	_, ok = restored.get("group-2")
+	require.False(t, ok)
+}
+
+func TestGrafanaAlertmanager_EscalationsOptional(t *testing.T) {
+	cfg := &GrafanaAlertmanagerConfig{
+		Silences: newFakeMaintanenceOptions(t),
+		Nflog:    newFakeMaintanenceOptions(t),
+	}
+	am, err := NewGrafanaAlertmanager("org", 1, cfg, &NilPeer{}, log.NewNopLogger(), NewGrafanaAlertmanagerMetrics(prometheus.NewPedanticRegistry(), log.NewNopLogger()))
+	require.NoError(t, err)
+	t.Cleanup(am.StopAndWait)
+
+	escalationCfg := &escalationTestConfiguration{
+		testConfiguration: testConfiguration{
+			receivers: []*APIReceiver{{ConfigReceiver: ConfigReceiver{Name: "default"}}, {ConfigReceiver: ConfigReceiver{Name: "oncall"}}},
+			route:     &Route{Receiver: "default"},
+		},
+		grafanaTree: &definition.Route{Receiver: "default"},
+	}
+	require.NoError(t, am.ApplyConfig(escalationCfg))
+
+	// Escalation policies are only used if the escalation state is persisted.
+	escalationCfg.policies = []EscalationPolicy{{Name: "policy", Levels: []EscalationLevel{{Receiver: "oncall", After: model.Duration(time.Minute)}}}}
+	escalationCfg.grafanaTree.EscalationPolicy = "policy"
+	require.EqualError(t, am.ApplyConfig(escalationCfg), "escalation policies require escalations maintenance options")
+}
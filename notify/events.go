@@ -0,0 +1,267 @@
+package notify
+
+import (
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/alertmanager/provider/mem"
+	"github.com/prometheus/alertmanager/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+)
+
+// DefaultEventBufferSize is the buffer size of subscriptions when none is given.
+const DefaultEventBufferSize = 100
+
+type EventType string
+
+const (
+	// EventAlertReceived is emitted for every alert received by PutAlerts.
+	EventAlertReceived EventType = "alert_received"
+	// EventAlertStateChanged is emitted when an alert becomes active, suppressed or resolved.
+	EventAlertStateChanged EventType = "alert_state_changed"
+	// EventSilenceCreated is emitted when a silence is created or updated through the Alertmanager.
+	EventSilenceCreated EventType = "silence_created"
+	// EventSilenceExpired is emitted when a silence is expired through the Alertmanager.
+	EventSilenceExpired EventType = "silence_expired"
+	// EventNotificationSent is emitted when an integration delivers a notification.
+	EventNotificationSent EventType = "notification_sent"
+	// EventNotificationFailed is emitted when an integration fails to deliver a notification after all its retries.
+	EventNotificationFailed EventType = "notification_failed"
+)
+
+// AlertStateResolved is the state of alert state changed events for alerts that are received resolved, and for alerts
+// that resolve because their end time passes, which are reported when the alert store garbage collects them. Other
+// states are the states of types.AlertState.
+const AlertStateResolved = "resolved"
+
+// Event is an event of the lifecycle of alerts, silences and notifications. Only the fields of its type are set.
+type Event struct {
+	Type      EventType `json:"type"`
+	Timestamp time.Time `json:"timestamp"`
+
+	// Fingerprint is the fingerprint of the alert of alert events.
+	Fingerprint string `json:"fingerprint,omitempty"`
+	// Labels are the labels of the alert of alert received events, and of resolved alerts.
+	Labels model.LabelSet `json:"labels,omitempty"`
+	// State is the new state of the alert of alert state changed events.
+	State string `json:"state,omitempty"`
+	// SilencedBy and InhibitedBy are the IDs of the silences and of the fingerprints of the alerts that suppress the
+	// alert, for alert state changed events.
+	SilencedBy  []string `json:"silencedBy,omitempty"`
+	InhibitedBy []string `json:"inhibitedBy,omitempty"`
+
+	// SilenceID is the ID of the silence of silence events.
+	SilenceID string `json:"silenceID,omitempty"`
+
+	// Notification is the outcome of notification events.
+	Notification *NotificationHistoryEntry `json:"notification,omitempty"`
+}
+
+// Subscription receives the events of an Alertmanager. Events are delivered through a buffered channel and are dropped
+// while the buffer is full, so that slow subscribers never block the Alertmanager.
+type Subscription struct {
+	c       chan Event
+	types   []EventType
+	dropped atomic.Uint64
+	bus     *eventBus
+}
+
+// Events returns the channel of the events. It is closed once the subscription is closed or the Alertmanager stops.
+func (s *Subscription) Events() <-chan Event {
+	return s.c
+}
+
+// Dropped returns the number of events that were dropped because the buffer was full.
+func (s *Subscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Close ends the subscription and closes its channel. It is safe to call more than once.
+func (s *Subscription) Close() {
+	s.bus.unsubscribe(s)
+}
+
+// Subscribe returns a subscription to the events of the given types, or of every type if none is given. Up to
+// bufferSize events are buffered, DefaultEventBufferSize if it is not positive. The subscription must be closed when it
+// is no longer needed.
+func (am *GrafanaAlertmanager) Subscribe(bufferSize int, eventTypes ...EventType) *Subscription {
+	if bufferSize <= 0 {
+		bufferSize = DefaultEventBufferSize
+	}
+	s := &Subscription{
+		c:     make(chan Event, bufferSize),
+		types: eventTypes,
+		bus:   am.events,
+	}
+	am.events.subscribe(s)
+	return s
+}
+
+// eventBus delivers events to subscriptions without blocking, and records them in the timeline of their alerts.
+type eventBus struct {
+	mtx      sync.RWMutex
+	subs     map[*Subscription]struct{}
+	closed   bool
+	dropped  prometheus.Counter
+	timeline *alertTimeline
+}
+
+func newEventBus(dropped prometheus.Counter, timeline *alertTimeline) *eventBus {
+	return &eventBus{
+		subs:     make(map[*Subscription]struct{}),
+		dropped:  dropped,
+		timeline: timeline,
+	}
+}
+
+func (b *eventBus) subscribe(s *Subscription) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	if b.closed {
+		close(s.c)
+		return
+	}
+	b.subs[s] = struct{}{}
+}
+
+func (b *eventBus) unsubscribe(s *Subscription) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	if _, ok := b.subs[s]; ok {
+		delete(b.subs, s)
+		close(s.c)
+	}
+}
+
+// close closes every subscription. Later subscriptions are closed right away.
+func (b *eventBus) close() {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	for s := range b.subs {
+		close(s.c)
+	}
+	b.subs = make(map[*Subscription]struct{})
+	b.closed = true
+}
+
+// publish delivers the event to every subscription of its type whose buffer is not full. It is a no-op on a nil bus.
+func (b *eventBus) publish(e Event) {
+	if b == nil {
+		return
+	}
+	if e.Timestamp.IsZero() {
+		e.Timestamp = time.Now()
+	}
+	b.timeline.record(e)
+
+	b.mtx.RLock()
+	defer b.mtx.RUnlock()
+	for s := range b.subs {
+		if len(s.types) > 0 && !slices.Contains(s.types, e.Type) {
+			continue
+		}
+		select {
+		case s.c <- e:
+		default:
+			s.dropped.Add(1)
+			b.dropped.Inc()
+		}
+	}
+}
+
+// eventMarker emits alert state changed events when the state of an alert changes.
+type eventMarker struct {
+	types.Marker
+	events *eventBus
+
+	// mtx makes reading the previous state, changing it and reading the new state atomic, so that concurrent changes
+	// by the silencer and the inhibitor emit every transition exactly once.
+	mtx sync.Mutex
+}
+
+// SetActiveOrSilenced implements the types.Marker interface.
+func (m *eventMarker) SetActiveOrSilenced(alert model.Fingerprint, version int, activeSilenceIDs, pendingSilenceIDs []string) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	prev := m.Marker.Status(alert).State
+	m.Marker.SetActiveOrSilenced(alert, version, activeSilenceIDs, pendingSilenceIDs)
+	m.stateChanged(alert, prev)
+}
+
+// SetInhibited implements the types.Marker interface.
+func (m *eventMarker) SetInhibited(alert model.Fingerprint, alertIDs ...string) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	prev := m.Marker.Status(alert).State
+	m.Marker.SetInhibited(alert, alertIDs...)
+	m.stateChanged(alert, prev)
+}
+
+// Delete implements the types.Marker interface.
+func (m *eventMarker) Delete(alert model.Fingerprint) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	m.Marker.Delete(alert)
+}
+
+// stateChanged emits an event if the state of the alert is no longer the previous state. It must be called with the
+// lock held.
+func (m *eventMarker) stateChanged(alert model.Fingerprint, prev types.AlertState) {
+	if status := m.Marker.Status(alert); status.State != prev {
+		m.events.publish(Event{
+			Type:        EventAlertStateChanged,
+			Fingerprint: alert.String(),
+			State:       string(status.State),
+			SilencedBy:  status.SilencedBy,
+			InhibitedBy: status.InhibitedBy,
+		})
+	}
+}
+
+// resolvedByTimeout returns whether the resolved alert resolved because its end time passed, rather than because it
+// was received resolved. The end time of alerts received resolved is never after the time they were received.
+func resolvedByTimeout(a *types.Alert) bool {
+	return a.EndsAt.After(a.UpdatedAt)
+}
+
+// eventCallback emits alert state changed events for alerts that resolve because their end time passes, when the alert
+// store garbage collects them. Alerts received resolved emit their event when they are received instead.
+type eventCallback struct {
+	mem.AlertStoreCallback
+	events *eventBus
+}
+
+func newEventCallback(callback mem.AlertStoreCallback, events *eventBus) *eventCallback {
+	if callback == nil {
+		callback = noopAlertStoreCallback{}
+	}
+	return &eventCallback{
+		AlertStoreCallback: callback,
+		events:             events,
+	}
+}
+
+// PostDelete implements the mem.AlertStoreCallback interface.
+func (c *eventCallback) PostDelete(alert *types.Alert) {
+	if resolvedByTimeout(alert) {
+		c.events.publish(Event{
+			Type:        EventAlertStateChanged,
+			Fingerprint: alert.Fingerprint().String(),
+			Labels:      alert.Labels,
+			State:       AlertStateResolved,
+		})
+	}
+	c.AlertStoreCallback.PostDelete(alert)
+}
+
+type noopAlertStoreCallback struct{}
+
+func (noopAlertStoreCallback) PreStore(_ *types.Alert, _ bool) error { return nil }
+func (noopAlertStoreCallback) PostStore(_ *types.Alert, _ bool)      {}
+func (noopAlertStoreCallback) PostDelete(_ *types.Alert)             {}
@@ -0,0 +1,233 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-openapi/strfmt"
+	amv2 "github.com/prometheus/alertmanager/api/v2/models"
+	"github.com/prometheus/alertmanager/featurecontrol"
+	"github.com/prometheus/alertmanager/notify"
+	"github.com/prometheus/alertmanager/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/alerting/notify/nfstatus"
+)
+
+func TestEventBus(t *testing.T) {
+	dropped := prometheus.NewCounter(prometheus.CounterOpts{Name: "dropped"})
+	bus := newEventBus(dropped, nil)
+	am := &GrafanaAlertmanager{events: bus}
+
+	slow := am.Subscribe(1)
+	silences := am.Subscribe(10, EventSilenceCreated, EventSilenceExpired)
+
+	bus.publish(Event{Type: EventAlertReceived, Fingerprint: "1"})
+	bus.publish(Event{Type: EventSilenceCreated, SilenceID: "a"})
+	bus.publish(Event{Type: EventSilenceExpired, SilenceID: "a"})
+
+	// Events are dropped once the buffer is full.
+	e := <-slow.Events()
+	require.Equal(t, EventAlertReceived, e.Type)
+	require.False(t, e.Timestamp.IsZero())
+	require.Equal(t, uint64(2), slow.Dropped())
+	require.Equal(t, 2.0, testutil.ToFloat64(dropped))
+
+	// Subscriptions only get the events of their types.
+	require.Len(t, silences.Events(), 2)
+	require.Equal(t, EventSilenceCreated, (<-silences.Events()).Type)
+	require.Equal(t, EventSilenceExpired, (<-silences.Events()).Type)
+	require.Zero(t, silences.Dropped())
+
+	// Closed subscriptions get no events.
+	silences.Close()
+	silences.Close()
+	_, ok := <-silences.Events()
+	require.False(t, ok)
+	bus.publish(Event{Type: EventSilenceCreated})
+	require.Equal(t, EventSilenceCreated, (<-slow.Events()).Type)
+
+	// Closing the bus closes every subscription.
+	bus.close()
+	_, ok = <-slow.Events()
+	require.False(t, ok)
+	_, ok = <-am.Subscribe(1).Events()
+	require.False(t, ok)
+}
+
+func TestSubscribe(t *testing.T) {
+	am, _ := setupAMTest(t)
+	cfg := &testConfiguration{
+		receivers: []*APIReceiver{{ConfigReceiver: ConfigReceiver{Name: "default"}}},
+		route:     &Route{Receiver: "default", GroupBy: []model.LabelName{"alertname"}},
+	}
+	require.NoError(t, am.ApplyConfig(cfg))
+
+	sub := am.Subscribe(100)
+	defer sub.Close()
+	next := func() Event {
+		select {
+		case e := <-sub.Events():
+			return e
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "no event")
+			return Event{}
+		}
+	}
+
+	now := time.Now()
+	alert := &PostableAlert{
+		Alert:    amv2.Alert{Labels: amv2.LabelSet{"alertname": "test"}},
+		StartsAt: strfmt.DateTime(now),
+		EndsAt:   strfmt.DateTime(now.Add(time.Hour)),
+	}
+	require.NoError(t, am.PutAlerts(amv2.PostableAlerts{alert}))
+	e := next()
+	require.Equal(t, EventAlertReceived, e.Type)
+	require.Equal(t, model.LabelSet{"alertname": "test"}, e.Labels)
+	fingerprint := e.Fingerprint
+
+	// The alert becomes active once it is evaluated by the silencer and the inhibitor, which the dispatcher and the API
+	// do.
+	_, err := am.GetAlerts(true, true, true, nil, "")
+	require.NoError(t, err)
+	e = next()
+	require.Equal(t, EventAlertStateChanged, e.Type)
+	require.Equal(t, fingerprint, e.Fingerprint)
+	require.Equal(t, string(types.AlertStateActive), e.State)
+
+	id, err := am.CreateSilence(testSilence(now, "alertname", "test"))
+	require.NoError(t, err)
+	e = next()
+	require.Equal(t, EventSilenceCreated, e.Type)
+	require.Equal(t, id, e.SilenceID)
+
+	// The state of the alert changes when it is evaluated again.
+	_, err = am.GetAlerts(true, true, true, nil, "")
+	require.NoError(t, err)
+	e = next()
+	require.Equal(t, EventAlertStateChanged, e.Type)
+	require.Equal(t, string(types.AlertStateSuppressed), e.State)
+
+	require.NoError(t, am.DeleteSilence(id))
+	e = next()
+	require.Equal(t, EventSilenceExpired, e.Type)
+	require.Equal(t, id, e.SilenceID)
+
+	alert.EndsAt = strfmt.DateTime(now)
+	require.NoError(t, am.PutAlerts(amv2.PostableAlerts{alert}))
+	require.Equal(t, EventAlertReceived, next().Type)
+	e = next()
+	require.Equal(t, EventAlertStateChanged, e.Type)
+	require.Equal(t, AlertStateResolved, e.State)
+}
+
+func TestNotificationHistoryStage_Events(t *testing.T) {
+	bus := newEventBus(prometheus.NewCounter(prometheus.CounterOpts{Name: "dropped"}), nil)
+	sub := (&GrafanaAlertmanager{events: bus}).Subscribe(10)
+	stageMetrics := notify.NewMetrics(prometheus.NewRegistry(), featurecontrol.NoopFlags{})
+	alert := &types.Alert{Alert: model.Alert{
+		Labels:   model.LabelSet{"alertname": "test"},
+		StartsAt: time.Now(),
+		EndsAt:   time.Now().Add(time.Hour),
+	}}
+
+	for _, n := range []notify.Notifier{&fakeNotifier{}, &fakeNotifierWithError{err: errors.New("unrecoverable")}} {
+		integration := nfstatus.NewIntegration(n, n.(notify.ResolvedSender), "webhook", 0, "receiver")
+		stage := newNotificationHistoryStage(notify.NewRetryStage(integration.Integration(), "receiver", stageMetrics), newNotificationHistory(10), bus, "receiver", integration)
+		_, _, _ = stage.Exec(notify.WithGroupKey(context.Background(), "group"), log.NewNopLogger(), alert)
+	}
+
+	e := <-sub.Events()
+	require.Equal(t, EventNotificationSent, e.Type)
+	require.Equal(t, "group", e.Notification.GroupKey)
+	require.Equal(t, NotificationStatusSuccess, e.Notification.Status)
+
+	e = <-sub.Events()
+	require.Equal(t, EventNotificationFailed, e.Type)
+	require.Contains(t, e.Notification.Error, "unrecoverable")
+}
+
+func TestEventMarker_Concurrent(t *testing.T) {
+	bus := newEventBus(prometheus.NewCounter(prometheus.CounterOpts{Name: "dropped"}), nil)
+	sub := (&GrafanaAlertmanager{events: bus}).Subscribe(10000)
+	marker := &eventMarker{Marker: types.NewMarker(prometheus.NewRegistry()), events: bus}
+	fp := model.Fingerprint(1)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if (i+j)%2 == 0 {
+					marker.SetInhibited(fp, "inhibitor")
+				} else {
+					marker.SetInhibited(fp)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	bus.close()
+
+	// Every transition is emitted exactly once, so consecutive events never have the same state, and the last event has
+	// the state of the alert.
+	var last string
+	for e := range sub.Events() {
+		require.NotEqual(t, last, e.State)
+		last = e.State
+	}
+	require.Zero(t, sub.Dropped())
+	require.Equal(t, string(marker.Status(fp).State), last)
+}
+
+func TestEventCallback(t *testing.T) {
+	bus := newEventBus(prometheus.NewCounter(prometheus.CounterOpts{Name: "dropped"}), nil)
+	sub := (&GrafanaAlertmanager{events: bus}).Subscribe(10)
+	next := &deleteRecordingCallback{}
+	callback := newEventCallback(next, bus)
+
+	now := time.Now()
+	timedOut := &types.Alert{
+		Alert:     model.Alert{Labels: model.LabelSet{"alertname": "timed-out"}, StartsAt: now.Add(-time.Hour), EndsAt: now.Add(-time.Minute)},
+		UpdatedAt: now.Add(-5 * time.Minute),
+	}
+	receivedResolved := &types.Alert{
+		Alert:     model.Alert{Labels: model.LabelSet{"alertname": "received-resolved"}, StartsAt: now.Add(-time.Hour), EndsAt: now.Add(-time.Minute)},
+		UpdatedAt: now.Add(-time.Minute),
+	}
+	callback.PostDelete(timedOut)
+	callback.PostDelete(receivedResolved)
+
+	// Only alerts that resolved because their end time passed emit an event, the others did when they were received.
+	require.Len(t, sub.Events(), 1)
+	e := <-sub.Events()
+	require.Equal(t, EventAlertStateChanged, e.Type)
+	require.Equal(t, AlertStateResolved, e.State)
+	require.Equal(t, timedOut.Fingerprint().String(), e.Fingerprint)
+	require.Equal(t, timedOut.Labels, e.Labels)
+
+	// The callback of the configuration is still called.
+	require.Equal(t, []*types.Alert{timedOut, receivedResolved}, next.deleted)
+
+	// The callback of the configuration is optional.
+	newEventCallback(nil, bus).PostDelete(timedOut)
+	require.Len(t, sub.Events(), 1)
+}
+
+type deleteRecordingCallback struct {
+	noopAlertStoreCallback
+	deleted []*types.Alert
+}
+
+func (c *deleteRecordingCallback) PostDelete(alert *types.Alert) {
+	c.deleted = append(c.deleted, alert)
+}
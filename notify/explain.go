@@ -0,0 +1,89 @@
+package notify
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/alertmanager/provider"
+	"github.com/prometheus/alertmanager/store"
+	"github.com/prometheus/common/model"
+)
+
+var (
+	ErrExplainAlertBadPayload  = fmt.Errorf("unable to explain alert")
+	ErrExplainAlertUnavailable = fmt.Errorf("unable to explain alert as alertmanager is not initialised yet")
+	ErrAlertNotFound           = fmt.Errorf("alert not found")
+)
+
+// AlertExplanation describes everything that suppresses, or would suppress, the notifications of an alert.
+type AlertExplanation struct {
+	Fingerprint string         `json:"fingerprint"`
+	Labels      model.LabelSet `json:"labels"`
+	// State is the state of the alert as of its last evaluation by the silencer and the inhibitor.
+	State string `json:"state"`
+	// Silences are the active silences that match the alert.
+	Silences GettableSilences `json:"silences"`
+	// Inhibitions are the inhibition rules that inhibit the alert, and the alerts that inhibit it through each rule.
+	Inhibitions []InhibitionMatch `json:"inhibitions"`
+	// Routes are the routes the alert is routed to, with their time intervals and whether they are muted.
+	Routes []RouteMatch `json:"routes"`
+}
+
+// Suppressed returns true if the alert is silenced, inhibited, or muted by time intervals on all of its routes.
+func (e *AlertExplanation) Suppressed() bool {
+	if len(e.Silences) > 0 || len(e.Inhibitions) > 0 {
+		return true
+	}
+	for _, r := range e.Routes {
+		if !r.Muted {
+			return false
+		}
+	}
+	return len(e.Routes) > 0
+}
+
+// ExplainAlert returns why notifications for the alert with the given fingerprint are suppressed. It returns ErrAlertNotFound
+// if there is no such alert.
+func (am *GrafanaAlertmanager) ExplainAlert(fingerprint string) (*AlertExplanation, error) {
+	fp, err := model.ParseFingerprint(fingerprint)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", err.Error(), ErrExplainAlertBadPayload)
+	}
+
+	am.reloadConfigMtx.RLock()
+	defer am.reloadConfigMtx.RUnlock()
+
+	if !am.ready() {
+		return nil, ErrExplainAlertUnavailable
+	}
+
+	alert, err := am.alerts.Get(fp)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) || errors.Is(err, provider.ErrNotFound) {
+			return nil, ErrAlertNotFound
+		}
+		level.Error(am.logger).Log("msg", "failed to get alert", "fingerprint", fingerprint, "err", err)
+		return nil, fmt.Errorf("%s: %w", ErrGetAlertsInternal.Error(), err)
+	}
+
+	routes, err := matchRoutes(am.route, am.timeIntervals, alert.Labels, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", ErrGetAlertsInternal.Error(), err)
+	}
+
+	silences, err := am.matchingSilences(alert.Labels)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AlertExplanation{
+		Fingerprint: fp.String(),
+		Labels:      alert.Labels,
+		State:       string(am.marker.Status(fp).State),
+		Silences:    silences,
+		Inhibitions: am.matchingInhibitions(alert.Labels),
+		Routes:      routes,
+	}, nil
+}
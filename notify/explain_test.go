@@ -0,0 +1,110 @@
+package notify
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-openapi/strfmt"
+	amv2 "github.com/prometheus/alertmanager/api/v2/models"
+	"github.com/prometheus/alertmanager/config"
+	"github.com/prometheus/alertmanager/pkg/labels"
+	"github.com/prometheus/alertmanager/timeinterval"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExplainAlert(t *testing.T) {
+	am, _ := setupAMTest(t)
+
+	matchers := func(name, value string) config.Matchers {
+		m, err := labels.NewMatcher(labels.MatchEqual, name, value)
+		require.NoError(t, err)
+		return config.Matchers{m}
+	}
+	cfg := &testConfiguration{
+		receivers: []*APIReceiver{
+			{ConfigReceiver: ConfigReceiver{Name: "default"}},
+			{ConfigReceiver: ConfigReceiver{Name: "team-a"}},
+		},
+		route: &Route{
+			Receiver: "default",
+			Routes: []*Route{{
+				Receiver:          "team-a",
+				Matchers:          matchers("team", "a"),
+				MuteTimeIntervals: []string{"always"},
+			}},
+		},
+		inhibitRules: []InhibitRule{{
+			SourceMatchers: matchers("severity", "critical"),
+			TargetMatchers: matchers("severity", "warning"),
+		}},
+		timeIntervals: []TimeInterval{{Name: "always", TimeIntervals: []timeinterval.TimeInterval{{}}}},
+	}
+	require.NoError(t, am.ApplyConfig(cfg))
+
+	now := time.Now()
+	postable := func(lbls amv2.LabelSet) *PostableAlert {
+		return &PostableAlert{
+			Alert:    amv2.Alert{Labels: lbls},
+			StartsAt: strfmt.DateTime(now),
+			EndsAt:   strfmt.DateTime(now.Add(time.Hour)),
+		}
+	}
+	require.NoError(t, am.PutAlerts(amv2.PostableAlerts{
+		postable(amv2.LabelSet{"alertname": "source", "severity": "critical"}),
+		postable(amv2.LabelSet{"alertname": "target", "severity": "warning", "team": "a"}),
+		postable(amv2.LabelSet{"alertname": "unsuppressed"}),
+	}))
+	_, err := am.CreateSilence(&PostableSilence{Silence: amv2.Silence{
+		Comment:   ptr("comment"),
+		CreatedBy: ptr("test"),
+		StartsAt:  ptr(strfmt.DateTime(now)),
+		EndsAt:    ptr(strfmt.DateTime(now.Add(time.Hour))),
+		Matchers:  amv2.Matchers{{Name: ptr("alertname"), Value: ptr("target"), IsEqual: ptr(true), IsRegex: ptr(false)}},
+	}})
+	require.NoError(t, err)
+
+	t.Run("suppressed alert", func(t *testing.T) {
+		fp := model.LabelSet{"alertname": "target", "severity": "warning", "team": "a"}.Fingerprint()
+		e, err := am.ExplainAlert(fp.String())
+		require.NoError(t, err)
+		require.True(t, e.Suppressed())
+		require.Equal(t, fp.String(), e.Fingerprint)
+
+		require.Len(t, e.Silences, 1)
+		require.Equal(t, "alertname", *e.Silences[0].Matchers[0].Name)
+		require.Equal(t, "target", *e.Silences[0].Matchers[0].Value)
+
+		require.Len(t, e.Inhibitions, 1)
+		require.Equal(t, cfg.inhibitRules[0], e.Inhibitions[0].Rule)
+		require.Equal(t, []InhibitingAlert{{
+			Fingerprint: model.LabelSet{"alertname": "source", "severity": "critical"}.Fingerprint().String(),
+			Labels:      model.LabelSet{"alertname": "source", "severity": "critical"},
+		}}, e.Inhibitions[0].SourceAlerts)
+
+		require.Len(t, e.Routes, 1)
+		require.Equal(t, "team-a", e.Routes[0].Receiver)
+		require.Equal(t, []TimeIntervalMatch{{Name: "always", Active: true}}, e.Routes[0].MuteTimeIntervals)
+		require.True(t, e.Routes[0].Muted)
+	})
+
+	t.Run("unsuppressed alert", func(t *testing.T) {
+		e, err := am.ExplainAlert(model.LabelSet{"alertname": "unsuppressed"}.Fingerprint().String())
+		require.NoError(t, err)
+		require.False(t, e.Suppressed())
+		require.Empty(t, e.Silences)
+		require.Empty(t, e.Inhibitions)
+		require.Len(t, e.Routes, 1)
+		require.False(t, e.Routes[0].Muted)
+	})
+
+	t.Run("unknown alert", func(t *testing.T) {
+		_, err := am.ExplainAlert(model.LabelSet{"alertname": "unknown"}.Fingerprint().String())
+		require.ErrorIs(t, err, ErrAlertNotFound)
+	})
+
+	t.Run("invalid fingerprint", func(t *testing.T) {
+		_, err := am.ExplainAlert("invalid")
+		require.ErrorIs(t, err, ErrExplainAlertBadPayload)
+	})
+}
@@ -59,7 +59,7 @@ func BuildReceiverIntegrations(
 			return logger("ngalert.notifier."+meta.Type, "notifierUID", meta.UID)
 		}
 		ci = func(idx int, cfg receivers.Metadata, n notificationChannel) {
-			i := NewIntegration(n, n, cfg.Type, idx, cfg.Name)
+			i := NewIntegrationWithUID(n, n, cfg.Type, idx, cfg.Name, cfg.UID)
 			integrations = append(integrations, i)
 		}
 		nw = func(cfg receivers.Metadata) receivers.WebhookSender {
@@ -0,0 +1,88 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/alertmanager/notify"
+	"github.com/prometheus/alertmanager/types"
+
+	"github.com/grafana/alerting/notify/nfstatus"
+)
+
+// DefaultFallbackTimeout is the most time kept for the fallback receiver when no fallback timeout is configured.
+const DefaultFallbackTimeout = time.Minute
+
+// validateFallbackReceivers checks that the fallback receiver of every receiver exists and is not the receiver itself.
+func validateFallbackReceivers(receivers []*APIReceiver, integrationsMap map[string][]*Integration) error {
+	for _, r := range receivers {
+		if r.FallbackReceiver == "" {
+			continue
+		}
+		if r.FallbackReceiver == r.Name {
+			return fmt.Errorf("receiver %q cannot be its own fallback receiver", r.Name)
+		}
+		if _, ok := integrationsMap[r.FallbackReceiver]; !ok {
+			return fmt.Errorf("fallback receiver %q of receiver %q does not exist", r.FallbackReceiver, r.Name)
+		}
+	}
+	return nil
+}
+
+// fallbackStage notifies the integrations of a receiver and, if all of them fail, notifies the integrations of its fallback receiver.
+// The fallback receiver is notified through its own pipeline, so the notification log records the notifications sent through it
+// under the fallback receiver and the group key of the alert group. The fallback receiver's own fallback is not used.
+type fallbackStage struct {
+	receiver     *nfstatus.Receiver
+	primary      notify.Stage
+	integrations int
+	fallback     notify.Stage
+	// timeout is the most time of the notification kept for the fallback receiver.
+	timeout time.Duration
+}
+
+func newFallbackStage(receiver *nfstatus.Receiver, primary notify.Stage, fallback notify.Stage, timeout time.Duration) *fallbackStage {
+	return &fallbackStage{
+		receiver:     receiver,
+		primary:      primary,
+		integrations: len(receiver.Integrations()),
+		fallback:     fallback,
+		timeout:      timeout,
+	}
+}
+
+// Exec implements the Stage interface.
+func (s *fallbackStage) Exec(ctx context.Context, l log.Logger, alerts ...*types.Alert) (context.Context, []*types.Alert, error) {
+	// The integrations retry until the context is done, so some of the remaining time is kept for the fallback: half of
+	// it, but no more than the fallback timeout.
+	primaryCtx := ctx
+	if deadline, ok := ctx.Deadline(); ok {
+		reserve := min(time.Until(deadline)/2, s.timeout)
+		var cancel context.CancelFunc
+		primaryCtx, cancel = context.WithDeadline(ctx, deadline.Add(-reserve))
+		defer cancel()
+	}
+
+	_, _, err := s.primary.Exec(primaryCtx, l, alerts...)
+	if err == nil {
+		return ctx, alerts, nil
+	}
+
+	// The fallback is only used when all the integrations failed.
+	var me *types.MultiError
+	if !errors.As(err, &me) || me.Len() < s.integrations {
+		return ctx, alerts, err
+	}
+
+	level.Warn(l).Log("msg", "All integrations of the receiver failed, notifying the fallback receiver", "receiver", s.receiver.Name(), "fallback", s.receiver.Fallback(), "err", err)
+	s.receiver.ReportFallback(time.Now())
+
+	if _, _, ferr := s.fallback.Exec(ctx, l, alerts...); ferr != nil {
+		return ctx, alerts, fmt.Errorf("%w; fallback receiver %s: %s", err, s.receiver.Fallback(), ferr)
+	}
+	return ctx, alerts, nil
+}
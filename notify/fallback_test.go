@@ -0,0 +1,194 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/alertmanager/nflog"
+	"github.com/prometheus/alertmanager/nflog/nflogpb"
+	"github.com/prometheus/alertmanager/notify"
+	"github.com/prometheus/alertmanager/types"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/alerting/notify/nfstatus"
+)
+
+type countingNotifier struct {
+	calls int
+}
+
+func (c *countingNotifier) Notify(_ context.Context, _ ...*types.Alert) (bool, error) {
+	c.calls++
+	return false, nil
+}
+
+func (c *countingNotifier) SendResolved() bool {
+	return true
+}
+
+func TestFallbackStage(t *testing.T) {
+	am, _ := setupAMTest(t)
+
+	alert := &types.Alert{Alert: model.Alert{
+		Labels:   model.LabelSet{"alertname": "test"},
+		StartsAt: time.Now(),
+		EndsAt:   time.Now().Add(time.Hour),
+	}}
+	newCtx := func(groupKey string) context.Context {
+		ctx := notify.WithGroupKey(context.Background(), groupKey)
+		ctx = notify.WithRepeatInterval(ctx, time.Hour)
+		ctx = notify.WithNow(ctx, time.Now())
+		return ctx
+	}
+	failing := &fakeNotifierWithError{err: errors.New("failed to notify")}
+	working := &fakeNotifier{}
+
+	cases := []struct {
+		name             string
+		primary          []notify.Notifier
+		fallbackNotifier *fakeNotifierWithError
+		expFallback      bool
+		expErr           bool
+	}{{
+		name:    "all integrations succeed",
+		primary: []notify.Notifier{working, working},
+	}, {
+		name:    "some integrations fail",
+		primary: []notify.Notifier{failing, working},
+		expErr:  true,
+	}, {
+		name:        "all integrations fail",
+		primary:     []notify.Notifier{failing, failing},
+		expFallback: true,
+	}, {
+		name:             "all integrations and the fallback fail",
+		primary:          []notify.Notifier{failing},
+		fallbackNotifier: failing,
+		expFallback:      true,
+		expErr:           true,
+	}}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			primaryIntegrations := make([]*Integration, 0, len(c.primary))
+			for i, n := range c.primary {
+				primaryIntegrations = append(primaryIntegrations, nfstatus.NewIntegration(n, &fakeNotifier{}, "webhook", i, "primary"))
+			}
+
+			var fallbackIntegration *Integration
+			fallbackNotifier := &countingNotifier{}
+			if c.fallbackNotifier != nil {
+				fallbackIntegration = nfstatus.NewIntegration(c.fallbackNotifier, c.fallbackNotifier, "email", 0, "fallback")
+			} else {
+				fallbackIntegration = nfstatus.NewIntegration(fallbackNotifier, fallbackNotifier, "email", 0, "fallback")
+			}
+
+			rcv := nfstatus.NewReceiver("primary", true, primaryIntegrations)
+			rcv.SetFallback("fallback")
+			stage := newFallbackStage(rcv,
+				am.createReceiverStage("primary", primaryIntegrations, receiverStageOptions{}, am.waitFunc, am.notificationLog),
+				am.createReceiverStage("fallback", []*Integration{fallbackIntegration}, receiverStageOptions{}, am.waitFunc, am.notificationLog),
+				DefaultFallbackTimeout,
+			)
+
+			groupKey := "group:" + c.name
+			_, _, err := stage.Exec(newCtx(groupKey), log.NewNopLogger(), alert)
+			if c.expErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+
+			if !c.expFallback {
+				require.Zero(t, fallbackNotifier.calls)
+				require.True(t, rcv.LastFallback().IsZero())
+				return
+			}
+			require.False(t, rcv.LastFallback().IsZero())
+			if c.fallbackNotifier != nil {
+				return
+			}
+			require.Equal(t, 1, fallbackNotifier.calls)
+
+			// The notification log records the notification sent through the fallback receiver.
+			entries, err := am.notificationLog.Query(nflog.QGroupKey(groupKey), nflog.QReceiver(&nflogpb.Receiver{GroupName: "fallback", Integration: "email", Idx: 0}))
+			require.NoError(t, err)
+			require.Len(t, entries, 1)
+
+			// The fallback receiver is not notified again while the alerts do not change.
+			_, _, err = stage.Exec(newCtx(groupKey), log.NewNopLogger(), alert)
+			require.NoError(t, err)
+			require.Equal(t, 1, fallbackNotifier.calls)
+		})
+	}
+}
+
+// deadlineStage records the deadline of the context it is executed with.
+type deadlineStage struct {
+	deadline time.Time
+}
+
+func (s *deadlineStage) Exec(ctx context.Context, _ log.Logger, alerts ...*types.Alert) (context.Context, []*types.Alert, error) {
+	s.deadline, _ = ctx.Deadline()
+	return ctx, alerts, nil
+}
+
+func TestFallbackStage_Deadline(t *testing.T) {
+	fn := &fakeNotifier{}
+	rcv := nfstatus.NewReceiver("primary", true, []*Integration{nfstatus.NewIntegration(fn, fn, "webhook", 0, "primary")})
+	rcv.SetFallback("fallback")
+
+	for _, c := range []struct {
+		name     string
+		timeout  time.Duration
+		remains  time.Duration
+		expEarly time.Duration
+	}{
+		{name: "fallback timeout is kept", timeout: time.Minute, remains: 10 * time.Minute, expEarly: time.Minute},
+		{name: "at most half of the time is kept", timeout: time.Minute, remains: time.Minute, expEarly: 30 * time.Second},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			primary := &deadlineStage{}
+			stage := newFallbackStage(rcv, primary, &deadlineStage{}, c.timeout)
+
+			deadline := time.Now().Add(c.remains)
+			ctx, cancel := context.WithDeadline(context.Background(), deadline)
+			defer cancel()
+			_, _, err := stage.Exec(ctx, log.NewNopLogger())
+			require.NoError(t, err)
+			require.WithinDuration(t, deadline.Add(-c.expEarly), primary.deadline, time.Second)
+		})
+	}
+}
+
+func TestGetReceivers_Fallback(t *testing.T) {
+	am, _ := setupAMTest(t)
+
+	cfg := &testConfiguration{
+		receivers: []*APIReceiver{{
+			ConfigReceiver: ConfigReceiver{Name: "primary"},
+			GrafanaIntegrations: GrafanaIntegrations{
+				Integrations:     []*GrafanaIntegrationConfig{{Type: "webhook"}},
+				FallbackReceiver: "fallback",
+			},
+		}, {
+			ConfigReceiver:      ConfigReceiver{Name: "fallback"},
+			GrafanaIntegrations: GrafanaIntegrations{Integrations: []*GrafanaIntegrationConfig{{Type: "email"}}},
+		}},
+		route: &Route{Receiver: "primary"},
+	}
+	require.NoError(t, am.ApplyConfig(cfg))
+
+	fallbacks := map[string]string{}
+	for _, r := range am.GetReceivers() {
+		fallbacks[r.Name] = r.FallbackReceiver
+	}
+	require.Equal(t, map[string]string{"primary": "fallback", "fallback": ""}, fallbacks)
+
+	cfg.receivers[0].FallbackReceiver = "unknown"
+	require.ErrorContains(t, am.ApplyConfig(cfg), `fallback receiver "unknown" of receiver "primary" does not exist`)
+}
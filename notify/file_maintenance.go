@@ -0,0 +1,288 @@
+package notify
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	// DefaultFileMaintenanceRetention is the retention of FileMaintenanceOptions when none is configured.
+	DefaultFileMaintenanceRetention = 120 * time.Hour
+	// DefaultFileMaintenanceFrequency is how often FileMaintenanceOptions write snapshots when no frequency is configured.
+	DefaultFileMaintenanceFrequency = 15 * time.Minute
+)
+
+// gzipMagic are the first bytes of gzip files. Snapshots are decompressed based on their content rather than on the
+// configuration, so that compression can be turned on and off without losing the state.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+type FileMaintenanceConfig struct {
+	// Path is the path of the snapshot file. Its directory must exist.
+	Path string
+	// Name identifies the snapshots in metrics and logs. Defaults to the base name of the path.
+	Name string
+	// Retention is for how long the artefacts under maintenance are kept. Defaults to DefaultFileMaintenanceRetention.
+	Retention time.Duration
+	// MaintenanceFrequency is how often snapshots are written. Defaults to DefaultFileMaintenanceFrequency.
+	MaintenanceFrequency time.Duration
+	// Compress compresses snapshots with gzip.
+	Compress bool
+	// Generations is the number of previous snapshots that are kept for rollback, as <path>.1 (the most recent) to
+	// <path>.<Generations>. Previous snapshots are also read when the current snapshot cannot be read.
+	Generations int
+}
+
+func (c *FileMaintenanceConfig) Validate() error {
+	if c.Path == "" {
+		return errors.New("snapshot file path must be present")
+	}
+
+	if c.Generations < 0 {
+		return errors.New("number of snapshot generations must not be negative")
+	}
+
+	return nil
+}
+
+type FileMaintenanceMetrics struct {
+	snapshots        *prometheus.CounterVec
+	snapshotsFailed  *prometheus.CounterVec
+	snapshotSize     *prometheus.GaugeVec
+	snapshotDuration *prometheus.HistogramVec
+}
+
+// NewFileMaintenanceMetrics creates the metrics of FileMaintenanceOptions. Metrics are labeled by the name of the
+// snapshots, so the same metrics can be shared by all the snapshots of an Alertmanager.
+func NewFileMaintenanceMetrics(r prometheus.Registerer) *FileMaintenanceMetrics {
+	return &FileMaintenanceMetrics{
+		snapshots: promauto.With(r).NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "state_snapshots_total",
+			Help:      "The total number of snapshots written to files.",
+		}, []string{"state"}),
+		snapshotsFailed: promauto.With(r).NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "state_snapshots_failed_total",
+			Help:      "The total number of snapshots that failed to be written to files.",
+		}, []string{"state"}),
+		snapshotSize: promauto.With(r).NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "state_snapshot_size_bytes",
+			Help:      "Size of the last snapshot file in bytes, after compression.",
+		}, []string{"state"}),
+		snapshotDuration: promauto.With(r).NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "state_snapshot_duration_seconds",
+			Help:      "Duration of writing snapshots to files in seconds.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"state"}),
+	}
+}
+
+// FileMaintenanceOptions are MaintenanceOptions that persist the state to a snapshot file. Snapshots are written
+// atomically: to a temporary file in the same directory, which is synced and then renamed to the snapshot file.
+type FileMaintenanceOptions struct {
+	config  FileMaintenanceConfig
+	metrics *FileMaintenanceMetrics
+	logger  log.Logger
+
+	// mtx serializes snapshots.
+	mtx sync.Mutex
+}
+
+// NewFileMaintenanceOptions creates FileMaintenanceOptions. Metrics are optional, they are not exported if not present.
+func NewFileMaintenanceOptions(config FileMaintenanceConfig, m *FileMaintenanceMetrics, logger log.Logger) (*FileMaintenanceOptions, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	if config.Name == "" {
+		config.Name = filepath.Base(config.Path)
+	}
+	if config.Retention <= 0 {
+		config.Retention = DefaultFileMaintenanceRetention
+	}
+	if config.MaintenanceFrequency <= 0 {
+		config.MaintenanceFrequency = DefaultFileMaintenanceFrequency
+	}
+	if m == nil {
+		m = NewFileMaintenanceMetrics(nil)
+	}
+
+	return &FileMaintenanceOptions{
+		config:  config,
+		metrics: m,
+		logger:  log.With(logger, "component", "file-maintenance", "state", config.Name),
+	}, nil
+}
+
+// InitialState returns the content of the snapshot file. If it does not exist or cannot be read, the most recent
+// previous snapshot that can be read is returned instead. The state is empty if there is no snapshot.
+func (o *FileMaintenanceOptions) InitialState() string {
+	for i := 0; i <= o.config.Generations; i++ {
+		path := o.generationPath(i)
+		b, err := readSnapshot(path)
+		if err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				level.Error(o.logger).Log("msg", "Failed to read snapshot", "file", path, "err", err)
+			}
+			continue
+		}
+		if i > 0 {
+			level.Warn(o.logger).Log("msg", "Restored the state from a previous snapshot", "file", path)
+		}
+		return string(b)
+	}
+	return ""
+}
+
+func (o *FileMaintenanceOptions) Retention() time.Duration {
+	return o.config.Retention
+}
+
+func (o *FileMaintenanceOptions) MaintenanceFrequency() time.Duration {
+	return o.config.MaintenanceFrequency
+}
+
+// MaintenanceFunc writes a snapshot of the state. It returns the size of the snapshot file in bytes.
+func (o *FileMaintenanceOptions) MaintenanceFunc(state State) (int64, error) {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+
+	start := time.Now()
+	size, err := o.snapshot(state)
+	o.metrics.snapshotDuration.WithLabelValues(o.config.Name).Observe(time.Since(start).Seconds())
+	o.metrics.snapshots.WithLabelValues(o.config.Name).Inc()
+	if err != nil {
+		o.metrics.snapshotsFailed.WithLabelValues(o.config.Name).Inc()
+		return 0, fmt.Errorf("unable to write snapshot %s: %w", o.config.Path, err)
+	}
+	o.metrics.snapshotSize.WithLabelValues(o.config.Name).Set(float64(size))
+	return size, nil
+}
+
+func (o *FileMaintenanceOptions) snapshot(state State) (int64, error) {
+	b, err := state.MarshalBinary()
+	if err != nil {
+		return 0, err
+	}
+
+	if o.config.Compress {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		if _, err := gz.Write(b); err != nil {
+			return 0, err
+		}
+		if err := gz.Close(); err != nil {
+			return 0, err
+		}
+		b = buf.Bytes()
+	}
+
+	dir := filepath.Dir(o.config.Path)
+	tmp, err := writeTempFile(dir, filepath.Base(o.config.Path)+".tmp-*", b)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := o.rotate(); err != nil {
+		_ = os.Remove(tmp)
+		return 0, err
+	}
+	if err := os.Rename(tmp, o.config.Path); err != nil {
+		_ = os.Remove(tmp)
+		return 0, err
+	}
+	if err := syncDir(dir); err != nil {
+		return 0, err
+	}
+
+	return int64(len(b)), nil
+}
+
+// rotate shifts the previous snapshots by one generation, dropping the oldest one, and makes the current snapshot the
+// most recent previous snapshot.
+func (o *FileMaintenanceOptions) rotate() error {
+	for i := o.config.Generations; i > 0; i-- {
+		if err := os.Rename(o.generationPath(i-1), o.generationPath(i)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// generationPath returns the path of the snapshot of the generation, where 0 is the current snapshot.
+func (o *FileMaintenanceOptions) generationPath(generation int) string {
+	if generation == 0 {
+		return o.config.Path
+	}
+	return fmt.Sprintf("%s.%d", o.config.Path, generation)
+}
+
+// writeTempFile writes the content to a new temporary file in the directory and syncs it. It returns the path of the
+// file.
+func writeTempFile(dir, pattern string, b []byte) (string, error) {
+	f, err := os.CreateTemp(dir, pattern)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = f.Write(b)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// syncDir syncs the directory, so that renames in it are durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	err = d.Sync()
+	if closeErr := d.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// readSnapshot reads the snapshot file, decompressing it if it is compressed.
+func readSnapshot(path string) ([]byte, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(b, gzipMagic) {
+		return b, nil
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+	return io.ReadAll(gz)
+}
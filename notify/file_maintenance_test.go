@@ -0,0 +1,116 @@
+package notify
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+type rawState []byte
+
+func (s rawState) MarshalBinary() ([]byte, error) { return s, nil }
+
+func TestFileMaintenanceOptions(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "silences")
+	m := NewFileMaintenanceMetrics(prometheus.NewPedanticRegistry())
+	o, err := NewFileMaintenanceOptions(FileMaintenanceConfig{
+		Path:                 path,
+		Retention:            time.Hour,
+		MaintenanceFrequency: time.Minute,
+	}, m, log.NewNopLogger())
+	require.NoError(t, err)
+	require.Equal(t, time.Hour, o.Retention())
+	require.Equal(t, time.Minute, o.MaintenanceFrequency())
+
+	defaults, err := NewFileMaintenanceOptions(FileMaintenanceConfig{Path: path}, nil, log.NewNopLogger())
+	require.NoError(t, err)
+	require.Equal(t, DefaultFileMaintenanceRetention, defaults.Retention())
+	require.Equal(t, DefaultFileMaintenanceFrequency, defaults.MaintenanceFrequency())
+
+	// There is no snapshot yet.
+	require.Empty(t, o.InitialState())
+
+	size, err := o.MaintenanceFunc(rawState("state"))
+	require.NoError(t, err)
+	require.Equal(t, int64(5), size)
+	require.Equal(t, "state", o.InitialState())
+	require.Equal(t, 5.0, testutil.ToFloat64(m.snapshotSize.WithLabelValues("silences")))
+	require.Equal(t, 1.0, testutil.ToFloat64(m.snapshots.WithLabelValues("silences")))
+
+	// Temporary files are renamed to the snapshot file.
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+
+	// States that cannot be marshaled do not replace the snapshot.
+	_, err = o.MaintenanceFunc(failingState{})
+	require.Error(t, err)
+	require.Equal(t, 1.0, testutil.ToFloat64(m.snapshotsFailed.WithLabelValues("silences")))
+	require.Equal(t, "state", o.InitialState())
+}
+
+type failingState struct{}
+
+func (failingState) MarshalBinary() ([]byte, error) { return nil, os.ErrInvalid }
+
+func TestFileMaintenanceOptions_Compress(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nflog")
+	o, err := NewFileMaintenanceOptions(FileMaintenanceConfig{Path: path, Compress: true}, nil, log.NewNopLogger())
+	require.NoError(t, err)
+
+	state := rawState(make([]byte, 1024))
+	size, err := o.MaintenanceFunc(state)
+	require.NoError(t, err)
+
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, gzipMagic, b[:2])
+	require.Equal(t, int64(len(b)), size)
+	require.Less(t, size, int64(len(state)))
+	require.Equal(t, string(state), o.InitialState())
+
+	// Compressed snapshots can be read when compression is turned off.
+	o, err = NewFileMaintenanceOptions(FileMaintenanceConfig{Path: path}, nil, log.NewNopLogger())
+	require.NoError(t, err)
+	require.Equal(t, string(state), o.InitialState())
+}
+
+func TestFileMaintenanceOptions_Generations(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "silences")
+	o, err := NewFileMaintenanceOptions(FileMaintenanceConfig{Path: path, Generations: 2}, nil, log.NewNopLogger())
+	require.NoError(t, err)
+
+	for _, s := range []string{"a", "b", "c", "d"} {
+		_, err := o.MaintenanceFunc(rawState(s))
+		require.NoError(t, err)
+	}
+
+	for file, expected := range map[string]string{path: "d", path + ".1": "c", path + ".2": "b"} {
+		b, err := os.ReadFile(file)
+		require.NoError(t, err)
+		require.Equal(t, expected, string(b))
+	}
+	_, err = os.Stat(path + ".3")
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	// The most recent previous snapshot that can be read is restored if the snapshot cannot be read.
+	require.NoError(t, os.WriteFile(path, append(gzipMagic, 0), 0o600))
+	require.Equal(t, "c", o.InitialState())
+	require.NoError(t, os.Remove(path))
+	require.Equal(t, "c", o.InitialState())
+}
+
+func TestFileMaintenanceConfig_Validate(t *testing.T) {
+	_, err := NewFileMaintenanceOptions(FileMaintenanceConfig{}, nil, log.NewNopLogger())
+	require.EqualError(t, err, "snapshot file path must be present")
+
+	_, err = NewFileMaintenanceOptions(FileMaintenanceConfig{Path: "silences", Generations: -1}, nil, log.NewNopLogger())
+	require.EqualError(t, err, "number of snapshot generations must not be negative")
+}
@@ -19,6 +19,7 @@ import (
 	"golang.org/x/sync/errgroup"
 
 	"github.com/grafana/alerting/cluster"
+	"github.com/grafana/alerting/definition"
 	"github.com/grafana/alerting/notify/nfstatus"
 	amv2 "github.com/prometheus/alertmanager/api/v2/models"
 	"github.com/prometheus/alertmanager/config"
@@ -29,6 +30,7 @@ import (
 	"github.com/prometheus/alertmanager/nflog"
 	"github.com/prometheus/alertmanager/nflog/nflogpb"
 	"github.com/prometheus/alertmanager/notify"
+	"github.com/prometheus/alertmanager/provider"
 	"github.com/prometheus/alertmanager/provider/mem"
 	"github.com/prometheus/alertmanager/silence"
 	"github.com/prometheus/alertmanager/template"
@@ -65,6 +67,11 @@ type ClusterPeer interface {
 	WaitReady(context.Context) error
 }
 
+// stateRemovingPeer is implemented by cluster peers that can remove the states they replicate.
+type stateRemovingPeer interface {
+	RemoveState(key string)
+}
+
 type GrafanaAlertmanager struct {
 	logger  log.Logger
 	Metrics *GrafanaAlertmanagerMetrics
@@ -72,22 +79,61 @@ type GrafanaAlertmanager struct {
 	tenantID int64
 
 	marker      types.Marker
-	alerts      *mem.Alerts
+	alerts      AlertStore
 	route       *dispatch.Route
 	peer        ClusterPeer
 	peerTimeout time.Duration
+	// stateKeys are the keys of the states the Alertmanager replicates through the peer.
+	stateKeys []string
 
 	// wg is for dispatcher, inhibitor, silences and notifications
 	// Across configuration changes dispatcher and inhibitor are completely replaced, however, silences, notification log and alerts remain the same.
 	// stopc is used to let silences and notifications know we are done.
 	wg    sync.WaitGroup
 	stopc chan struct{}
-
-	notificationLog *nflog.Log
-	dispatcher      *dispatch.Dispatcher
-	inhibitor       *inhibit.Inhibitor
-	silencer        *silence.Silencer
-	silences        *silence.Silences
+	// reloadc is closed when the configuration is replaced, to let the stages of the previous configuration that send
+	// notifications in the background know that they are no longer in use.
+	reloadc chan struct{}
+
+	notificationLog     *nflog.Log
+	notificationHistory *notificationHistory
+	// deliveryDelay is shared by the integrations of all the configurations, so that repeated notifications are not
+	// observed as first notifications after a reload.
+	deliveryDelay *nfstatus.DeliveryDelayTracker
+	// events delivers the events of the Alertmanager to subscriptions.
+	events      *eventBus
+	timeline    *alertTimeline
+	escalations *escalations
+	// escalationsPersisted is whether the escalation state is snapshotted, which escalation policies require.
+	escalationsPersisted bool
+	acknowledgements     *acknowledgements
+	// circuitBreaker configures the circuit breaker of every integration. If nil, integrations have no circuit breaker.
+	circuitBreaker *CircuitBreakerConfig
+	dispatcher     *dispatch.Dispatcher
+	inhibitor      *inhibit.Inhibitor
+	// dispatcherStarted and inhibitorStarted are closed once the dispatcher and the inhibitor start running, and
+	// dispatcherDone and inhibitorDone once they stop running.
+	dispatcherStarted chan struct{}
+	inhibitorStarted  chan struct{}
+	dispatcherDone    chan struct{}
+	inhibitorDone     chan struct{}
+	silencer          *silence.Silencer
+	silences          *silence.Silences
+	// recurringSilences are materialized into silences ahead of time by recurringSilencesLookahead.
+	recurringSilences          *recurringSilences
+	recurringSilencesLookahead time.Duration
+	// materializeMtx serializes the materialization of recurring silences.
+	materializeMtx sync.Mutex
+	silencePolicy  SilencePolicy
+	// silencePreviewWarningFraction is the fraction of active alerts above which silence previews warn.
+	silencePreviewWarningFraction float64
+
+	// upstreamIntegrations configures the upstream integrations of receivers. If nil, they are not built.
+	upstreamIntegrations *UpstreamIntegrationsConfig
+	// fallbackTimeout is the most time of a notification kept for fallback receivers.
+	fallbackTimeout time.Duration
+	// rateLimiters are the rate limiters of the current configuration.
+	rateLimiters rateLimiters
 
 	// timeIntervals is the set of all time_intervals and mute_time_intervals from
 	// the configuration.
@@ -99,7 +145,15 @@ type GrafanaAlertmanager struct {
 	reloadConfigMtx sync.RWMutex
 	configHash      [16]byte
 	config          []byte
+	configAppliedAt time.Time
 	receivers       []*nfstatus.Receiver
+	// apiReceivers and inhibitRules are the receivers and inhibition rules of the current configuration.
+	apiReceivers []*APIReceiver
+	inhibitRules []InhibitRule
+	// lastApplyConfigError is the error of the last call to ApplyConfig, nil if it succeeded.
+	lastApplyConfigError   error
+	lastApplyConfigErrorAt time.Time
+	startedAt              time.Time
 
 	// buildReceiverIntegrationsFunc builds the integrations for a receiver based on its APIReceiver configuration and the current parsed template.
 	buildReceiverIntegrationsFunc func(next *APIReceiver, tmpl *templates.Template) ([]*Integration, error)
@@ -109,13 +163,13 @@ type GrafanaAlertmanager struct {
 	templates []templates.TemplateDefinition
 }
 
-// State represents any of the two 'states' of the alertmanager. Notification log or Silences.
-// MarshalBinary returns the binary representation of this internal state based on the protobuf.
+// State represents any of the 'states' of the alertmanager. Notification log, Silences or Alerts.
+// MarshalBinary returns the binary representation of this internal state.
 type State interface {
 	MarshalBinary() ([]byte, error)
 }
 
-// MaintenanceOptions represent the configuration options available for executing maintenance of Silences and the Notification log that the Alertmanager uses.
+// MaintenanceOptions represent the configuration options available for executing maintenance of Silences, the Notification log and Alerts that the Alertmanager uses.
 type MaintenanceOptions interface {
 	// InitialState returns the initial snapshot of the artefacts under maintenance. This will be loaded when the Alertmanager starts.
 	InitialState() string
@@ -130,11 +184,16 @@ type MaintenanceOptions interface {
 
 var NewIntegration = nfstatus.NewIntegration
 
+var NewIntegrationWithUID = nfstatus.NewIntegrationWithUID
+
 type InhibitRule = config.InhibitRule
 type MuteTimeInterval = config.MuteTimeInterval
 type TimeInterval = config.TimeInterval
 type Route = config.Route
 type Integration = nfstatus.Integration
+type CircuitBreakerConfig = nfstatus.CircuitBreakerConfig
+type RateLimit = definition.RateLimit
+type Digest = definition.Digest
 type DispatcherLimits = dispatch.Limits
 type Notifier = notify.Notifier
 
@@ -161,6 +220,8 @@ type Configuration interface {
 type Limits struct {
 	MaxSilences         int
 	MaxSilenceSizeBytes int
+	// SilencePolicy restricts the silences that can be created.
+	SilencePolicy SilencePolicy
 }
 
 type GrafanaAlertmanagerConfig struct {
@@ -168,8 +229,56 @@ type GrafanaAlertmanagerConfig struct {
 	AlertStoreCallback mem.AlertStoreCallback
 	PeerTimeout        time.Duration
 
+	// AlertStore creates the storage for alerts. If nil, alerts are kept in memory.
+	AlertStore AlertStoreFactory
+
 	Silences MaintenanceOptions
 	Nflog    MaintenanceOptions
+	// Alerts is optional. If present, active alerts are periodically snapshotted and restored on startup.
+	Alerts MaintenanceOptions
+	// Escalations is optional. If present, the escalation state of alert groups is periodically snapshotted and restored
+	// on startup, so that escalations carry on where they were after a restart. It must be present for configurations
+	// with escalation policies. Otherwise, the escalation state is kept in memory and garbage collected with the
+	// retention of the notification log.
+	Escalations MaintenanceOptions
+	// Acknowledgements is optional. If present, the acknowledgements of alert groups are periodically snapshotted and
+	// restored on startup, so that acknowledged alert groups stay acknowledged after a restart. Acknowledgements are
+	// kept for the retention after they expire. Otherwise, they are kept in memory with the retention of the
+	// notification log.
+	Acknowledgements MaintenanceOptions
+	// RecurringSilences is optional. If present, recurring silences are periodically snapshotted and restored on startup.
+	// Otherwise, they are maintained with the frequency and retention of silences.
+	RecurringSilences MaintenanceOptions
+	// RecurringSilencesLookahead is how far ahead recurring silences are materialized. Defaults to
+	// DefaultRecurringSilencesLookahead.
+	RecurringSilencesLookahead time.Duration
+
+	// SilencePreviewWarningFraction is the fraction of active alerts above which PreviewSilence warns that a silence is
+	// too broad. Defaults to DefaultSilencePreviewWarningFraction.
+	SilencePreviewWarningFraction float64
+
+	// NotificationHistorySize is the maximum number of entries kept in the notification history. Defaults to 1000.
+	NotificationHistorySize int
+
+	// FallbackTimeout is the most time of a notification that is kept for the fallback receiver of a receiver, so that
+	// its integrations get the rest of the time to retry. Defaults to DefaultFallbackTimeout.
+	FallbackTimeout time.Duration
+
+	// AlertTimelineSize is the maximum number of events kept in the timeline of every alert. Defaults to
+	// DefaultAlertTimelineSize. Events are kept for the retention of the notification log.
+	AlertTimelineSize int
+
+	// UpstreamIntegrations is optional. If present, the upstream Alertmanager configurations of receivers, such as
+	// email_configs and webhook_configs, are built with the upstream notifiers and run next to the Grafana integrations
+	// of the configuration. Otherwise, they are ignored. Configurations that mix both must be loaded with
+	// definition.LoadWithMixedReceiverTypes.
+	UpstreamIntegrations *UpstreamIntegrationsConfig
+
+	// CircuitBreaker is optional. If present, every integration has a circuit breaker that makes notifications fail fast,
+	// or go to the fallback receiver if one is configured, while the integration keeps failing. The state of the circuit
+	// breaker of a Grafana integration is kept across configuration changes as long as its configuration is unchanged.
+	// The circuit breakers of other integrations, and of integrations that changed, start closed.
+	CircuitBreaker *CircuitBreakerConfig
 
 	Limits Limits
 }
@@ -190,17 +299,38 @@ func (c *GrafanaAlertmanagerConfig) Validate() error {
 func NewGrafanaAlertmanager(tenantKey string, tenantID int64, config *GrafanaAlertmanagerConfig, peer ClusterPeer, logger log.Logger, m *GrafanaAlertmanagerMetrics) (*GrafanaAlertmanager, error) {
 	// TODO: Remove the context.
 	am := &GrafanaAlertmanager{
-		stopc:             make(chan struct{}),
-		logger:            log.With(logger, "component", "alertmanager", tenantKey, tenantID),
-		marker:            types.NewMarker(m.Registerer),
-		stageMetrics:      notify.NewMetrics(m.Registerer, featurecontrol.NoopFlags{}),
-		dispatcherMetrics: dispatch.NewDispatcherMetrics(false, m.Registerer),
-		peer:              peer,
-		peerTimeout:       config.PeerTimeout,
-		Metrics:           m,
-		tenantID:          tenantID,
-		externalURL:       config.ExternalURL,
+		stopc:               make(chan struct{}),
+		reloadc:             make(chan struct{}),
+		startedAt:           time.Now(),
+		logger:              log.With(logger, "component", "alertmanager", tenantKey, tenantID),
+		stageMetrics:        notify.NewMetrics(m.Registerer, featurecontrol.NoopFlags{}),
+		dispatcherMetrics:   dispatch.NewDispatcherMetrics(false, m.Registerer),
+		peer:                peer,
+		peerTimeout:         config.PeerTimeout,
+		Metrics:             m,
+		tenantID:            tenantID,
+		externalURL:         config.ExternalURL,
+		notificationHistory: newNotificationHistory(config.NotificationHistorySize),
+		timeline:            newAlertTimeline(config.AlertTimelineSize),
+		circuitBreaker:      config.CircuitBreaker,
+
+		recurringSilencesLookahead:    config.RecurringSilencesLookahead,
+		silencePreviewWarningFraction: config.SilencePreviewWarningFraction,
+		silencePolicy:                 config.Limits.SilencePolicy,
+		upstreamIntegrations:          config.UpstreamIntegrations,
+		fallbackTimeout:               config.FallbackTimeout,
+	}
+	if am.recurringSilencesLookahead <= 0 {
+		am.recurringSilencesLookahead = DefaultRecurringSilencesLookahead
 	}
+	if am.silencePreviewWarningFraction <= 0 {
+		am.silencePreviewWarningFraction = DefaultSilencePreviewWarningFraction
+	}
+	if am.fallbackTimeout <= 0 {
+		am.fallbackTimeout = DefaultFallbackTimeout
+	}
+	am.events = newEventBus(m.eventsDropped.WithLabelValues(am.tenantString()), am.timeline)
+	am.marker = &eventMarker{Marker: types.NewMarker(m.Registerer), events: am.events}
 
 	if err := config.Validate(); err != nil {
 		return nil, err
@@ -233,12 +363,86 @@ func NewGrafanaAlertmanager(tenantKey string, tenantID int64, config *GrafanaAle
 	if err != nil {
 		return nil, fmt.Errorf("unable to initialize the notification log component of alerting: %w", err)
 	}
-	c := am.peer.AddState(fmt.Sprintf("notificationlog:%d", am.tenantID), am.notificationLog, m.Registerer)
+	c := am.addState(fmt.Sprintf("notificationlog:%d", am.tenantID), am.notificationLog, m.Registerer)
 	am.notificationLog.SetBroadcast(c.Broadcast)
+	am.deliveryDelay = nfstatus.NewDeliveryDelayTracker(am.notificationLog)
 
-	c = am.peer.AddState(fmt.Sprintf("silences:%d", am.tenantID), am.silences, m.Registerer)
+	c = am.addState(fmt.Sprintf("silences:%d", am.tenantID), am.silences, m.Registerer)
 	am.silences.SetBroadcast(c.Broadcast)
 
+	// Initialize the recurring silences
+	recurringSilencesOpts := config.Silences
+	var snapshotRecurringSilences func(State) (int64, error)
+	if config.RecurringSilences != nil {
+		recurringSilencesOpts = config.RecurringSilences
+		snapshotRecurringSilences = config.RecurringSilences.MaintenanceFunc
+		am.recurringSilences, err = loadRecurringSilences(strings.NewReader(config.RecurringSilences.InitialState()))
+		if err != nil {
+			return nil, fmt.Errorf("unable to decode the recurring silences snapshot: %w", err)
+		}
+	} else {
+		am.recurringSilences = newRecurringSilences()
+	}
+	c = am.addState(fmt.Sprintf("recurringsilences:%d", am.tenantID), am.recurringSilences, m.Registerer)
+	am.recurringSilences.SetBroadcast(c.Broadcast)
+
+	// Initialize the escalation state
+	escalationsOpts := config.Nflog
+	var snapshotEscalations func(State) (int64, error)
+	if config.Escalations != nil {
+		escalationsOpts = config.Escalations
+		snapshotEscalations = config.Escalations.MaintenanceFunc
+		am.escalations, err = loadEscalations(strings.NewReader(config.Escalations.InitialState()))
+		if err != nil {
+			return nil, fmt.Errorf("unable to decode the escalations snapshot: %w", err)
+		}
+	} else {
+		am.escalations = newEscalations()
+	}
+	am.escalationsPersisted = config.Escalations != nil
+	c = am.addState(fmt.Sprintf("escalations:%d", am.tenantID), am.escalations, m.Registerer)
+	am.escalations.SetBroadcast(c.Broadcast)
+
+	// Initialize the acknowledgements
+	acknowledgementsOpts := config.Nflog
+	var snapshotAcknowledgements func(State) (int64, error)
+	if config.Acknowledgements != nil {
+		acknowledgementsOpts = config.Acknowledgements
+		snapshotAcknowledgements = config.Acknowledgements.MaintenanceFunc
+		am.acknowledgements, err = loadAcknowledgements(strings.NewReader(config.Acknowledgements.InitialState()))
+		if err != nil {
+			return nil, fmt.Errorf("unable to decode the acknowledgements snapshot: %w", err)
+		}
+	} else {
+		am.acknowledgements = newAcknowledgements()
+	}
+	c = am.addState(fmt.Sprintf("acknowledgements:%d", am.tenantID), am.acknowledgements, m.Registerer)
+	am.acknowledgements.SetBroadcast(c.Broadcast)
+
+	am.wg.Add(1)
+	go func() {
+		am.escalationsMaintenance(escalationsOpts.MaintenanceFrequency(), escalationsOpts.Retention(), snapshotEscalations)
+		am.wg.Done()
+	}()
+
+	am.wg.Add(1)
+	go func() {
+		am.acknowledgementsMaintenance(acknowledgementsOpts.MaintenanceFrequency(), acknowledgementsOpts.Retention(), snapshotAcknowledgements)
+		am.wg.Done()
+	}()
+
+	am.wg.Add(1)
+	go func() {
+		am.recurringSilencesMaintenance(recurringSilencesOpts.MaintenanceFrequency(), recurringSilencesOpts.Retention(), snapshotRecurringSilences)
+		am.wg.Done()
+	}()
+
+	am.wg.Add(1)
+	go func() {
+		am.alertTimelineMaintenance(config.Nflog.MaintenanceFrequency(), config.Nflog.Retention())
+		am.wg.Done()
+	}()
+
 	am.wg.Add(1)
 	go func() {
 		am.notificationLog.Maintenance(config.Nflog.MaintenanceFrequency(), snapshotPlaceholder, am.stopc, func() (int64, error) {
@@ -266,12 +470,32 @@ func NewGrafanaAlertmanager(tenantKey string, tenantID int64, config *GrafanaAle
 		am.wg.Done()
 	}()
 
-	// Initialize in-memory alerts
-	am.alerts, err = mem.NewAlerts(context.Background(), am.marker, memoryAlertsGCInterval, config.AlertStoreCallback, am.logger, m.Registerer)
+	// Initialize the alert store, in-memory by default.
+	newAlertStore := config.AlertStore
+	if newAlertStore == nil {
+		newAlertStore = NewMemAlertStore
+	}
+	am.alerts, err = newAlertStore(am.marker, newEventCallback(config.AlertStoreCallback, am.events), am.logger, m.Registerer)
 	if err != nil {
 		return nil, fmt.Errorf("unable to initialize the alert provider component of alerting: %w", err)
 	}
 
+	if config.Alerts != nil {
+		st, err := DecodeAlertsState(strings.NewReader(config.Alerts.InitialState()))
+		if err != nil {
+			return nil, fmt.Errorf("unable to decode the alerts snapshot: %w", err)
+		}
+		if err := am.restoreAlerts(st, config.Alerts.Retention()); err != nil {
+			return nil, fmt.Errorf("unable to restore the alerts snapshot: %w", err)
+		}
+
+		am.wg.Add(1)
+		go func() {
+			am.alertsMaintenance(config.Alerts)
+			am.wg.Done()
+		}()
+	}
+
 	return am, nil
 }
 
@@ -291,20 +515,78 @@ func (am *GrafanaAlertmanager) ready() bool {
 
 func (am *GrafanaAlertmanager) StopAndWait() {
 	if am.dispatcher != nil {
-		am.dispatcher.Stop()
+		stopAndWait(am.dispatcher.Stop, am.dispatcherStarted, am.dispatcherDone)
 	}
 
 	if am.inhibitor != nil {
-		am.inhibitor.Stop()
+		stopAndWait(am.inhibitor.Stop, am.inhibitorStarted, am.inhibitorDone)
 	}
 
-	am.alerts.Close()
-
 	close(am.stopc)
 
 	am.wg.Wait()
+
+	// The alert store is closed after the maintenance goroutines have returned, as they take the final snapshot of the
+	// alerts.
+	am.alerts.Close()
+
+	am.events.close()
+}
+
+// stopAndWait stops the dispatcher or the inhibitor and waits until it stops running. Stopping them does nothing until
+// they start running, which they may not have yet right after a configuration is applied, so they are only stopped once
+// started is closed.
+func stopAndWait(stop func(), started, done <-chan struct{}) {
+	<-started
+	stop()
+	<-done
+}
+
+// startSignalingAlerts closes started the first time the alerts are subscribed to. The dispatcher and the inhibitor
+// subscribe when they start running, after they have set up what stopping them cancels.
+type startSignalingAlerts struct {
+	provider.Alerts
+	once    sync.Once
+	started chan struct{}
+}
+
+func newStartSignalingAlerts(alerts provider.Alerts) *startSignalingAlerts {
+	return &startSignalingAlerts{Alerts: alerts, started: make(chan struct{})}
 }
 
+func (a *startSignalingAlerts) Subscribe() provider.AlertIterator {
+	a.once.Do(func() { close(a.started) })
+	return a.Alerts.Subscribe()
+}
+
+// addState replicates the state through the peer.
+func (am *GrafanaAlertmanager) addState(key string, s cluster.State, reg prometheus.Registerer) cluster.ClusterChannel {
+	am.stateKeys = append(am.stateKeys, key)
+	return am.peer.AddState(key, s, reg)
+}
+
+// removeStates stops replicating the states of the Alertmanager, which must be stopped. The upstream cluster peer
+// cannot remove states, so they are replaced with removedState instead, which releases them. Their keys stay known to
+// the peer until it stops, or until an Alertmanager of the same tenant adds its states again.
+func (am *GrafanaAlertmanager) removeStates() {
+	for _, key := range am.stateKeys {
+		if p, ok := am.peer.(stateRemovingPeer); ok {
+			p.RemoveState(key)
+			continue
+		}
+		// The metrics of the channel of the replacement are not exported.
+		am.peer.AddState(key, removedState{}, prometheus.NewRegistry())
+	}
+	am.stateKeys = nil
+}
+
+// removedState replaces the states of removed Alertmanagers. It holds nothing and ignores what peers send. An empty
+// state is a valid encoding of every state of the Alertmanager, with no entries.
+type removedState struct{}
+
+func (removedState) MarshalBinary() ([]byte, error) { return nil, nil }
+func (removedState) Merge([]byte) error             { return nil }
+
 // GetReceivers returns the receivers configured as part of the current configuration.
 // It is safe to call concurrently.
 func (am *GrafanaAlertmanager) GetReceivers() []models.Receiver {
@@ -323,6 +605,7 @@ func GetReceivers(receivers []*nfstatus.Receiver) []models.Receiver {
 		integrations := make([]models.Integration, 0, len(rcv.Integrations()))
 		for _, integration := range rcv.Integrations() {
 			ts, d, err := integration.GetReport()
+			health := integration.GetHealth()
 			integrations = append(integrations, models.Integration{
 				Name:                      integration.Name(),
 				SendResolved:              integration.SendResolved(),
@@ -334,13 +617,21 @@ func GetReceivers(receivers []*nfstatus.Receiver) []models.Receiver {
 					}
 					return ""
 				}(),
+				LastSuccessfulNotifyAttempt: strfmt.DateTime(health.LastSuccessfulNotifyAttempt),
+				ConsecutiveFailures:         health.ConsecutiveFailures,
+				TotalSuccesses:              health.TotalSuccesses,
+				TotalFailures:               health.TotalFailures,
+				ErrorRate:                   health.ErrorRate,
+				CircuitBreakerState:         string(integration.CircuitBreakerState()),
 			})
 		}
 
 		apiReceivers = append(apiReceivers, models.Receiver{
-			Active:       rcv.Active(),
-			Integrations: integrations,
-			Name:         rcv.Name(),
+			Active:           rcv.Active(),
+			FallbackReceiver: rcv.Fallback(),
+			LastFallback:     strfmt.DateTime(rcv.LastFallback()),
+			Integrations:     integrations,
+			Name:             rcv.Name(),
 		})
 	}
 
@@ -640,14 +931,11 @@ func (am *GrafanaAlertmanager) buildTimeIntervals(timeIntervals []config.TimeInt
 	return muteTimes
 }
 
-// ApplyConfig applies a new configuration by re-initializing all components using the configuration provided.
-// It is not safe to call concurrently.
-func (am *GrafanaAlertmanager) ApplyConfig(cfg Configuration) (err error) {
-	am.templates = cfg.Templates()
-
+// buildTemplate parses the template definitions, skipping definitions with a name that was already seen.
+func (am *GrafanaAlertmanager) buildTemplate(definitions []templates.TemplateDefinition) (*templates.Template, error) {
 	seen := make(map[string]struct{})
-	tmpls := make([]string, 0, len(am.templates))
-	for _, tc := range am.templates {
+	tmpls := make([]string, 0, len(definitions))
+	for _, tc := range definitions {
 		if _, ok := seen[tc.Name]; ok {
 			level.Warn(am.logger).Log("msg", "template with same name is defined multiple times, skipping...", "template_name", tc.Name)
 			continue
@@ -656,34 +944,90 @@ func (am *GrafanaAlertmanager) ApplyConfig(cfg Configuration) (err error) {
 		seen[tc.Name] = struct{}{}
 	}
 
-	tmpl, err := templateFromContent(tmpls, am.ExternalURL())
-	if err != nil {
-		return err
-	}
+	return templateFromContent(tmpls, am.ExternalURL())
+}
 
-	// Finally, build the integrations map using the receiver configuration and templates.
+// buildIntegrationsMap builds the integrations of every receiver in the configuration, keyed by receiver name. The
+// upstream integrations of receivers are built after their Grafana integrations if they are enabled.
+func (am *GrafanaAlertmanager) buildIntegrationsMap(cfg Configuration, tmpl *templates.Template) (map[string][]*Integration, error) {
 	apiReceivers := cfg.Receivers()
 	integrationsMap := make(map[string][]*Integration, len(apiReceivers))
 	for _, apiReceiver := range apiReceivers {
 		integrations, err := cfg.BuildReceiverIntegrationsFunc()(apiReceiver, tmpl)
 		if err != nil {
-			return err
+			return nil, err
+		}
+		if am.upstreamIntegrations != nil {
+			upstream, err := BuildUpstreamReceiverIntegrations(apiReceiver.ConfigReceiver, tmpl, len(integrations), am.logger, am.upstreamIntegrations.HTTPClientOptions...)
+			if err != nil {
+				return nil, fmt.Errorf("failed to build the upstream integrations of receiver %s: %w", apiReceiver.Name, err)
+			}
+			integrations = append(integrations, upstream...)
 		}
 		integrationsMap[apiReceiver.Name] = integrations
 	}
+	return integrationsMap, nil
+}
+
+// ApplyConfig applies a new configuration by re-initializing all components using the configuration provided.
+// It is not safe to call concurrently.
+func (am *GrafanaAlertmanager) ApplyConfig(cfg Configuration) (err error) {
+	defer func() {
+		am.lastApplyConfigError = err
+		if err != nil {
+			am.lastApplyConfigErrorAt = time.Now()
+		}
+	}()
+
+	am.templates = cfg.Templates()
+
+	tmpl, err := am.buildTemplate(am.templates)
+	if err != nil {
+		return err
+	}
+
+	// Finally, build the integrations map using the receiver configuration and templates.
+	integrationsMap, err := am.buildIntegrationsMap(cfg, tmpl)
+	if err != nil {
+		return err
+	}
+	if err := validateFallbackReceivers(cfg.Receivers(), integrationsMap); err != nil {
+		return err
+	}
+	if err := validateRateLimits(cfg.Receivers()); err != nil {
+		return err
+	}
+	timeIntervals := am.buildTimeIntervals(cfg.TimeIntervals(), cfg.MuteTimeIntervals())
+	if err := validateDigests(cfg.Receivers(), timeIntervals); err != nil {
+		return err
+	}
+	route := dispatch.NewRoute(cfg.RoutingTree(), nil)
+	if err := validateRoutes(route, integrationsMap, timeIntervals); err != nil {
+		return err
+	}
+	routePolicies, err := escalationPolicies(cfg, route, integrationsMap)
+	if err != nil {
+		return err
+	}
+	if len(routePolicies) > 0 && !am.escalationsPersisted {
+		return errors.New("escalation policies require escalations maintenance options")
+	}
 
 	// Now, let's put together our notification pipeline
 	routingStage := make(notify.RoutingStage, len(integrationsMap))
 
 	if am.inhibitor != nil {
-		am.inhibitor.Stop()
+		stopAndWait(am.inhibitor.Stop, am.inhibitorStarted, am.inhibitorDone)
 	}
 	if am.dispatcher != nil {
-		am.dispatcher.Stop()
+		stopAndWait(am.dispatcher.Stop, am.dispatcherStarted, am.dispatcherDone)
 	}
+	close(am.reloadc)
+	am.reloadc = make(chan struct{})
 
-	am.inhibitor = inhibit.NewInhibitor(am.alerts, cfg.InhibitRules(), am.marker, am.logger)
-	am.timeIntervals = am.buildTimeIntervals(cfg.TimeIntervals(), cfg.MuteTimeIntervals())
+	inhibitorAlerts := newStartSignalingAlerts(am.alerts)
+	am.inhibitor = inhibit.NewInhibitor(inhibitorAlerts, cfg.InhibitRules(), am.marker, am.logger)
+	am.timeIntervals = timeIntervals
 	am.silencer = silence.NewSilencer(am.silences, am.marker, am.logger)
 
 	meshStage := notify.NewGossipSettleStage(am.peer)
@@ -691,40 +1035,100 @@ func (am *GrafanaAlertmanager) ApplyConfig(cfg Configuration) (err error) {
 	timeMuteStage := notify.NewTimeMuteStage(timeinterval.NewIntervener(am.timeIntervals), am.stageMetrics)
 	silencingStage := notify.NewMuteStage(am.silencer, am.stageMetrics)
 
-	am.route = dispatch.NewRoute(cfg.RoutingTree(), nil)
-	am.dispatcher = dispatch.NewDispatcher(am.alerts, am.route, routingStage, am.marker, am.timeoutFunc, cfg.DispatcherLimits(), am.logger, am.dispatcherMetrics)
+	am.route = route
+	dispatcherAlerts := newStartSignalingAlerts(am.alerts)
+	am.dispatcher = dispatch.NewDispatcher(dispatcherAlerts, am.route, routingStage, am.marker, am.timeoutFunc, cfg.DispatcherLimits(), am.logger, am.dispatcherMetrics)
 
 	// TODO: This has not been upstreamed yet. Should be aligned when https://github.com/prometheus/alertmanager/pull/3016 is merged.
 	var receivers []*nfstatus.Receiver
 	activeReceivers := GetActiveReceiversMap(am.route)
+	fallbacks := make(map[string]string)
+	stageOptions := make(map[string]receiverStageOptions, len(integrationsMap))
+	limiters := make(rateLimiters)
+	for _, r := range cfg.Receivers() {
+		if r.FallbackReceiver != "" {
+			fallbacks[r.Name] = r.FallbackReceiver
+		}
+		stageOptions[r.Name] = receiverStageOptions{
+			tmpl:         tmpl,
+			rateLimiters: buildRateLimiters(r, integrationsMap[r.Name], am.rateLimiters, limiters),
+			digest:       buildDigestSchedule(r, timeIntervals),
+		}
+	}
+	am.rateLimiters = limiters
+	var esc *escalator
+	if len(routePolicies) > 0 {
+		esc = &escalator{routePolicies: routePolicies, stages: make(map[string]notify.Stage), state: am.escalations}
+		for _, p := range routePolicies {
+			for _, l := range p.Levels {
+				if _, ok := esc.stages[l.Receiver]; !ok {
+					esc.stages[l.Receiver] = am.createReceiverStage(l.Receiver, integrationsMap[l.Receiver], stageOptions[l.Receiver], am.waitFunc, am.notificationLog)
+				}
+			}
+		}
+	}
+	var unchangedIntegrations map[integrationKey]*nfstatus.Integration
+	if am.circuitBreaker != nil {
+		unchangedIntegrations = am.unchangedIntegrations(cfg.Receivers())
+	}
 	for name := range integrationsMap {
-		stage := am.createReceiverStage(name, nfstatus.GetIntegrations(integrationsMap[name]), am.waitFunc, am.notificationLog)
-		routingStage[name] = notify.MultiStage{meshStage, silencingStage, timeMuteStage, inhibitionStage, stage}
+		for _, integration := range integrationsMap[name] {
+			integration.SetMetrics(am.Metrics.integrationMetrics(am.tenantString(), name, integration, am.circuitBreaker != nil))
+			integration.SetDeliveryDelayTracker(am.deliveryDelay)
+			if am.circuitBreaker != nil {
+				integration.SetCircuitBreaker(*am.circuitBreaker)
+				if prev, ok := unchangedIntegrations[integrationKey{name, integration.UID()}]; ok {
+					integration.RestoreCircuitBreaker(prev)
+				}
+			}
+		}
 		_, isActive := activeReceivers[name]
+		rcv := nfstatus.NewReceiver(name, isActive, integrationsMap[name])
+
+		stage := am.createReceiverStage(name, integrationsMap[name], stageOptions[name], am.waitFunc, am.notificationLog)
+		if fallback, ok := fallbacks[name]; ok {
+			rcv.SetFallback(fallback)
+			stage = newFallbackStage(rcv, stage, am.createReceiverStage(fallback, integrationsMap[fallback], stageOptions[fallback], am.waitFunc, am.notificationLog), am.fallbackTimeout)
+		}
+		if esc != nil {
+			stage = newEscalationStage(stage, esc)
+		}
+		stage = newAcknowledgementStage(stage, am.acknowledgements)
+		routingStage[name] = notify.MultiStage{meshStage, silencingStage, timeMuteStage, inhibitionStage, stage}
 
-		receivers = append(receivers, nfstatus.NewReceiver(name, isActive, integrationsMap[name]))
+		receivers = append(receivers, rcv)
 	}
 
 	am.setReceiverMetrics(receivers, len(activeReceivers))
 	am.setInhibitionRulesMetrics(cfg.InhibitRules())
+	am.deleteRemovedIntegrationMetrics(am.receivers, receivers)
 
 	am.receivers = receivers
+	am.apiReceivers = cfg.Receivers()
+	am.inhibitRules = cfg.InhibitRules()
 	am.buildReceiverIntegrationsFunc = cfg.BuildReceiverIntegrationsFunc()
 
+	dispatcherDone, inhibitorDone := make(chan struct{}), make(chan struct{})
+	am.dispatcherDone, am.inhibitorDone = dispatcherDone, inhibitorDone
+	am.dispatcherStarted, am.inhibitorStarted = dispatcherAlerts.started, inhibitorAlerts.started
+
 	am.wg.Add(1)
 	go func() {
 		defer am.wg.Done()
+		defer close(dispatcherDone)
 		am.dispatcher.Run()
 	}()
 
 	am.wg.Add(1)
 	go func() {
 		defer am.wg.Done()
+		defer close(inhibitorDone)
 		am.inhibitor.Run()
 	}()
 
 	am.configHash = cfg.Hash()
 	am.config = cfg.Raw()
+	am.configAppliedAt = time.Now()
 
 	return nil
 }
@@ -749,6 +1153,68 @@ func (am *GrafanaAlertmanager) setReceiverMetrics(receivers []*nfstatus.Receiver
 	}
 }
 
+// integrationKey identifies a Grafana integration of a receiver.
+type integrationKey struct {
+	receiver string
+	uid      string
+}
+
+// unchangedIntegrations returns the integrations of the current configuration that are Grafana integrations with the
+// same receiver, UID and configuration in the next receivers. It must be called before the next configuration is
+// applied.
+func (am *GrafanaAlertmanager) unchangedIntegrations(next []*APIReceiver) map[integrationKey]*nfstatus.Integration {
+	configs := make(map[integrationKey]*GrafanaIntegrationConfig)
+	for _, r := range am.apiReceivers {
+		for _, i := range r.Integrations {
+			if i.UID != "" {
+				configs[integrationKey{r.Name, i.UID}] = i
+			}
+		}
+	}
+	unchanged := make(map[integrationKey]bool)
+	for _, r := range next {
+		for _, i := range r.Integrations {
+			k := integrationKey{r.Name, i.UID}
+			if prev, ok := configs[k]; ok && integrationConfigsEqual(prev, i) {
+				unchanged[k] = true
+			}
+		}
+	}
+
+	res := make(map[integrationKey]*nfstatus.Integration)
+	for _, r := range am.receivers {
+		for _, i := range r.Integrations() {
+			if k := (integrationKey{r.Name(), i.UID()}); unchanged[k] {
+				res[k] = i
+			}
+		}
+	}
+	return res
+}
+
+// deleteRemovedIntegrationMetrics deletes the metrics of the integrations of the previous receivers that are not in the
+// current receivers, so that removed and renamed integrations do not keep exporting stale series.
+func (am *GrafanaAlertmanager) deleteRemovedIntegrationMetrics(previous, current []*nfstatus.Receiver) {
+	type key struct{ receiver, integration, uid, index string }
+	keyOf := func(r *nfstatus.Receiver, i *nfstatus.Integration) key {
+		lbls := integrationLabels(am.tenantString(), r.Name(), i)
+		return key{r.Name(), lbls["integration"], lbls["uid"], lbls["index"]}
+	}
+	keys := make(map[key]struct{})
+	for _, r := range current {
+		for _, i := range r.Integrations() {
+			keys[keyOf(r, i)] = struct{}{}
+		}
+	}
+	for _, r := range previous {
+		for _, i := range r.Integrations() {
+			if _, ok := keys[keyOf(r, i)]; !ok {
+				am.Metrics.deleteIntegrationMetrics(am.tenantString(), r.Name(), i)
+			}
+		}
+	}
+}
+
 // PutAlerts receives the alerts and then sends them through the corresponding route based on whenever the alert has a receiver embedded or not
 func (am *GrafanaAlertmanager) PutAlerts(postableAlerts amv2.PostableAlerts) error {
 	now := time.Now()
@@ -772,10 +1238,28 @@ func (am *GrafanaAlertmanager) PutAlerts(postableAlerts amv2.PostableAlerts) err
 			a.EndsAt)
 	}
 
+	// Alerts that were firing and are received resolved change state. So do alerts that already resolved because their
+	// end time passed, as their event is only emitted once they are garbage collected, which they no longer are.
+	var resolved []*types.Alert
+	for _, a := range alerts {
+		if !a.EndsAt.After(now) {
+			if prev, err := am.alerts.Get(a.Fingerprint()); err == nil && (!prev.ResolvedAt(now) || resolvedByTimeout(prev)) {
+				resolved = append(resolved, a)
+			}
+		}
+	}
+
 	if err := am.alerts.Put(alerts...); err != nil {
 		// Notification sending alert takes precedence over validation errors.
 		return err
 	}
+
+	for _, a := range alerts {
+		am.events.publish(Event{Type: EventAlertReceived, Timestamp: now, Fingerprint: a.Fingerprint().String(), Labels: a.Labels})
+	}
+	for _, a := range resolved {
+		am.events.publish(Event{Type: EventAlertStateChanged, Timestamp: now, Fingerprint: a.Fingerprint().String(), Labels: a.Labels, State: AlertStateResolved})
+	}
 	if validationErr != nil {
 		am.Metrics.Invalid().Add(float64(len(validationErr.Alerts)))
 		// Even if validationErr is nil, the require.NoError fails on it.
@@ -864,8 +1348,79 @@ func (e AlertValidationError) Error() string {
 	return errMsg
 }
 
+// receiverStageOptions configures the optional stages of the pipeline of a receiver.
+type receiverStageOptions struct {
+	tmpl *templates.Template
+	// rateLimiters are the rate limiters of the integrations, in the same order. They may be nil.
+	rateLimiters []*rateLimiter
+	// digest is the schedule of the digest notifications of the receiver, if any.
+	digest []timeinterval.TimeInterval
+}
+
+// lifecycleEvent is what ends the wait of a stage that sends notifications in the background.
+type lifecycleEvent int
+
+const (
+	// lifecycleTimer is the end of the wait.
+	lifecycleTimer lifecycleEvent = iota
+	// lifecycleReload is the replacement of the configuration the stage belongs to.
+	lifecycleReload
+	// lifecycleStop is the Alertmanager stopping.
+	lifecycleStop
+)
+
+// stageLifecycle ties the notifications that stages send in the background, outside of the notification pipeline, to
+// the configuration they belong to and to the Alertmanager.
+type stageLifecycle struct {
+	// wg is the wait group of the Alertmanager, so that stopping waits for notifications sent in the background.
+	wg *sync.WaitGroup
+	// reloadc is closed when the configuration is replaced.
+	reloadc <-chan struct{}
+	// stopc is closed when the Alertmanager stops.
+	stopc <-chan struct{}
+}
+
+// stageLifecycle returns the lifecycle of the stages of the configuration being applied.
+func (am *GrafanaAlertmanager) stageLifecycle() stageLifecycle {
+	return stageLifecycle{wg: &am.wg, reloadc: am.reloadc, stopc: am.stopc}
+}
+
+// after calls f in the background once d has elapsed, the configuration is replaced or the Alertmanager stops,
+// whichever happens first.
+func (l stageLifecycle) after(d time.Duration, f func(lifecycleEvent)) {
+	l.wg.Add(1)
+	go func() {
+		defer l.wg.Done()
+
+		t := time.NewTimer(d)
+		defer t.Stop()
+		select {
+		case <-t.C:
+			f(lifecycleTimer)
+		case <-l.reloadc:
+			f(lifecycleReload)
+		case <-l.stopc:
+			f(lifecycleStop)
+		}
+	}()
+}
+
+// context returns the context of a notification sent in the background. It is done after the timeout or once the
+// Alertmanager stops, so that stopping does not wait for integrations that are slow or down.
+func (l stageLifecycle) context(timeout time.Duration) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	go func() {
+		select {
+		case <-l.stopc:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
 // createReceiverStage creates a pipeline of stages for a receiver.
-func (am *GrafanaAlertmanager) createReceiverStage(name string, integrations []*notify.Integration, wait func() time.Duration, notificationLog notify.NotificationLog) notify.Stage {
+func (am *GrafanaAlertmanager) createReceiverStage(name string, integrations []*nfstatus.Integration, opts receiverStageOptions, wait func() time.Duration, notificationLog notify.NotificationLog) notify.Stage {
 	var fs notify.FanoutStage
 	for i := range integrations {
 		recv := &nflogpb.Receiver{
@@ -875,8 +1430,15 @@ func (am *GrafanaAlertmanager) createReceiverStage(name string, integrations []*
 		}
 		var s notify.MultiStage
 		s = append(s, notify.NewWaitStage(wait))
-		s = append(s, notify.NewDedupStage(integrations[i], notificationLog, recv))
-		s = append(s, notify.NewRetryStage(integrations[i], name, am.stageMetrics))
+		s = append(s, notify.NewDedupStage(integrations[i].Integration(), notificationLog, recv))
+		var notifyStage notify.Stage = newNotificationHistoryStage(notify.NewRetryStage(integrations[i].Integration(), name, am.stageMetrics), am.notificationHistory, am.events, name, integrations[i])
+		if i < len(opts.rateLimiters) && opts.rateLimiters[i] != nil {
+			notifyStage = newRateLimitStage(notifyStage, opts.rateLimiters[i], opts.tmpl, name, integrations[i], am.stageLifecycle(), am.logger)
+		}
+		if len(opts.digest) > 0 {
+			notifyStage = newDigestStage(notifyStage, opts.tmpl, opts.digest, name, integrations[i], notificationLog, recv, am.stageLifecycle(), am.logger)
+		}
+		s = append(s, notifyStage)
 		s = append(s, notify.NewSetNotifiesStage(notificationLog, recv))
 
 		fs = append(fs, s)
@@ -1,10 +1,14 @@
 package notify
 
 import (
+	"strconv"
+
 	"github.com/go-kit/log"
 	"github.com/prometheus/alertmanager/api/metrics"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/grafana/alerting/notify/nfstatus"
 )
 
 const namespace = "grafana"
@@ -18,6 +22,15 @@ type GrafanaAlertmanagerMetrics struct {
 	configuredReceivers       *prometheus.GaugeVec
 	configuredIntegrations    *prometheus.GaugeVec
 	configuredInhibitionRules *prometheus.GaugeVec
+
+	integrationNotifications       *prometheus.CounterVec
+	integrationNotificationsFailed *prometheus.CounterVec
+	integrationNotificationLatency *prometheus.HistogramVec
+	integrationDeliveryDelay       *prometheus.HistogramVec
+	integrationCircuitState        *prometheus.GaugeVec
+	integrationCircuitRejections   *prometheus.CounterVec
+
+	eventsDropped *prometheus.CounterVec
 }
 
 // NewGrafanaAlertmanagerMetrics creates a set of metrics for the Alertmanager.
@@ -43,5 +56,88 @@ func NewGrafanaAlertmanagerMetrics(r prometheus.Registerer, l log.Logger) *Grafa
 			Name:      "alertmanager_inhibition_rules",
 			Help:      "Number of configured inhibition rules.",
 		}, []string{"org"}),
+		integrationNotifications: promauto.With(r).NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "alertmanager_integration_notifications_total",
+			Help:      "The total number of attempted notifications by integration.",
+		}, []string{"org", "receiver", "integration", "uid", "index"}),
+		integrationNotificationsFailed: promauto.With(r).NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "alertmanager_integration_notifications_failed_total",
+			Help:      "The total number of failed notifications by integration.",
+		}, []string{"org", "receiver", "integration", "uid", "index"}),
+		integrationNotificationLatency: promauto.With(r).NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "alertmanager_integration_notification_latency_seconds",
+			Help:      "The latency of notifications in seconds by integration.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"org", "receiver", "integration", "uid", "index"}),
+		integrationDeliveryDelay: promauto.With(r).NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "alertmanager_integration_delivery_delay_seconds",
+			Help:      "The time in seconds between an alert starting to fire and its first successful notification by integration.",
+			Buckets:   []float64{1, 5, 10, 30, 60, 120, 300, 600, 1800, 3600},
+		}, []string{"org", "receiver", "integration", "uid", "index"}),
+		integrationCircuitState: promauto.With(r).NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "alertmanager_integration_circuit_breaker_state",
+			Help:      "The state of the circuit breaker by integration: 0 closed, 1 half-open, 2 open.",
+		}, []string{"org", "receiver", "integration", "uid", "index"}),
+		integrationCircuitRejections: promauto.With(r).NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "alertmanager_integration_circuit_breaker_rejections_total",
+			Help:      "The total number of notifications rejected by the circuit breaker by integration.",
+		}, []string{"org", "receiver", "integration", "uid", "index"}),
+		eventsDropped: promauto.With(r).NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "alertmanager_events_dropped_total",
+			Help:      "The total number of events dropped because the buffer of their subscriber was full.",
+		}, []string{"org"}),
+	}
+}
+
+// integrationLabels returns the labels of the metrics of a single integration of a receiver. Integrations are
+// identified by their UID. Integrations without a UID, such as upstream integrations, are identified by their index in
+// the receiver instead, so that integrations of the same type in the same receiver do not share series.
+func integrationLabels(org string, receiver string, i *nfstatus.Integration) prometheus.Labels {
+	lbls := prometheus.Labels{"org": org, "receiver": receiver, "integration": i.Name(), "uid": i.UID(), "index": ""}
+	if i.UID() == "" {
+		lbls["index"] = strconv.Itoa(i.Index())
+	}
+	return lbls
+}
+
+// integrationMetrics returns the metrics of a single integration of a receiver. The circuit breaker metrics are only
+// included if the integration has a circuit breaker.
+func (m *GrafanaAlertmanagerMetrics) integrationMetrics(org string, receiver string, i *nfstatus.Integration, circuitBreaker bool) *nfstatus.Metrics {
+	lbls := integrationLabels(org, receiver, i)
+	res := &nfstatus.Metrics{
+		Attempts:      m.integrationNotifications.With(lbls),
+		Failures:      m.integrationNotificationsFailed.With(lbls),
+		Latency:       m.integrationNotificationLatency.With(lbls),
+		DeliveryDelay: m.integrationDeliveryDelay.With(lbls),
 	}
+	if circuitBreaker {
+		res.CircuitBreakerState = m.integrationCircuitState.With(lbls)
+		res.CircuitBreakerRejections = m.integrationCircuitRejections.With(lbls)
+	}
+	return res
+}
+
+// deleteIntegrationMetrics deletes the metrics of a single integration of a receiver.
+func (m *GrafanaAlertmanagerMetrics) deleteIntegrationMetrics(org string, receiver string, i *nfstatus.Integration) {
+	lbls := integrationLabels(org, receiver, i)
+	m.integrationNotifications.Delete(lbls)
+	m.integrationNotificationsFailed.Delete(lbls)
+	m.integrationNotificationLatency.Delete(lbls)
+	m.integrationDeliveryDelay.Delete(lbls)
+	m.integrationCircuitState.Delete(lbls)
+	m.integrationCircuitRejections.Delete(lbls)
 }
@@ -3,6 +3,7 @@ package notify
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"net/http"
 	"sort"
@@ -29,8 +30,10 @@ func setupAMTest(t *testing.T) (*GrafanaAlertmanager, *prometheus.Registry) {
 	m := NewGrafanaAlertmanagerMetrics(reg, log.NewNopLogger())
 
 	grafanaConfig := &GrafanaAlertmanagerConfig{
-		Silences: newFakeMaintanenceOptions(t),
-		Nflog:    newFakeMaintanenceOptions(t),
+		Silences:         newFakeMaintanenceOptions(t),
+		Nflog:            newFakeMaintanenceOptions(t),
+		Escalations:      newFakeMaintanenceOptions(t),
+		Acknowledgements: newFakeMaintanenceOptions(t),
 	}
 
 	am, err := NewGrafanaAlertmanager("org", 1, grafanaConfig, &NilPeer{}, log.NewNopLogger(), m)
@@ -578,8 +581,8 @@ func TestGrafanaAlertmanager_setInhibitionRulesMetrics(t *testing.T) {
 func TestGrafanaAlertmanager_setReceiverMetrics(t *testing.T) {
 	fn := &fakeNotifier{}
 	integrations := []*nfstatus.Integration{
-		nfstatus.NewIntegration(fn, fn, "grafana-oncall", 0, "test-grafana-oncall"),
-		nfstatus.NewIntegration(fn, fn, "sns", 1, "test-sns"),
+		nfstatus.NewIntegrationWithUID(fn, fn, "grafana-oncall", 0, "test-grafana-oncall", "test-grafana-oncall-uid"),
+		nfstatus.NewIntegrationWithUID(fn, fn, "sns", 1, "test-sns", "test-sns-uid"),
 	}
 
 	am, reg := setupAMTest(t)
@@ -605,6 +608,104 @@ func TestGrafanaAlertmanager_setReceiverMetrics(t *testing.T) {
 `), "grafana_alerting_alertmanager_receivers", "grafana_alerting_alertmanager_integrations"))
 }
 
+func TestGrafanaAlertmanager_integrationMetrics(t *testing.T) {
+	am, reg := setupAMTest(t)
+
+	fn := &fakeNotifier{}
+	integration := nfstatus.NewIntegrationWithUID(fn, fn, "slack", 0, "team-a", "slack-uid")
+	integration.SetMetrics(am.Metrics.integrationMetrics(am.tenantString(), "team-a", integration, false))
+
+	_, err := integration.Notify(context.Background())
+	require.NoError(t, err)
+
+	require.NoError(t, testutil.GatherAndCompare(reg, bytes.NewBufferString(`
+							# HELP grafana_alerting_alertmanager_integration_notifications_failed_total The total number of failed notifications by integration.
+							# TYPE grafana_alerting_alertmanager_integration_notifications_failed_total counter
+							grafana_alerting_alertmanager_integration_notifications_failed_total{index="",integration="slack",org="1",receiver="team-a",uid="slack-uid"} 0
+							# HELP grafana_alerting_alertmanager_integration_notifications_total The total number of attempted notifications by integration.
+							# TYPE grafana_alerting_alertmanager_integration_notifications_total counter
+							grafana_alerting_alertmanager_integration_notifications_total{index="",integration="slack",org="1",receiver="team-a",uid="slack-uid"} 1
+`), "grafana_alerting_alertmanager_integration_notifications_total", "grafana_alerting_alertmanager_integration_notifications_failed_total"))
+}
+
+func TestGrafanaAlertmanager_deleteRemovedIntegrationMetrics(t *testing.T) {
+	am, reg := setupAMTest(t)
+
+	fn := &fakeNotifier{}
+	kept := nfstatus.NewIntegrationWithUID(fn, fn, "slack", 0, "team-a", "slack-uid")
+	removed := nfstatus.NewIntegrationWithUID(fn, fn, "email", 1, "team-a", "email-uid")
+	// Integrations without a UID are told apart by their index.
+	keptWebhook := nfstatus.NewIntegration(fn, fn, "webhook", 2, "team-a")
+	removedWebhook := nfstatus.NewIntegration(fn, fn, "webhook", 3, "team-a")
+	for _, i := range []*nfstatus.Integration{kept, removed, keptWebhook, removedWebhook} {
+		i.SetMetrics(am.Metrics.integrationMetrics(am.tenantString(), "team-a", i, false))
+		_, err := i.Notify(context.Background())
+		require.NoError(t, err)
+	}
+
+	// The series of integrations that are no longer configured are deleted.
+	am.deleteRemovedIntegrationMetrics(
+		[]*nfstatus.Receiver{nfstatus.NewReceiver("team-a", true, []*nfstatus.Integration{kept, removed, keptWebhook, removedWebhook})},
+		[]*nfstatus.Receiver{nfstatus.NewReceiver("team-a", true, []*nfstatus.Integration{kept, keptWebhook})},
+	)
+
+	require.NoError(t, testutil.GatherAndCompare(reg, bytes.NewBufferString(`
+							# HELP grafana_alerting_alertmanager_integration_notifications_total The total number of attempted notifications by integration.
+							# TYPE grafana_alerting_alertmanager_integration_notifications_total counter
+							grafana_alerting_alertmanager_integration_notifications_total{index="",integration="slack",org="1",receiver="team-a",uid="slack-uid"} 1
+							grafana_alerting_alertmanager_integration_notifications_total{index="2",integration="webhook",org="1",receiver="team-a",uid=""} 1
+`), "grafana_alerting_alertmanager_integration_notifications_total"))
+}
+
+func TestGrafanaAlertmanager_unchangedIntegrations(t *testing.T) {
+	am, _ := setupAMTest(t)
+
+	receiver := func(integrations ...*GrafanaIntegrationConfig) *APIReceiver {
+		return &APIReceiver{ConfigReceiver: ConfigReceiver{Name: "team-a"}, GrafanaIntegrations: GrafanaIntegrations{Integrations: integrations}}
+	}
+	am.apiReceivers = []*APIReceiver{receiver(
+		&GrafanaIntegrationConfig{UID: "a", Type: "slack", Settings: json.RawMessage(`{"recipient": "#a"}`)},
+		&GrafanaIntegrationConfig{UID: "b", Type: "webhook", Settings: json.RawMessage(`{"url": "http://b"}`)},
+	)}
+	fn := &fakeNotifier{}
+	a := nfstatus.NewIntegrationWithUID(fn, fn, "slack", 0, "team-a", "a")
+	b := nfstatus.NewIntegrationWithUID(fn, fn, "webhook", 1, "team-a", "b")
+	am.receivers = []*nfstatus.Receiver{nfstatus.NewReceiver("team-a", true, []*nfstatus.Integration{a, b})}
+
+	// Integrations whose configuration changed are not unchanged.
+	res := am.unchangedIntegrations([]*APIReceiver{receiver(
+		&GrafanaIntegrationConfig{UID: "a", Type: "slack", Settings: json.RawMessage(`{"recipient":"#a"}`)},
+		&GrafanaIntegrationConfig{UID: "b", Type: "webhook", Settings: json.RawMessage(`{"url": "http://c"}`)},
+	)})
+	require.Equal(t, map[integrationKey]*nfstatus.Integration{{receiver: "team-a", uid: "a"}: a}, res)
+}
+
+func TestGrafanaAlertmanager_circuitBreakerMetrics(t *testing.T) {
+	am, reg := setupAMTest(t)
+
+	fn := &fakeNotifierWithError{err: errors.New("failed to notify")}
+	integration := nfstatus.NewIntegrationWithUID(fn, fn, "slack", 0, "team-a", "slack-uid")
+	integration.SetMetrics(am.Metrics.integrationMetrics(am.tenantString(), "team-a", integration, true))
+	integration.SetCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 1, OpenDuration: time.Hour})
+
+	_, err := integration.Notify(context.Background())
+	require.Error(t, err)
+	_, err = integration.Notify(context.Background())
+	require.ErrorIs(t, err, nfstatus.ErrCircuitOpen)
+
+	require.NoError(t, testutil.GatherAndCompare(reg, bytes.NewBufferString(`
+							# HELP grafana_alerting_alertmanager_integration_circuit_breaker_rejections_total The total number of notifications rejected by the circuit breaker by integration.
+							# TYPE grafana_alerting_alertmanager_integration_circuit_breaker_rejections_total counter
+							grafana_alerting_alertmanager_integration_circuit_breaker_rejections_total{index="",integration="slack",org="1",receiver="team-a",uid="slack-uid"} 1
+							# HELP grafana_alerting_alertmanager_integration_circuit_breaker_state The state of the circuit breaker by integration: 0 closed, 1 half-open, 2 open.
+							# TYPE grafana_alerting_alertmanager_integration_circuit_breaker_state gauge
+							grafana_alerting_alertmanager_integration_circuit_breaker_state{index="",integration="slack",org="1",receiver="team-a",uid="slack-uid"} 2
+`), "grafana_alerting_alertmanager_integration_circuit_breaker_state", "grafana_alerting_alertmanager_integration_circuit_breaker_rejections_total"))
+
+	receivers := GetReceivers([]*nfstatus.Receiver{nfstatus.NewReceiver("team-a", true, []*nfstatus.Integration{integration})})
+	require.Equal(t, "open", receivers[0].Integrations[0].CircuitBreakerState)
+}
+
 // Tests cleanup of expired Silences. We rely on prometheus/alertmanager for
 // our alert silencing functionality, so we rely on its tests. However, we
 // implement a custom maintenance function for silences, because we snapshot
@@ -1 +1,34 @@
 package notify
+
+import (
+	"github.com/go-kit/log"
+	"github.com/prometheus/alertmanager/config/receiver"
+	commoncfg "github.com/prometheus/common/config"
+
+	"github.com/grafana/alerting/templates"
+)
+
+// UpstreamIntegrationsConfig configures the integrations of the upstream Alertmanager configurations of receivers, such
+// as email_configs, slack_configs and webhook_configs.
+type UpstreamIntegrationsConfig struct {
+	// HTTPClientOptions are the options of the HTTP clients of the integrations.
+	HTTPClientOptions []commoncfg.HTTPClientOption
+}
+
+// BuildUpstreamReceiverIntegrations builds the integrations of the upstream Alertmanager configurations of the receiver
+// with the upstream notifiers. Settings that are empty are not taken from the global configuration, see
+// definition.PostableApiAlertingConfig.SetUpstreamReceiverDefaults. The indexes of the integrations start at
+// firstIndex, so that they do not collide with the indexes of the Grafana integrations of the same receiver in the
+// notification log.
+func BuildUpstreamReceiverIntegrations(r ConfigReceiver, tmpl *templates.Template, firstIndex int, logger log.Logger, httpOpts ...commoncfg.HTTPClientOption) ([]*Integration, error) {
+	upstream, err := receiver.BuildReceiverIntegrations(r, tmpl, logger, httpOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	integrations := make([]*Integration, 0, len(upstream))
+	for i, integration := range upstream {
+		integrations = append(integrations, NewIntegration(integration, integration, integration.Name(), firstIndex+i, r.Name))
+	}
+	return integrations, nil
+}
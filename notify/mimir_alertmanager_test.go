@@ -0,0 +1,110 @@
+package notify
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-openapi/strfmt"
+	amv2 "github.com/prometheus/alertmanager/api/v2/models"
+	"github.com/prometheus/alertmanager/config"
+	"github.com/prometheus/alertmanager/pkg/labels"
+	"github.com/prometheus/client_golang/prometheus"
+	commoncfg "github.com/prometheus/common/config"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+)
+
+func upstreamWebhookReceiver(t *testing.T, name, rawURL string) ConfigReceiver {
+	u, err := url.Parse(rawURL)
+	require.NoError(t, err)
+	return ConfigReceiver{
+		Name: name,
+		WebhookConfigs: []*config.WebhookConfig{{
+			NotifierConfig: config.NotifierConfig{VSendResolved: true},
+			HTTPConfig:     &commoncfg.DefaultHTTPClientConfig,
+			URL:            &config.SecretURL{URL: u},
+		}},
+	}
+}
+
+func TestBuildUpstreamReceiverIntegrations(t *testing.T) {
+	r := upstreamWebhookReceiver(t, "upstream", "http://localhost/webhook")
+	tmpl, err := templateFromContent(nil, "http://localhost")
+	require.NoError(t, err)
+
+	integrations, err := BuildUpstreamReceiverIntegrations(r, tmpl, 2, log.NewNopLogger())
+	require.NoError(t, err)
+	require.Len(t, integrations, 1)
+	require.Equal(t, "webhook", integrations[0].Name())
+	require.Equal(t, 2, integrations[0].Index())
+	require.True(t, integrations[0].SendResolved())
+
+	// Receivers without upstream configurations have no upstream integrations.
+	integrations, err = BuildUpstreamReceiverIntegrations(ConfigReceiver{Name: "grafana"}, tmpl, 0, log.NewNopLogger())
+	require.NoError(t, err)
+	require.Empty(t, integrations)
+}
+
+func TestApplyConfig_UpstreamIntegrations(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+
+	reg := prometheus.NewPedanticRegistry()
+	am, err := NewGrafanaAlertmanager("org", 1, &GrafanaAlertmanagerConfig{
+		Silences:             newFakeMaintanenceOptions(t),
+		Nflog:                newFakeMaintanenceOptions(t),
+		UpstreamIntegrations: &UpstreamIntegrationsConfig{},
+	}, &NilPeer{}, log.NewNopLogger(), NewGrafanaAlertmanagerMetrics(reg, log.NewNopLogger()))
+	require.NoError(t, err)
+
+	matcher, err := labels.NewMatcher(labels.MatchEqual, "team", "upstream")
+	require.NoError(t, err)
+	cfg := &testConfiguration{
+		receivers: []*APIReceiver{
+			{
+				ConfigReceiver:      ConfigReceiver{Name: "grafana"},
+				GrafanaIntegrations: GrafanaIntegrations{Integrations: []*GrafanaIntegrationConfig{{Name: "grafana", Type: "webhook"}}},
+			},
+			{ConfigReceiver: upstreamWebhookReceiver(t, "upstream", server.URL)},
+		},
+		route: &Route{
+			Receiver: "grafana",
+			GroupBy:  []model.LabelName{"alertname"},
+			Routes: []*Route{{
+				Receiver:      "upstream",
+				Matchers:      config.Matchers{matcher},
+				GroupWait:     ptr(model.Duration(10 * time.Millisecond)),
+				GroupInterval: ptr(model.Duration(time.Minute)),
+			}},
+		},
+	}
+	require.NoError(t, am.ApplyConfig(cfg))
+
+	// Both kinds of integrations run in the same routing tree.
+	integrations := make(map[string][]string)
+	for _, r := range am.GetReceivers() {
+		for _, i := range r.Integrations {
+			integrations[r.Name] = append(integrations[r.Name], i.Name)
+		}
+	}
+	require.Equal(t, map[string][]string{"grafana": {"webhook"}, "upstream": {"webhook"}}, integrations)
+
+	now := time.Now()
+	require.NoError(t, am.PutAlerts(amv2.PostableAlerts{{
+		Alert:    amv2.Alert{Labels: amv2.LabelSet{"alertname": "test", "team": "upstream"}},
+		StartsAt: strfmt.DateTime(now),
+		EndsAt:   strfmt.DateTime(now.Add(time.Hour)),
+	}}))
+	require.Eventually(t, func() bool {
+		return requests.Load() == 1
+	}, 5*time.Second, 10*time.Millisecond)
+}
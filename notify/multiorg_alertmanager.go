@@ -2,11 +2,378 @@ package notify
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
 
-	"github.com/grafana/alerting/cluster"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	amv2 "github.com/prometheus/alertmanager/api/v2/models"
 	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/grafana/alerting/cluster"
 )
 
+// DefaultTenantSyncInterval is how often tenants are synced with the configuration store when no interval is configured.
+const DefaultTenantSyncInterval = time.Minute
+
+var (
+	ErrNoAlertmanagerForTenant = fmt.Errorf("alertmanager does not exist for this tenant")
+	ErrTenantSyncFailed        = fmt.Errorf("unable to sync tenant alertmanagers")
+)
+
+// TenantConfigStore provides the configurations of the Alertmanagers of tenants.
+type TenantConfigStore interface {
+	// ListTenants returns the IDs of the tenants that have an Alertmanager.
+	ListTenants(ctx context.Context) ([]int64, error)
+	// GetConfiguration returns the configuration of the Alertmanager of the tenant. It returns ErrNoAlertmanagerForTenant
+	// if the tenant has no Alertmanager.
+	GetConfiguration(ctx context.Context, tenantID int64) (Configuration, error)
+}
+
+// TenantStateStore deletes the state of the Alertmanagers of removed tenants.
+type TenantStateStore interface {
+	// DeleteState deletes the state of the Alertmanager of the tenant: its silences, notification log and any other
+	// state persisted through its maintenance options. It is called once the Alertmanager has stopped, and so after
+	// its final snapshots.
+	DeleteState(ctx context.Context, tenantID int64) error
+}
+
+type MultiTenantAlertmanagerConfig struct {
+	// Store provides the configurations of tenants.
+	Store TenantConfigStore
+	// StateStore is optional. If present, the state of removed tenants is deleted from it.
+	StateStore TenantStateStore
+	// AlertmanagerConfig returns the configuration of the Alertmanager of the tenant, including the maintenance options
+	// of its state. It is called every time the Alertmanager of a tenant is created.
+	AlertmanagerConfig func(tenantID int64) (*GrafanaAlertmanagerConfig, error)
+	// Registerer is optional. It returns the registerer of the metrics of the Alertmanager of the tenant, and is called
+	// every time the Alertmanager of a tenant is created. Metrics are not exported if it is not present.
+	Registerer func(tenantID int64) prometheus.Registerer
+	// TenantKey is the key of the tenant ID in logs. Defaults to "org".
+	TenantKey string
+	// SyncInterval is how often Run syncs tenants. Defaults to DefaultTenantSyncInterval.
+	SyncInterval time.Duration
+}
+
+func (c *MultiTenantAlertmanagerConfig) Validate() error {
+	if c.Store == nil {
+		return errors.New("tenant configuration store must be present")
+	}
+
+	if c.AlertmanagerConfig == nil {
+		return errors.New("tenant alertmanager configuration must be present")
+	}
+
+	return nil
+}
+
+// MultiTenantAlertmanager runs a GrafanaAlertmanager for every tenant of a configuration store. All Alertmanagers share
+// the cluster peer, and their state is replicated with keys of their tenant, such as "silences:<tenant ID>", until the
+// tenant is removed.
+type MultiTenantAlertmanager struct {
+	logger log.Logger
+	config MultiTenantAlertmanagerConfig
+	peer   ClusterPeer
+
+	mtx           sync.RWMutex
+	alertmanagers map[int64]*GrafanaAlertmanager
+	synced        bool
+
+	// syncMtx serializes syncs.
+	syncMtx sync.Mutex
+	// createMtx serializes the creation of Alertmanagers, which is done without mtx so that other tenants are not
+	// blocked in the meantime. Two Alertmanagers of the same tenant must not be created, even if only one is kept, as
+	// they would add the same states to the shared peer.
+	createMtx sync.Mutex
+}
+
+// NewMultiTenantAlertmanager creates a MultiTenantAlertmanager. Alertmanagers are created the first time they are
+// needed, when tenants are synced or when the Alertmanager of a tenant is requested.
+func NewMultiTenantAlertmanager(config MultiTenantAlertmanagerConfig, peer ClusterPeer, logger log.Logger) (*MultiTenantAlertmanager, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	if config.TenantKey == "" {
+		config.TenantKey = "org"
+	}
+	if config.SyncInterval <= 0 {
+		config.SyncInterval = DefaultTenantSyncInterval
+	}
+	if peer == nil {
+		peer = &NilPeer{}
+	}
+
+	return &MultiTenantAlertmanager{
+		logger:        log.With(logger, "component", "multitenant-alertmanager"),
+		config:        config,
+		peer:          peer,
+		alertmanagers: make(map[int64]*GrafanaAlertmanager),
+	}, nil
+}
+
+// Run syncs tenants right away and then every sync interval, until the context is done. It then stops the
+// Alertmanagers of all tenants, without deleting their state.
+func (m *MultiTenantAlertmanager) Run(ctx context.Context) error {
+	t := time.NewTicker(m.config.SyncInterval)
+	defer t.Stop()
+
+	for {
+		if err := m.Sync(ctx); err != nil {
+			level.Error(m.logger).Log("msg", "failed to sync tenant alertmanagers", "err", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			m.StopAndWait()
+			return nil
+		case <-t.C:
+		}
+	}
+}
+
+// Sync creates the Alertmanagers of new tenants, applies the configurations that changed, and stops the Alertmanagers
+// of the tenants that were removed from the store and deletes their state. A configuration is applied only if its hash
+// differs from the hash of the running configuration. Tenants that are not listed are only removed once the store
+// confirms they have no configuration, as their Alertmanager may have been created since they were listed. Errors of
+// single tenants do not stop the sync of the others.
+func (m *MultiTenantAlertmanager) Sync(ctx context.Context) error {
+	m.syncMtx.Lock()
+	defer m.syncMtx.Unlock()
+
+	tenantIDs, err := m.config.Store.ListTenants(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", err.Error(), ErrTenantSyncFailed)
+	}
+
+	var errs []error
+	active := make(map[int64]struct{}, len(tenantIDs))
+	for _, tenantID := range tenantIDs {
+		active[tenantID] = struct{}{}
+		if err := m.syncTenant(ctx, tenantID); err != nil {
+			errs = append(errs, fmt.Errorf("tenant %d: %w", tenantID, err))
+		}
+	}
+
+	m.mtx.RLock()
+	removed := make(map[int64]*GrafanaAlertmanager)
+	for tenantID, am := range m.alertmanagers {
+		if _, ok := active[tenantID]; !ok {
+			removed[tenantID] = am
+		}
+	}
+	m.mtx.RUnlock()
+
+	for tenantID, am := range removed {
+		// The Alertmanager of a tenant added after the tenants were listed may have been created in the meantime.
+		if _, err := m.config.Store.GetConfiguration(ctx, tenantID); !errors.Is(err, ErrNoAlertmanagerForTenant) {
+			if err != nil {
+				errs = append(errs, fmt.Errorf("tenant %d: %w", tenantID, err))
+			}
+			continue
+		}
+
+		m.mtx.Lock()
+		if m.alertmanagers[tenantID] == am {
+			delete(m.alertmanagers, tenantID)
+		}
+		m.mtx.Unlock()
+
+		if err := m.cleanupTenant(ctx, tenantID, am); err != nil {
+			errs = append(errs, fmt.Errorf("tenant %d: %w", tenantID, err))
+		}
+	}
+
+	m.mtx.Lock()
+	m.synced = true
+	m.mtx.Unlock()
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%w: %w", errors.Join(errs...), ErrTenantSyncFailed)
+	}
+	return nil
+}
+
+// syncTenant applies the configuration of the tenant, creating its Alertmanager if it does not exist.
+func (m *MultiTenantAlertmanager) syncTenant(ctx context.Context, tenantID int64) error {
+	cfg, err := m.config.Store.GetConfiguration(ctx, tenantID)
+	if err != nil {
+		return err
+	}
+
+	am, _, err := m.getOrCreateAlertmanager(tenantID)
+	if err != nil {
+		return err
+	}
+
+	return applyConfigIfChanged(am, cfg)
+}
+
+// getOrCreateAlertmanager returns the Alertmanager of the tenant, creating it if it does not exist, and whether it was
+// created. The configuration of a new Alertmanager must be applied by the caller.
+func (m *MultiTenantAlertmanager) getOrCreateAlertmanager(tenantID int64) (*GrafanaAlertmanager, bool, error) {
+	m.mtx.RLock()
+	am, ok := m.alertmanagers[tenantID]
+	m.mtx.RUnlock()
+	if ok {
+		return am, false, nil
+	}
+
+	m.createMtx.Lock()
+	defer m.createMtx.Unlock()
+
+	// The Alertmanager may have been created in the meantime.
+	m.mtx.RLock()
+	am, ok = m.alertmanagers[tenantID]
+	m.mtx.RUnlock()
+	if ok {
+		return am, false, nil
+	}
+
+	am, err := m.newAlertmanager(tenantID)
+	if err != nil {
+		return nil, false, err
+	}
+	m.mtx.Lock()
+	m.alertmanagers[tenantID] = am
+	m.mtx.Unlock()
+	return am, true, nil
+}
+
+// cleanupTenant stops the Alertmanager of a removed tenant, stops replicating its state and deletes it.
+func (m *MultiTenantAlertmanager) cleanupTenant(ctx context.Context, tenantID int64, am *GrafanaAlertmanager) error {
+	am.StopAndWait()
+	am.removeStates()
+	level.Info(m.logger).Log("msg", "Stopped the alertmanager of a removed tenant", m.config.TenantKey, tenantID)
+
+	if m.config.StateStore == nil {
+		return nil
+	}
+	if err := m.config.StateStore.DeleteState(ctx, tenantID); err != nil {
+		return fmt.Errorf("unable to delete the state of the alertmanager: %w", err)
+	}
+	return nil
+}
+
+// newAlertmanager creates the Alertmanager of the tenant. Its configuration must be applied by the caller.
+func (m *MultiTenantAlertmanager) newAlertmanager(tenantID int64) (*GrafanaAlertmanager, error) {
+	amConfig, err := m.config.AlertmanagerConfig(tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	var reg prometheus.Registerer = prometheus.NewRegistry()
+	if m.config.Registerer != nil {
+		reg = m.config.Registerer(tenantID)
+	}
+	am, err := NewGrafanaAlertmanager(m.config.TenantKey, tenantID, amConfig, m.peer, m.logger, NewGrafanaAlertmanagerMetrics(reg, m.logger))
+	if err != nil {
+		return nil, err
+	}
+	level.Info(m.logger).Log("msg", "Created the alertmanager of a tenant", m.config.TenantKey, tenantID)
+	return am, nil
+}
+
+// applyConfigIfChanged applies the configuration if the Alertmanager is not ready or runs a configuration with
+// another hash.
+func applyConfigIfChanged(am *GrafanaAlertmanager, cfg Configuration) error {
+	var err error
+	am.WithLock(func() {
+		if am.ready() && am.ConfigHash() == cfg.Hash() {
+			return
+		}
+		err = am.ApplyConfig(cfg)
+	})
+	return err
+}
+
+// Alertmanager returns the Alertmanager of the tenant, creating it if the tenant has a configuration in the store but
+// was not synced yet. It returns ErrNoAlertmanagerForTenant if the tenant has no Alertmanager. A new Alertmanager is
+// returned even if its configuration cannot be applied, in which case it is not ready until the next sync applies it.
+func (m *MultiTenantAlertmanager) Alertmanager(ctx context.Context, tenantID int64) (*GrafanaAlertmanager, error) {
+	m.mtx.RLock()
+	am, ok := m.alertmanagers[tenantID]
+	m.mtx.RUnlock()
+	if ok {
+		return am, nil
+	}
+
+	cfg, err := m.config.Store.GetConfiguration(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	// The tenant may have been created in the meantime, in which case its configuration is applied by its creator.
+	am, created, err := m.getOrCreateAlertmanager(tenantID)
+	if err != nil {
+		return nil, err
+	}
+	if !created {
+		return am, nil
+	}
+
+	// The configuration is applied without the lock, so that other tenants are not blocked in the meantime.
+	if err := applyConfigIfChanged(am, cfg); err != nil {
+		level.Error(m.logger).Log("msg", "failed to apply the configuration of a tenant", m.config.TenantKey, tenantID, "err", err)
+	}
+	return am, nil
+}
+
+// Tenants returns the IDs of the tenants that have a running Alertmanager, in ascending order.
+func (m *MultiTenantAlertmanager) Tenants() []int64 {
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+
+	res := make([]int64, 0, len(m.alertmanagers))
+	for tenantID := range m.alertmanagers {
+		res = append(res, tenantID)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i] < res[j] })
+	return res
+}
+
+// Ready returns true once tenants were synced, the cluster peer is ready, and the Alertmanagers of all tenants have
+// applied a configuration.
+func (m *MultiTenantAlertmanager) Ready() bool {
+	if p, ok := m.peer.(statusPeer); ok && p.Status() != amv2.ClusterStatusStatusReady {
+		return false
+	}
+
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+
+	if !m.synced {
+		return false
+	}
+	for _, am := range m.alertmanagers {
+		if !am.Ready() {
+			return false
+		}
+	}
+	return true
+}
+
+// StopAndWait stops the Alertmanagers of all tenants, without deleting their state. They are removed first, and stopped
+// concurrently without the lock.
+func (m *MultiTenantAlertmanager) StopAndWait() {
+	m.mtx.Lock()
+	alertmanagers := m.alertmanagers
+	m.alertmanagers = make(map[int64]*GrafanaAlertmanager)
+	m.mtx.Unlock()
+
+	var wg sync.WaitGroup
+	for _, am := range alertmanagers {
+		wg.Add(1)
+		go func(am *GrafanaAlertmanager) {
+			defer wg.Done()
+			am.StopAndWait()
+		}(am)
+	}
+	wg.Wait()
+}
+
 // NilPeer and NilChannel implements the Alertmanager clustering interface.
 type NilPeer struct{}
 
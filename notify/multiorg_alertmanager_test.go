@@ -0,0 +1,335 @@
+package notify
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/alerting/cluster"
+	"github.com/grafana/alerting/templates"
+)
+
+type hashedConfiguration struct {
+	*testConfiguration
+	hash [16]byte
+}
+
+func (c *hashedConfiguration) Hash() [16]byte { return c.hash }
+
+type fakeTenantStore struct {
+	mtx     sync.Mutex
+	configs map[int64]Configuration
+	deleted []int64
+}
+
+func (s *fakeTenantStore) ListTenants(context.Context) ([]int64, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	res := make([]int64, 0, len(s.configs))
+	for tenantID := range s.configs {
+		res = append(res, tenantID)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i] < res[j] })
+	return res, nil
+}
+
+func (s *fakeTenantStore) GetConfiguration(_ context.Context, tenantID int64) (Configuration, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	cfg, ok := s.configs[tenantID]
+	if !ok {
+		return nil, ErrNoAlertmanagerForTenant
+	}
+	return cfg, nil
+}
+
+func (s *fakeTenantStore) DeleteState(_ context.Context, tenantID int64) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.deleted = append(s.deleted, tenantID)
+	return nil
+}
+
+func (s *fakeTenantStore) set(tenantID int64, cfg Configuration) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if cfg == nil {
+		delete(s.configs, tenantID)
+		return
+	}
+	s.configs[tenantID] = cfg
+}
+
+func TestMultiTenantAlertmanager(t *testing.T) {
+	newConfig := func(receiver string, hash byte) Configuration {
+		return &hashedConfiguration{
+			testConfiguration: &testConfiguration{
+				receivers: []*APIReceiver{{ConfigReceiver: ConfigReceiver{Name: receiver}}},
+				route:     &Route{Receiver: receiver, GroupBy: []model.LabelName{"alertname"}},
+			},
+			hash: [16]byte{hash},
+		}
+	}
+
+	store := &fakeTenantStore{configs: map[int64]Configuration{
+		1: newConfig("a", 1),
+		2: newConfig("b", 2),
+	}}
+	m, err := NewMultiTenantAlertmanager(MultiTenantAlertmanagerConfig{
+		Store:      store,
+		StateStore: store,
+		AlertmanagerConfig: func(int64) (*GrafanaAlertmanagerConfig, error) {
+			return &GrafanaAlertmanagerConfig{
+				Silences: newFakeMaintanenceOptions(t),
+				Nflog:    newFakeMaintanenceOptions(t),
+			}, nil
+		},
+	}, nil, log.NewNopLogger())
+	require.NoError(t, err)
+	t.Cleanup(m.StopAndWait)
+
+	require.False(t, m.Ready())
+	require.NoError(t, m.Sync(context.Background()))
+	require.True(t, m.Ready())
+	require.Equal(t, []int64{1, 2}, m.Tenants())
+
+	am1, err := m.Alertmanager(context.Background(), 1)
+	require.NoError(t, err)
+	require.Equal(t, [16]byte{1}, am1.ConfigHash())
+	appliedAt := am1.GetStatus().Config.AppliedAt
+
+	// Configurations with the same hash are not applied again.
+	require.NoError(t, m.Sync(context.Background()))
+	require.Equal(t, appliedAt, am1.GetStatus().Config.AppliedAt)
+
+	store.set(1, newConfig("c", 3))
+	require.NoError(t, m.Sync(context.Background()))
+	require.Equal(t, [16]byte{3}, am1.ConfigHash())
+	receivers := am1.GetReceivers()
+	require.Len(t, receivers, 1)
+	require.Equal(t, "c", receivers[0].Name)
+
+	// Invalid configurations fail the sync of their tenant only.
+	invalid := newConfig("d", 4)
+	invalid.(*hashedConfiguration).templates = []templates.TemplateDefinition{{Name: "invalid", Template: `{{ define "invalid" }}`}}
+	store.set(2, invalid)
+	require.ErrorIs(t, m.Sync(context.Background()), ErrTenantSyncFailed)
+	am2, err := m.Alertmanager(context.Background(), 2)
+	require.NoError(t, err)
+	require.Equal(t, [16]byte{2}, am2.ConfigHash())
+
+	// Removed tenants are stopped and their state is deleted.
+	store.set(2, nil)
+	require.NoError(t, m.Sync(context.Background()))
+	require.Equal(t, []int64{1}, m.Tenants())
+	require.Equal(t, []int64{2}, store.deleted)
+	_, err = m.Alertmanager(context.Background(), 2)
+	require.ErrorIs(t, err, ErrNoAlertmanagerForTenant)
+
+	// Alertmanagers are created on demand.
+	store.set(3, newConfig("e", 5))
+	am3, err := m.Alertmanager(context.Background(), 3)
+	require.NoError(t, err)
+	require.True(t, am3.Ready())
+	require.Equal(t, []int64{1, 3}, m.Tenants())
+	require.True(t, m.Ready())
+}
+
+// staleListStore lists the tenants the store had when it was created, like a list that raced with changes to the store.
+type staleListStore struct {
+	*fakeTenantStore
+	listed []int64
+}
+
+func (s *staleListStore) ListTenants(context.Context) ([]int64, error) {
+	return s.listed, nil
+}
+
+// blockingConfiguration blocks building its integrations until it is released.
+type blockingConfiguration struct {
+	Configuration
+	building chan struct{}
+	release  chan struct{}
+}
+
+func (c *blockingConfiguration) BuildReceiverIntegrationsFunc() func(next *APIReceiver, tmpl *templates.Template) ([]*Integration, error) {
+	build := c.Configuration.BuildReceiverIntegrationsFunc()
+	return func(next *APIReceiver, tmpl *templates.Template) ([]*Integration, error) {
+		close(c.building)
+		<-c.release
+		return build(next, tmpl)
+	}
+}
+
+type stateRecordingPeer struct {
+	NilPeer
+	mtx     sync.Mutex
+	states  map[string]cluster.State
+	removed []string
+}
+
+func (p *stateRecordingPeer) AddState(key string, s cluster.State, _ prometheus.Registerer) cluster.ClusterChannel {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	p.states[key] = s
+	return &NilChannel{}
+}
+
+func (p *stateRecordingPeer) state(key string) cluster.State {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	return p.states[key]
+}
+
+type stateRemovingRecordingPeer struct {
+	*stateRecordingPeer
+}
+
+func (p *stateRemovingRecordingPeer) RemoveState(key string) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	delete(p.states, key)
+	p.removed = append(p.removed, key)
+}
+
+func TestMultiTenantAlertmanager_Cleanup(t *testing.T) {
+	newConfig := func() Configuration {
+		return &testConfiguration{
+			receivers: []*APIReceiver{{ConfigReceiver: ConfigReceiver{Name: "a"}}},
+			route:     &Route{Receiver: "a"},
+		}
+	}
+	newMultiTenantAlertmanager := func(t *testing.T, store TenantConfigStore, peer ClusterPeer) *MultiTenantAlertmanager {
+		m, err := NewMultiTenantAlertmanager(MultiTenantAlertmanagerConfig{
+			Store: store,
+			AlertmanagerConfig: func(int64) (*GrafanaAlertmanagerConfig, error) {
+				return &GrafanaAlertmanagerConfig{
+					Silences: newFakeMaintanenceOptions(t),
+					Nflog:    newFakeMaintanenceOptions(t),
+				}, nil
+			},
+		}, peer, log.NewNopLogger())
+		require.NoError(t, err)
+		t.Cleanup(m.StopAndWait)
+		return m
+	}
+	keys := []string{"notificationlog:1", "silences:1", "recurringsilences:1", "escalations:1", "acknowledgements:1"}
+
+	t.Run("states of removed tenants are no longer replicated", func(t *testing.T) {
+		store := &fakeTenantStore{configs: map[int64]Configuration{1: newConfig()}}
+		peer := &stateRecordingPeer{states: map[string]cluster.State{}}
+		m := newMultiTenantAlertmanager(t, store, peer)
+		require.NoError(t, m.Sync(context.Background()))
+		for _, key := range keys {
+			require.NotNil(t, peer.state(key), key)
+		}
+
+		// Peers that cannot remove states get states that hold nothing instead.
+		store.set(1, nil)
+		require.NoError(t, m.Sync(context.Background()))
+		for _, key := range keys {
+			require.Equal(t, removedState{}, peer.state(key), key)
+		}
+	})
+
+	t.Run("states are removed from peers that can remove them", func(t *testing.T) {
+		store := &fakeTenantStore{configs: map[int64]Configuration{1: newConfig()}}
+		peer := &stateRemovingRecordingPeer{&stateRecordingPeer{states: map[string]cluster.State{}}}
+		m := newMultiTenantAlertmanager(t, store, peer)
+		require.NoError(t, m.Sync(context.Background()))
+
+		store.set(1, nil)
+		require.NoError(t, m.Sync(context.Background()))
+		require.ElementsMatch(t, keys, peer.removed)
+		require.Empty(t, peer.states)
+	})
+
+	t.Run("tenants created after they were listed are kept", func(t *testing.T) {
+		store := &staleListStore{fakeTenantStore: &fakeTenantStore{configs: map[int64]Configuration{1: newConfig()}}, listed: []int64{}}
+		m := newMultiTenantAlertmanager(t, store, nil)
+		_, err := m.Alertmanager(context.Background(), 1)
+		require.NoError(t, err)
+
+		require.NoError(t, m.Sync(context.Background()))
+		require.Equal(t, []int64{1}, m.Tenants())
+	})
+
+	t.Run("tenants are not blocked while a configuration is applied", func(t *testing.T) {
+		cfg := &blockingConfiguration{Configuration: newConfig(), building: make(chan struct{}), release: make(chan struct{})}
+		store := &fakeTenantStore{configs: map[int64]Configuration{1: cfg}}
+		m := newMultiTenantAlertmanager(t, store, nil)
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			_, err := m.Alertmanager(context.Background(), 1)
+			require.NoError(t, err)
+		}()
+		<-cfg.building
+		require.Equal(t, []int64{1}, m.Tenants())
+		close(cfg.release)
+		<-done
+	})
+
+	t.Run("tenants are not blocked while an alertmanager is created", func(t *testing.T) {
+		store := &fakeTenantStore{configs: map[int64]Configuration{1: newConfig(), 2: newConfig()}}
+		var (
+			mtx          sync.Mutex
+			created      int
+			creatingOnce sync.Once
+			creating     = make(chan struct{})
+			release      = make(chan struct{})
+		)
+		m, err := NewMultiTenantAlertmanager(MultiTenantAlertmanagerConfig{
+			Store: store,
+			AlertmanagerConfig: func(tenantID int64) (*GrafanaAlertmanagerConfig, error) {
+				if tenantID == 2 {
+					mtx.Lock()
+					created++
+					mtx.Unlock()
+					creatingOnce.Do(func() { close(creating) })
+					<-release
+				}
+				return &GrafanaAlertmanagerConfig{
+					Silences: newFakeMaintanenceOptions(t),
+					Nflog:    newFakeMaintanenceOptions(t),
+				}, nil
+			},
+		}, nil, log.NewNopLogger())
+		require.NoError(t, err)
+		t.Cleanup(m.StopAndWait)
+		_, err = m.Alertmanager(context.Background(), 1)
+		require.NoError(t, err)
+
+		synced := make(chan error)
+		go func() {
+			synced <- m.Sync(context.Background())
+		}()
+		<-creating
+		require.Equal(t, []int64{1}, m.Tenants())
+		_, err = m.Alertmanager(context.Background(), 1)
+		require.NoError(t, err)
+
+		// The alertmanager being created is not created again.
+		requested := make(chan *GrafanaAlertmanager)
+		go func() {
+			am, err := m.Alertmanager(context.Background(), 2)
+			require.NoError(t, err)
+			requested <- am
+		}()
+		close(release)
+		require.NoError(t, <-synced)
+		am2, err := m.Alertmanager(context.Background(), 2)
+		require.NoError(t, err)
+		require.Same(t, am2, <-requested)
+		mtx.Lock()
+		require.Equal(t, 1, created)
+		mtx.Unlock()
+	})
+}
@@ -0,0 +1,178 @@
+package nfstatus
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	defaultCircuitBreakerFailureThreshold = 5
+	defaultCircuitBreakerOpenDuration     = time.Minute
+	defaultCircuitBreakerSuccessThreshold = 1
+)
+
+// ErrCircuitOpen is returned instead of attempting a notification while the circuit breaker of the integration is open.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+type CircuitState string
+
+const (
+	// CircuitClosed is the normal state, in which all notification attempts are made.
+	CircuitClosed CircuitState = "closed"
+	// CircuitOpen is the state after too many consecutive failures, in which notification attempts fail fast.
+	CircuitOpen CircuitState = "open"
+	// CircuitHalfOpen is the state after the circuit was open for long enough, in which a single trial attempt is made at a time.
+	CircuitHalfOpen CircuitState = "half-open"
+)
+
+// Value returns a numeric value for the state, used in metrics.
+func (s CircuitState) Value() float64 {
+	switch s {
+	case CircuitHalfOpen:
+		return 1
+	case CircuitOpen:
+		return 2
+	default:
+		return 0
+	}
+}
+
+// CircuitBreakerConfig configures the circuit breaker of integrations. Zero values are replaced with defaults.
+type CircuitBreakerConfig struct {
+	// FailureThreshold is the number of consecutive failed attempts after which the circuit opens.
+	FailureThreshold int
+	// OpenDuration is how long the circuit stays open before a trial attempt is allowed.
+	OpenDuration time.Duration
+	// SuccessThreshold is the number of consecutive successful trial attempts after which a half-open circuit closes.
+	SuccessThreshold int
+}
+
+type circuitBreaker struct {
+	cfg CircuitBreakerConfig
+	now func() time.Time
+	// onStateChange is called with the new state on every transition, with the lock held.
+	onStateChange func(CircuitState)
+
+	mtx       sync.Mutex
+	state     CircuitState
+	failures  int
+	successes int
+	openedAt  time.Time
+	// trial is whether a trial attempt is in progress while half-open.
+	trial bool
+}
+
+func newCircuitBreaker(cfg CircuitBreakerConfig, onStateChange func(CircuitState)) *circuitBreaker {
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = defaultCircuitBreakerFailureThreshold
+	}
+	if cfg.OpenDuration <= 0 {
+		cfg.OpenDuration = defaultCircuitBreakerOpenDuration
+	}
+	if cfg.SuccessThreshold <= 0 {
+		cfg.SuccessThreshold = defaultCircuitBreakerSuccessThreshold
+	}
+	return &circuitBreaker{
+		cfg:           cfg,
+		now:           time.Now,
+		onStateChange: onStateChange,
+		state:         CircuitClosed,
+	}
+}
+
+// State returns the current state of the circuit.
+func (b *circuitBreaker) State() CircuitState {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	b.checkOpenDuration()
+	return b.state
+}
+
+// allow returns true if a notification attempt can be made. Every allowed attempt must be followed by a call to report.
+func (b *circuitBreaker) allow() bool {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	b.checkOpenDuration()
+	switch b.state {
+	case CircuitOpen:
+		return false
+	case CircuitHalfOpen:
+		if b.trial {
+			return false
+		}
+		b.trial = true
+	}
+	return true
+}
+
+// report records the outcome of an allowed notification attempt.
+func (b *circuitBreaker) report(err error) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	switch b.state {
+	case CircuitClosed:
+		if err == nil {
+			b.failures = 0
+			return
+		}
+		b.failures++
+		if b.failures >= b.cfg.FailureThreshold {
+			b.open()
+		}
+	case CircuitHalfOpen:
+		b.trial = false
+		if err != nil {
+			b.open()
+			return
+		}
+		b.successes++
+		if b.successes >= b.cfg.SuccessThreshold {
+			b.failures = 0
+			b.setState(CircuitClosed)
+		}
+	case CircuitOpen:
+		// The attempt was allowed before the circuit opened, its outcome does not change anything.
+	}
+}
+
+// restore copies the state of another circuit breaker, e.g. the one of the same integration before the configuration
+// was reloaded. A trial attempt in progress is not copied, the next attempt of a half-open circuit is the trial.
+func (b *circuitBreaker) restore(from *circuitBreaker) {
+	from.mtx.Lock()
+	state, failures, successes, openedAt := from.state, from.failures, from.successes, from.openedAt
+	from.mtx.Unlock()
+
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	b.failures = failures
+	b.successes = successes
+	b.openedAt = openedAt
+	b.trial = false
+	b.setState(state)
+}
+
+func (b *circuitBreaker) open() {
+	b.openedAt = b.now()
+	b.successes = 0
+	b.setState(CircuitOpen)
+}
+
+// checkOpenDuration moves an open circuit to half-open once it has been open for long enough. It must be called with the lock held.
+func (b *circuitBreaker) checkOpenDuration() {
+	if b.state == CircuitOpen && b.now().Sub(b.openedAt) >= b.cfg.OpenDuration {
+		b.trial = false
+		b.setState(CircuitHalfOpen)
+	}
+}
+
+func (b *circuitBreaker) setState(s CircuitState) {
+	b.state = s
+	if b.onStateChange != nil {
+		b.onStateChange(s)
+	}
+}
@@ -0,0 +1,116 @@
+package nfstatus
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Now()
+	var transitions []CircuitState
+	b := newCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 2, OpenDuration: time.Minute, SuccessThreshold: 2}, func(s CircuitState) {
+		transitions = append(transitions, s)
+	})
+	b.now = func() time.Time { return now }
+	failure := errors.New("failure")
+
+	// A success resets the consecutive failures.
+	assert.True(t, b.allow())
+	b.report(failure)
+	assert.True(t, b.allow())
+	b.report(nil)
+	assert.True(t, b.allow())
+	b.report(failure)
+	assert.Equal(t, CircuitClosed, b.State())
+
+	// The circuit opens after enough consecutive failures.
+	assert.True(t, b.allow())
+	b.report(failure)
+	assert.Equal(t, CircuitOpen, b.State())
+	assert.False(t, b.allow())
+
+	// It is half-open once it has been open for long enough, and allows a single trial attempt at a time.
+	now = now.Add(time.Minute)
+	assert.Equal(t, CircuitHalfOpen, b.State())
+	assert.True(t, b.allow())
+	assert.False(t, b.allow())
+
+	// A failed trial opens the circuit again.
+	b.report(failure)
+	assert.Equal(t, CircuitOpen, b.State())
+	now = now.Add(30 * time.Second)
+	assert.False(t, b.allow())
+
+	// It closes after enough successful trials.
+	now = now.Add(30 * time.Second)
+	assert.True(t, b.allow())
+	b.report(nil)
+	assert.Equal(t, CircuitHalfOpen, b.State())
+	assert.True(t, b.allow())
+	b.report(nil)
+	assert.Equal(t, CircuitClosed, b.State())
+	assert.True(t, b.allow())
+
+	assert.Equal(t, []CircuitState{CircuitOpen, CircuitHalfOpen, CircuitOpen, CircuitHalfOpen, CircuitClosed}, transitions)
+}
+
+func TestCircuitBreakerDefaults(t *testing.T) {
+	b := newCircuitBreaker(CircuitBreakerConfig{}, nil)
+	assert.Equal(t, CircuitBreakerConfig{
+		FailureThreshold: defaultCircuitBreakerFailureThreshold,
+		OpenDuration:     defaultCircuitBreakerOpenDuration,
+		SuccessThreshold: defaultCircuitBreakerSuccessThreshold,
+	}, b.cfg)
+}
+
+func TestIntegrationCircuitBreaker(t *testing.T) {
+	notifier := &fakeNotifier{retry: true, err: errors.New("An error")}
+	integration := NewIntegrationWithUID(notifier, &fakeResolvedSender{}, "foo", 0, "bar", "baz")
+	assert.Equal(t, CircuitState(""), integration.CircuitBreakerState())
+
+	integration.SetCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 1, OpenDuration: time.Hour})
+	assert.Equal(t, CircuitClosed, integration.CircuitBreakerState())
+
+	retry, err := integration.Notify(context.Background())
+	assert.True(t, retry)
+	assert.EqualError(t, err, "An error")
+	assert.Equal(t, CircuitOpen, integration.CircuitBreakerState())
+
+	// While open, notifications fail fast without being retried or reaching the notifier.
+	notifier.err = nil
+	retry, err = integration.Notify(context.Background())
+	assert.False(t, retry)
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.Equal(t, uint64(1), integration.GetHealth().TotalFailures)
+	assert.Equal(t, uint64(0), integration.GetHealth().TotalSuccesses)
+}
+
+func TestIntegrationRestoreCircuitBreaker(t *testing.T) {
+	notifier := &fakeNotifier{err: errors.New("An error")}
+	prev := NewIntegrationWithUID(notifier, &fakeResolvedSender{}, "foo", 0, "bar", "baz")
+	prev.SetCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 1, OpenDuration: time.Hour})
+	_, _ = prev.Notify(context.Background())
+	assert.Equal(t, CircuitOpen, prev.CircuitBreakerState())
+
+	// The state metric is set as soon as the circuit breaker is created.
+	state := prometheus.NewGauge(prometheus.GaugeOpts{Name: "state"})
+	state.Set(CircuitOpen.Value())
+	integration := NewIntegrationWithUID(notifier, &fakeResolvedSender{}, "foo", 0, "bar", "baz")
+	integration.SetMetrics(&Metrics{CircuitBreakerState: state})
+	integration.SetCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 1, OpenDuration: time.Hour})
+	assert.Equal(t, CircuitClosed.Value(), testutil.ToFloat64(state))
+
+	// An open circuit stays open once restored.
+	integration.RestoreCircuitBreaker(prev)
+	assert.Equal(t, CircuitOpen, integration.CircuitBreakerState())
+	assert.Equal(t, CircuitOpen.Value(), testutil.ToFloat64(state))
+	notifier.err = nil
+	_, err := integration.Notify(context.Background())
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+}
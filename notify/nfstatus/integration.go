@@ -3,6 +3,7 @@ package nfstatus
 import (
 	"context"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/prometheus/alertmanager/notify"
@@ -15,18 +16,29 @@ import (
 type Integration struct {
 	status      *statusCaptureNotifier
 	integration *notify.Integration
+	uid         string
 }
 
 // NewIntegration returns a new integration.
 func NewIntegration(notifier notify.Notifier, rs notify.ResolvedSender, name string, idx int, receiverName string) *Integration {
+	return NewIntegrationWithUID(notifier, rs, name, idx, receiverName, "")
+}
+
+// NewIntegrationWithUID returns a new integration of the integration configuration with the UID.
+func NewIntegrationWithUID(notifier notify.Notifier, rs notify.ResolvedSender, name string, idx int, receiverName string, uid string) *Integration {
 	// Wrap the provided Notifier with our own, which will capture notification attempt errors.
-	status := &statusCaptureNotifier{upstream: notifier}
+	status := &statusCaptureNotifier{
+		upstream:      notifier,
+		deliveryDelay: NewDeliveryDelayTracker(nil),
+		key:           deliveryDelayKey{receiver: receiverName, integration: name, idx: idx},
+	}
 
 	integration := notify.NewIntegration(status, rs, name, idx, receiverName)
 
 	return &Integration{
 		status:      status,
 		integration: integration,
+		uid:         uid,
 	}
 }
 
@@ -57,6 +69,11 @@ func (i *Integration) Index() int {
 	return i.integration.Index()
 }
 
+// UID returns the UID of the integration configuration. It can be empty.
+func (i *Integration) UID() string {
+	return i.uid
+}
+
 // String implements the Stringer interface.
 func (i *Integration) String() string {
 	return i.integration.String()
@@ -67,6 +84,54 @@ func (i *Integration) GetReport() (time.Time, model.Duration, error) {
 	return i.status.GetReport()
 }
 
+// GetHealth returns rolling statistics about the notification attempts.
+func (i *Integration) GetHealth() Health {
+	return i.status.GetHealth()
+}
+
+// SetMetrics sets the metrics the integration reports its notification attempts to.
+// It must be called before the integration is used to send notifications.
+func (i *Integration) SetMetrics(m *Metrics) {
+	i.status.metrics = m
+}
+
+// SetDeliveryDelayTracker replaces the tracker of the alert groups notified by the integration, which is otherwise
+// specific to the integration. It must be called before the integration is used to send notifications.
+func (i *Integration) SetDeliveryDelayTracker(t *DeliveryDelayTracker) {
+	i.status.deliveryDelay = t
+}
+
+// SetCircuitBreaker adds a circuit breaker to the integration. While the circuit is open, notification attempts fail
+// with ErrCircuitOpen without reaching the notifier. It must be called before the integration is used to send notifications.
+func (i *Integration) SetCircuitBreaker(cfg CircuitBreakerConfig) {
+	i.status.breaker = newCircuitBreaker(cfg, i.setCircuitBreakerStateMetric)
+	i.setCircuitBreakerStateMetric(CircuitClosed)
+}
+
+// RestoreCircuitBreaker copies the state of the circuit breaker of another integration, usually the same integration
+// before the configuration was reloaded, so that an open circuit stays open. It does nothing if either integration has
+// no circuit breaker. It must be called before the integration is used to send notifications.
+func (i *Integration) RestoreCircuitBreaker(from *Integration) {
+	if i.status.breaker == nil || from.status.breaker == nil {
+		return
+	}
+	i.status.breaker.restore(from.status.breaker)
+}
+
+func (i *Integration) setCircuitBreakerStateMetric(s CircuitState) {
+	if i.status.metrics != nil && i.status.metrics.CircuitBreakerState != nil {
+		i.status.metrics.CircuitBreakerState.Set(s.Value())
+	}
+}
+
+// CircuitBreakerState returns the state of the circuit breaker of the integration, or an empty string if it has none.
+func (i *Integration) CircuitBreakerState() CircuitState {
+	if i.status.breaker == nil {
+		return ""
+	}
+	return i.status.breaker.State()
+}
+
 // GetIntegrations is a convenience function to unwrap all the notify.GetIntegrations
 // from a slice of nfstatus.Integration.
 func GetIntegrations(integrations []*Integration) []*notify.Integration {
@@ -77,6 +142,31 @@ func GetIntegrations(integrations []*Integration) []*notify.Integration {
 	return result
 }
 
+// errorRateDecay is the weight given to the latest attempt when updating the moving error rate.
+const errorRateDecay = 0.1
+
+// Health contains rolling statistics about the notification attempts of an integration.
+type Health struct {
+	// LastSuccessfulNotifyAttempt is the time of the last notification attempt that succeeded.
+	LastSuccessfulNotifyAttempt time.Time
+	// ConsecutiveFailures is the number of attempts that failed since the last successful one.
+	ConsecutiveFailures int
+	// TotalSuccesses and TotalFailures count all the attempts since the integration was created.
+	TotalSuccesses uint64
+	TotalFailures  uint64
+	// ErrorRate is an exponentially weighted moving average of the failed attempts, between 0 and 1.
+	ErrorRate float64
+}
+
+type attemptsKey struct{}
+
+// WithAttemptsCounter returns a copy of ctx that counts the notification attempts made through any Integration with it.
+// This is useful to know how many times a notification was retried.
+func WithAttemptsCounter(ctx context.Context) (context.Context, *atomic.Int64) {
+	counter := &atomic.Int64{}
+	return context.WithValue(ctx, attemptsKey{}, counter), counter
+}
+
 // statusCaptureNotifier is used to wrap a notify.Notifer and capture information about attempts.
 type statusCaptureNotifier struct {
 	upstream notify.Notifier
@@ -85,14 +175,49 @@ type statusCaptureNotifier struct {
 	lastNotifyAttempt         time.Time
 	lastNotifyAttemptDuration model.Duration
 	lastNotifyAttemptError    error
+	health                    Health
+
+	metrics       *Metrics
+	deliveryDelay *DeliveryDelayTracker
+	// key identifies the integration in the delivery delay tracker, without the group key.
+	key     deliveryDelayKey
+	breaker *circuitBreaker
 }
 
 // Notify implements the Notifier interface.
 func (n *statusCaptureNotifier) Notify(ctx context.Context, alerts ...*types.Alert) (bool, error) {
+	// Attempts rejected by the circuit breaker are not retried, and do not count as attempts.
+	if n.breaker != nil && !n.breaker.allow() {
+		if n.metrics != nil && n.metrics.CircuitBreakerRejections != nil {
+			n.metrics.CircuitBreakerRejections.Inc()
+		}
+		return false, ErrCircuitOpen
+	}
+
+	if counter, ok := ctx.Value(attemptsKey{}).(*atomic.Int64); ok {
+		counter.Add(1)
+	}
+
 	start := time.Now()
 	retry, err := n.upstream.Notify(ctx, alerts...)
 	duration := time.Since(start)
 
+	if n.breaker != nil {
+		n.breaker.report(err)
+	}
+
+	if n.metrics != nil {
+		n.metrics.Attempts.Inc()
+		n.metrics.Latency.Observe(duration.Seconds())
+		if err != nil {
+			n.metrics.Failures.Inc()
+		} else if groupKey, ok := notify.GroupKey(ctx); ok {
+			key := n.key
+			key.groupKey = groupKey
+			n.deliveryDelay.observe(n.metrics.DeliveryDelay, key, start.Add(duration), alerts)
+		}
+	}
+
 	n.mtx.Lock()
 	defer n.mtx.Unlock()
 
@@ -100,6 +225,22 @@ func (n *statusCaptureNotifier) Notify(ctx context.Context, alerts ...*types.Ale
 	n.lastNotifyAttemptDuration = model.Duration(duration)
 	n.lastNotifyAttemptError = err
 
+	failed := 0.0
+	if err != nil {
+		failed = 1
+		n.health.ConsecutiveFailures++
+		n.health.TotalFailures++
+	} else {
+		n.health.LastSuccessfulNotifyAttempt = start
+		n.health.ConsecutiveFailures = 0
+		n.health.TotalSuccesses++
+	}
+	if n.health.TotalSuccesses+n.health.TotalFailures == 1 {
+		n.health.ErrorRate = failed
+	} else {
+		n.health.ErrorRate = errorRateDecay*failed + (1-errorRateDecay)*n.health.ErrorRate
+	}
+
 	return retry, err
 }
 
@@ -110,3 +251,11 @@ func (n *statusCaptureNotifier) GetReport() (time.Time, model.Duration, error) {
 
 	return n.lastNotifyAttempt, n.lastNotifyAttemptDuration, n.lastNotifyAttemptError
 }
+
+// GetHealth returns rolling statistics about the notification attempts.
+func (n *statusCaptureNotifier) GetHealth() Health {
+	n.mtx.RLock()
+	defer n.mtx.RUnlock()
+
+	return n.health
+}
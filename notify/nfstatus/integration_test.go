@@ -31,11 +31,13 @@ func (f *fakeResolvedSender) SendResolved() bool {
 func TestIntegration(t *testing.T) {
 	notifier := &fakeNotifier{}
 	rs := &fakeResolvedSender{}
-	integration := NewIntegration(notifier, rs, "foo", 42, "bar")
+	integration := NewIntegrationWithUID(notifier, rs, "foo", 42, "bar", "baz")
 
 	// Check wrapped functions work as expected.
 	assert.Equal(t, "foo", integration.Name())
 	assert.Equal(t, 42, integration.Index())
+	assert.Equal(t, "baz", integration.UID())
+	assert.Equal(t, "", NewIntegration(notifier, rs, "foo", 42, "bar").UID())
 	rs.sendResolved = false
 	assert.Equal(t, false, integration.SendResolved())
 	rs.sendResolved = true
@@ -76,3 +78,46 @@ func TestIntegration(t *testing.T) {
 	assert.NotEqual(t, model.Duration(0), lastDuration)
 	assert.Equal(t, "An error", lastError.Error())
 }
+
+func TestIntegrationHealth(t *testing.T) {
+	notifier := &fakeNotifier{}
+	integration := NewIntegrationWithUID(notifier, &fakeResolvedSender{}, "foo", 0, "bar", "baz")
+
+	// Check that health is empty if no notifications have happened.
+	assert.Equal(t, Health{}, integration.GetHealth())
+
+	// The error rate starts with the outcome of the first attempt.
+	notifier.err = errors.New("An error")
+	_, _ = integration.Notify(context.Background())
+	health := integration.GetHealth()
+	assert.Equal(t, time.Time{}, health.LastSuccessfulNotifyAttempt)
+	assert.Equal(t, 1, health.ConsecutiveFailures)
+	assert.Equal(t, uint64(0), health.TotalSuccesses)
+	assert.Equal(t, uint64(1), health.TotalFailures)
+	assert.Equal(t, 1.0, health.ErrorRate)
+
+	_, _ = integration.Notify(context.Background())
+	health = integration.GetHealth()
+	assert.Equal(t, 2, health.ConsecutiveFailures)
+	assert.Equal(t, uint64(2), health.TotalFailures)
+	assert.Equal(t, 1.0, health.ErrorRate)
+
+	// A successful attempt resets the consecutive failures and lowers the error rate.
+	notifier.err = nil
+	_, _ = integration.Notify(context.Background())
+	health = integration.GetHealth()
+	lastAttempt, _, _ := integration.GetReport()
+	assert.Equal(t, lastAttempt, health.LastSuccessfulNotifyAttempt)
+	assert.Equal(t, 0, health.ConsecutiveFailures)
+	assert.Equal(t, uint64(1), health.TotalSuccesses)
+	assert.Equal(t, uint64(2), health.TotalFailures)
+	assert.InDelta(t, 0.9, health.ErrorRate, 1e-9)
+
+	// A failed attempt does not change the last successful attempt.
+	notifier.err = errors.New("An error")
+	_, _ = integration.Notify(context.Background())
+	health = integration.GetHealth()
+	assert.Equal(t, lastAttempt, health.LastSuccessfulNotifyAttempt)
+	assert.Equal(t, 1, health.ConsecutiveFailures)
+	assert.InDelta(t, 0.91, health.ErrorRate, 1e-9)
+}
@@ -0,0 +1,117 @@
+package nfstatus
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/alertmanager/nflog"
+	"github.com/prometheus/alertmanager/nflog/nflogpb"
+	"github.com/prometheus/alertmanager/types"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// notifiedGroupsRetention is how long the last delivery of an alert group is remembered for if the group is not notified again.
+// It should be larger than any reasonable repeat_interval.
+const notifiedGroupsRetention = 7 * 24 * time.Hour
+
+// Metrics are the metrics an Integration reports about its notification attempts.
+type Metrics struct {
+	// Attempts counts all the notification attempts.
+	Attempts prometheus.Counter
+	// Failures counts the notification attempts that failed.
+	Failures prometheus.Counter
+	// Latency observes the duration of the notification attempts in seconds.
+	Latency prometheus.Observer
+	// DeliveryDelay observes the seconds between an alert starting to fire and the first successful notification about it.
+	DeliveryDelay prometheus.Observer
+	// CircuitBreakerState is set to the value of the state of the circuit breaker, if any.
+	CircuitBreakerState prometheus.Gauge
+	// CircuitBreakerRejections counts the notification attempts rejected because the circuit breaker was open.
+	CircuitBreakerRejections prometheus.Counter
+}
+
+// NotificationLog is the notification log an Alertmanager records its notifications in.
+type NotificationLog interface {
+	Query(params ...nflog.QueryParam) ([]*nflogpb.Entry, error)
+}
+
+// deliveryDelayKey identifies an alert group notified by an integration.
+type deliveryDelayKey struct {
+	groupKey    string
+	receiver    string
+	integration string
+	idx         int
+}
+
+// DeliveryDelayTracker remembers the last successful notification of each alert group by each integration so that the
+// delivery delay is only observed the first time an alert is notified, not on every repeated notification.
+//
+// It is meant to be shared by all the integrations of an Alertmanager, so that it outlives configuration reloads. Groups
+// it does not know about are looked up in the notification log, if any, so that alerts notified before a restart are
+// not observed again either.
+type DeliveryDelayTracker struct {
+	nflog NotificationLog
+
+	mtx       sync.Mutex
+	groups    map[deliveryDelayKey]time.Time
+	lastSweep time.Time
+}
+
+// NewDeliveryDelayTracker creates a DeliveryDelayTracker. The notification log is optional.
+func NewDeliveryDelayTracker(l NotificationLog) *DeliveryDelayTracker {
+	return &DeliveryDelayTracker{nflog: l, groups: make(map[deliveryDelayKey]time.Time)}
+}
+
+// observe reports the delivery delay of the alerts that started firing after the group was last notified.
+func (t *DeliveryDelayTracker) observe(o prometheus.Observer, key deliveryDelayKey, now time.Time, alerts []*types.Alert) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	if now.Sub(t.lastSweep) > time.Hour {
+		for k, last := range t.groups {
+			if now.Sub(last) > notifiedGroupsRetention {
+				delete(t.groups, k)
+			}
+		}
+		t.lastSweep = now
+	}
+
+	last, ok := t.groups[key]
+	if !ok {
+		last = t.lastNotified(key)
+	}
+	firing := false
+	for _, a := range alerts {
+		if a.Resolved() {
+			continue
+		}
+		firing = true
+		if a.StartsAt.After(last) {
+			o.Observe(now.Sub(a.StartsAt).Seconds())
+		}
+	}
+
+	// Once all the alerts are resolved, any new alert in the group is notified for the first time.
+	if !firing {
+		delete(t.groups, key)
+		return
+	}
+	t.groups[key] = now
+}
+
+// lastNotified returns when the group was last notified with firing alerts according to the notification log, or the
+// zero time if it was not.
+func (t *DeliveryDelayTracker) lastNotified(key deliveryDelayKey) time.Time {
+	if t.nflog == nil {
+		return time.Time{}
+	}
+	entries, err := t.nflog.Query(nflog.QGroupKey(key.groupKey), nflog.QReceiver(&nflogpb.Receiver{
+		GroupName:   key.receiver,
+		Integration: key.integration,
+		Idx:         uint32(key.idx),
+	}))
+	if err != nil || len(entries) == 0 || len(entries[0].FiringAlerts) == 0 {
+		return time.Time{}
+	}
+	return entries[0].Timestamp
+}
@@ -0,0 +1,137 @@
+package nfstatus
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/alertmanager/nflog"
+	"github.com/prometheus/alertmanager/nflog/nflogpb"
+	"github.com/prometheus/alertmanager/notify"
+	"github.com/prometheus/alertmanager/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIntegrationMetrics(t *testing.T) {
+	attempts := prometheus.NewCounter(prometheus.CounterOpts{Name: "attempts"})
+	failures := prometheus.NewCounter(prometheus.CounterOpts{Name: "failures"})
+	latency := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "latency"})
+	delay := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "delay"})
+
+	notifier := &fakeNotifier{}
+	integration := NewIntegrationWithUID(notifier, &fakeResolvedSender{}, "foo", 0, "bar", "baz")
+	integration.SetMetrics(&Metrics{
+		Attempts:      attempts,
+		Failures:      failures,
+		Latency:       latency,
+		DeliveryDelay: delay,
+	})
+
+	now := time.Now()
+	firing := func(name string, startsAt time.Time) *types.Alert {
+		return &types.Alert{Alert: model.Alert{
+			Labels:   model.LabelSet{"alertname": model.LabelValue(name)},
+			StartsAt: startsAt,
+			EndsAt:   now.Add(time.Hour),
+		}}
+	}
+	delayCount := func() uint64 {
+		reg := prometheus.NewPedanticRegistry()
+		require.NoError(t, reg.Register(delay))
+		mfs, err := reg.Gather()
+		require.NoError(t, err)
+		return mfs[0].GetMetric()[0].GetHistogram().GetSampleCount()
+	}
+
+	ctx := notify.WithGroupKey(context.Background(), "group")
+	a1 := firing("a1", now.Add(-time.Minute))
+
+	// Failed attempts are counted, but the delivery delay is not observed.
+	notifier.err = errors.New("An error")
+	_, _ = integration.Notify(ctx, a1)
+	assert.Equal(t, 1.0, testutil.ToFloat64(attempts))
+	assert.Equal(t, 1.0, testutil.ToFloat64(failures))
+	assert.Equal(t, uint64(0), delayCount())
+
+	// The first successful notification observes the delivery delay.
+	notifier.err = nil
+	_, _ = integration.Notify(ctx, a1)
+	assert.Equal(t, 2.0, testutil.ToFloat64(attempts))
+	assert.Equal(t, 1.0, testutil.ToFloat64(failures))
+	assert.Equal(t, uint64(1), delayCount())
+
+	// Repeated notifications only observe the delivery delay of new alerts.
+	a2 := firing("a2", time.Now())
+	_, _ = integration.Notify(ctx, a1, a2)
+	assert.Equal(t, uint64(2), delayCount())
+
+	// Once the group is resolved, new alerts are observed again.
+	resolved := *a1
+	resolved.EndsAt = time.Now()
+	_, _ = integration.Notify(ctx, &resolved)
+	assert.Equal(t, uint64(2), delayCount())
+	_, _ = integration.Notify(ctx, a1)
+	assert.Equal(t, uint64(3), delayCount())
+}
+
+type fakeNotificationLog struct {
+	entries []*nflogpb.Entry
+}
+
+func (l *fakeNotificationLog) Query(_ ...nflog.QueryParam) ([]*nflogpb.Entry, error) {
+	if len(l.entries) == 0 {
+		return nil, nflog.ErrNotFound
+	}
+	return l.entries, nil
+}
+
+func TestDeliveryDelayTracker(t *testing.T) {
+	delay := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "delay"})
+	now := time.Now()
+	alert := &types.Alert{Alert: model.Alert{
+		Labels:   model.LabelSet{"alertname": "a"},
+		StartsAt: now.Add(-time.Hour),
+		EndsAt:   now.Add(time.Hour),
+	}}
+
+	// Groups that were notified before the tracker was created, such as before a restart, are looked up in the
+	// notification log.
+	l := &fakeNotificationLog{entries: []*nflogpb.Entry{{Timestamp: now.Add(-time.Minute), FiringAlerts: []uint64{1}}}}
+	tracker := NewDeliveryDelayTracker(l)
+	key := deliveryDelayKey{groupKey: "group", receiver: "bar", integration: "foo"}
+	tracker.observe(delay, key, now, []*types.Alert{alert})
+	require.Equal(t, uint64(0), histogramCount(t, delay))
+
+	// The tracker is shared by integrations, which are recreated when the configuration is reloaded.
+	notifier := &fakeNotifier{}
+	integration := NewIntegrationWithUID(notifier, &fakeResolvedSender{}, "foo", 0, "bar", "baz")
+	integration.SetMetrics(&Metrics{
+		Attempts:      prometheus.NewCounter(prometheus.CounterOpts{Name: "attempts"}),
+		Failures:      prometheus.NewCounter(prometheus.CounterOpts{Name: "failures"}),
+		Latency:       prometheus.NewHistogram(prometheus.HistogramOpts{Name: "latency"}),
+		DeliveryDelay: delay,
+	})
+	integration.SetDeliveryDelayTracker(tracker)
+	l.entries = nil
+	_, err := integration.Notify(notify.WithGroupKey(context.Background(), "group"), alert)
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), histogramCount(t, delay))
+
+	// Groups that are unknown to both are notified for the first time.
+	_, err = integration.Notify(notify.WithGroupKey(context.Background(), "other"), alert)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), histogramCount(t, delay))
+}
+
+func histogramCount(t *testing.T, h prometheus.Histogram) uint64 {
+	t.Helper()
+	m := &dto.Metric{}
+	require.NoError(t, h.Write(m))
+	return m.GetHistogram().GetSampleCount()
+}
@@ -1,10 +1,21 @@
 package nfstatus
 
+import (
+	"sync"
+	"time"
+)
+
 // Receiver holds onto a slice of nfstatus.Integration and some metadata.
 type Receiver struct {
 	name         string
 	integrations []*Integration
 	active       bool
+
+	// fallback is the name of the receiver notified when all integrations fail, if any.
+	fallback string
+
+	mtx          sync.Mutex
+	lastFallback time.Time
 }
 
 func (r *Receiver) Name() string {
@@ -19,6 +30,30 @@ func (r *Receiver) Integrations() []*Integration {
 	return r.integrations
 }
 
+// Fallback returns the name of the receiver notified when all integrations of this receiver fail, or an empty string.
+func (r *Receiver) Fallback() string {
+	return r.fallback
+}
+
+// SetFallback sets the name of the fallback receiver. It must be called before the receiver is used.
+func (r *Receiver) SetFallback(name string) {
+	r.fallback = name
+}
+
+// ReportFallback records that a notification was sent to the fallback receiver at the given time.
+func (r *Receiver) ReportFallback(t time.Time) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.lastFallback = t
+}
+
+// LastFallback returns the last time a notification was sent to the fallback receiver.
+func (r *Receiver) LastFallback() time.Time {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return r.lastFallback
+}
+
 func NewReceiver(name string, active bool, integrations []*Integration) *Receiver {
 	return &Receiver{
 		name:         name,
@@ -0,0 +1,195 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/alertmanager/notify"
+	"github.com/prometheus/alertmanager/types"
+
+	"github.com/grafana/alerting/notify/nfstatus"
+)
+
+// defaultNotificationHistorySize is the number of notification history entries kept when no size is configured.
+const defaultNotificationHistorySize = 1000
+
+var (
+	ErrNotificationHistoryBadPayload = fmt.Errorf("unable to retrieve notification history")
+)
+
+type NotificationStatus string
+
+const (
+	NotificationStatusSuccess NotificationStatus = "success"
+	NotificationStatusFailed  NotificationStatus = "failed"
+)
+
+// NotificationHistoryEntry is the outcome of delivering a notification for an alert group through an integration, including retries.
+type NotificationHistoryEntry struct {
+	Timestamp        time.Time          `json:"timestamp"`
+	Receiver         string             `json:"receiver"`
+	Integration      string             `json:"integration"`
+	IntegrationIndex int                `json:"integrationIndex"`
+	IntegrationUID   string             `json:"integrationUID,omitempty"`
+	GroupKey         string             `json:"groupKey"`
+	Fingerprints     []string           `json:"fingerprints"`
+	Status           NotificationStatus `json:"status"`
+	Duration         time.Duration      `json:"duration"`
+	Retries          int                `json:"retries"`
+	Error            string             `json:"error,omitempty"`
+}
+
+// NotificationHistoryQuery filters the notification history. The zero value returns all the entries.
+type NotificationHistoryQuery struct {
+	// From and To restrict the entries to those recorded within the time range. A zero value leaves that end of the range open.
+	From time.Time
+	To   time.Time
+	// Receivers is a regular expression that the receiver name must fully match, as in GetAlerts.
+	Receivers string
+	// Status restricts the entries to the given outcome.
+	Status NotificationStatus
+}
+
+// NotificationHistory returns the entries of the notification history that match the query, most recent first.
+// It is safe to call concurrently.
+func (am *GrafanaAlertmanager) NotificationHistory(q NotificationHistoryQuery) ([]NotificationHistoryEntry, error) {
+	receiverFilter, err := parseReceivers(q.Receivers)
+	if err != nil {
+		level.Error(am.logger).Log("msg", "failed to parse receiver regex", "err", err)
+		return nil, fmt.Errorf("%s: %w", err.Error(), ErrNotificationHistoryBadPayload)
+	}
+
+	switch q.Status {
+	case "", NotificationStatusSuccess, NotificationStatusFailed:
+	default:
+		return nil, fmt.Errorf("unknown notification status %q: %w", q.Status, ErrNotificationHistoryBadPayload)
+	}
+
+	res := make([]NotificationHistoryEntry, 0)
+	for _, e := range am.notificationHistory.entries() {
+		if !q.From.IsZero() && e.Timestamp.Before(q.From) {
+			continue
+		}
+		if !q.To.IsZero() && e.Timestamp.After(q.To) {
+			continue
+		}
+		if receiverFilter != nil && !receiverFilter.MatchString(e.Receiver) {
+			continue
+		}
+		if q.Status != "" && e.Status != q.Status {
+			continue
+		}
+		res = append(res, e)
+	}
+
+	return res, nil
+}
+
+// notificationHistory is a bounded log of notification outcomes. Once full, the oldest entries are overwritten.
+type notificationHistory struct {
+	mtx  sync.RWMutex
+	buf  []NotificationHistoryEntry
+	next int
+	full bool
+}
+
+func newNotificationHistory(size int) *notificationHistory {
+	if size <= 0 {
+		size = defaultNotificationHistorySize
+	}
+	return &notificationHistory{buf: make([]NotificationHistoryEntry, size)}
+}
+
+func (h *notificationHistory) add(e NotificationHistoryEntry) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	h.buf[h.next] = e
+	h.next = (h.next + 1) % len(h.buf)
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+// entries returns a copy of the entries, most recent first.
+func (h *notificationHistory) entries() []NotificationHistoryEntry {
+	h.mtx.RLock()
+	defer h.mtx.RUnlock()
+
+	n := h.next
+	if h.full {
+		n = len(h.buf)
+	}
+
+	res := make([]NotificationHistoryEntry, 0, n)
+	for i := 1; i <= n; i++ {
+		res = append(res, h.buf[(h.next-i+len(h.buf))%len(h.buf)])
+	}
+	return res
+}
+
+// notificationHistoryStage wraps the stage delivering notifications through an integration, recording its outcome in the notification history
+// and emitting it as an event.
+type notificationHistoryStage struct {
+	next        notify.Stage
+	history     *notificationHistory
+	events      *eventBus
+	receiver    string
+	integration *nfstatus.Integration
+}
+
+func newNotificationHistoryStage(next notify.Stage, history *notificationHistory, events *eventBus, receiver string, integration *nfstatus.Integration) *notificationHistoryStage {
+	return &notificationHistoryStage{
+		next:        next,
+		history:     history,
+		events:      events,
+		receiver:    receiver,
+		integration: integration,
+	}
+}
+
+// Exec implements the Stage interface.
+func (s *notificationHistoryStage) Exec(ctx context.Context, l log.Logger, alerts ...*types.Alert) (context.Context, []*types.Alert, error) {
+	ctx, attempts := nfstatus.WithAttemptsCounter(ctx)
+
+	start := time.Now()
+	ctx, sent, err := s.next.Exec(ctx, l, alerts...)
+
+	// Nothing was sent, e.g. the integration does not send resolved notifications and all the alerts are resolved.
+	if attempts.Load() == 0 && err == nil {
+		return ctx, sent, err
+	}
+
+	groupKey, _ := notify.GroupKey(ctx)
+	e := NotificationHistoryEntry{
+		Timestamp:        start,
+		Receiver:         s.receiver,
+		Integration:      s.integration.Name(),
+		IntegrationIndex: s.integration.Index(),
+		IntegrationUID:   s.integration.UID(),
+		GroupKey:         groupKey,
+		Fingerprints:     make([]string, 0, len(alerts)),
+		Status:           NotificationStatusSuccess,
+		Duration:         time.Since(start),
+	}
+	if n := int(attempts.Load()); n > 1 {
+		e.Retries = n - 1
+	}
+	for _, a := range alerts {
+		e.Fingerprints = append(e.Fingerprints, a.Fingerprint().String())
+	}
+	eventType := EventNotificationSent
+	if err != nil {
+		e.Status = NotificationStatusFailed
+		e.Error = err.Error()
+		eventType = EventNotificationFailed
+	}
+	s.history.add(e)
+	s.events.publish(Event{Type: eventType, Timestamp: start, Notification: &e})
+
+	return ctx, sent, err
+}
@@ -0,0 +1,163 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/alertmanager/featurecontrol"
+	"github.com/prometheus/alertmanager/notify"
+	"github.com/prometheus/alertmanager/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/alerting/notify/nfstatus"
+)
+
+// failingNotifier fails the first failures notifications with a retryable error.
+type failingNotifier struct {
+	failures int
+	calls    int
+}
+
+func (f *failingNotifier) Notify(_ context.Context, _ ...*types.Alert) (bool, error) {
+	f.calls++
+	if f.calls <= f.failures {
+		return true, errors.New("failed to notify")
+	}
+	return false, nil
+}
+
+func (f *failingNotifier) SendResolved() bool {
+	return true
+}
+
+func TestNotificationHistoryStage(t *testing.T) {
+	history := newNotificationHistory(10)
+	stageMetrics := notify.NewMetrics(prometheus.NewRegistry(), featurecontrol.NoopFlags{})
+	alert := &types.Alert{Alert: model.Alert{
+		Labels:   model.LabelSet{"alertname": "test"},
+		StartsAt: time.Now(),
+		EndsAt:   time.Now().Add(time.Hour),
+	}}
+
+	t.Run("successful notification after retries", func(t *testing.T) {
+		n := &failingNotifier{failures: 1}
+		integration := nfstatus.NewIntegrationWithUID(n, n, "webhook", 0, "receiver", "webhook-uid")
+		stage := newNotificationHistoryStage(notify.NewRetryStage(integration.Integration(), "receiver", stageMetrics), history, nil, "receiver", integration)
+
+		ctx := notify.WithGroupKey(context.Background(), "group-1")
+		_, _, err := stage.Exec(ctx, log.NewNopLogger(), alert)
+		require.NoError(t, err)
+
+		entries := history.entries()
+		require.Len(t, entries, 1)
+		require.Equal(t, "receiver", entries[0].Receiver)
+		require.Equal(t, "webhook", entries[0].Integration)
+		require.Equal(t, 0, entries[0].IntegrationIndex)
+		require.Equal(t, "webhook-uid", entries[0].IntegrationUID)
+		require.Equal(t, "group-1", entries[0].GroupKey)
+		require.Equal(t, []string{alert.Fingerprint().String()}, entries[0].Fingerprints)
+		require.Equal(t, NotificationStatusSuccess, entries[0].Status)
+		require.Equal(t, 1, entries[0].Retries)
+		require.Empty(t, entries[0].Error)
+	})
+
+	t.Run("failed notification", func(t *testing.T) {
+		n := &fakeNotifierWithError{err: errors.New("unrecoverable")}
+		integration := nfstatus.NewIntegrationWithUID(n, n, "slack", 1, "other", "slack-uid")
+		stage := newNotificationHistoryStage(notify.NewRetryStage(integration.Integration(), "other", stageMetrics), history, nil, "other", integration)
+
+		ctx := notify.WithGroupKey(context.Background(), "group-2")
+		_, _, err := stage.Exec(ctx, log.NewNopLogger(), alert)
+		require.Error(t, err)
+
+		entries := history.entries()
+		require.Len(t, entries, 2)
+		require.Equal(t, "other", entries[0].Receiver)
+		require.Equal(t, NotificationStatusFailed, entries[0].Status)
+		require.Equal(t, 0, entries[0].Retries)
+		require.Contains(t, entries[0].Error, "unrecoverable")
+	})
+}
+
+type fakeNotifierWithError struct {
+	err error
+}
+
+func (f *fakeNotifierWithError) Notify(_ context.Context, _ ...*types.Alert) (bool, error) {
+	return false, f.err
+}
+
+func (f *fakeNotifierWithError) SendResolved() bool {
+	return true
+}
+
+func TestNotificationHistory(t *testing.T) {
+	am, _ := setupAMTest(t)
+	am.notificationHistory = newNotificationHistory(3)
+
+	now := time.Now()
+	for _, e := range []NotificationHistoryEntry{
+		{Timestamp: now.Add(-4 * time.Hour), Receiver: "dropped", Status: NotificationStatusSuccess},
+		{Timestamp: now.Add(-3 * time.Hour), Receiver: "slack", Status: NotificationStatusSuccess},
+		{Timestamp: now.Add(-2 * time.Hour), Receiver: "slack", Status: NotificationStatusFailed},
+		{Timestamp: now.Add(-1 * time.Hour), Receiver: "email", Status: NotificationStatusSuccess},
+	} {
+		am.notificationHistory.add(e)
+	}
+
+	receivers := func(entries []NotificationHistoryEntry) []string {
+		res := make([]string, 0, len(entries))
+		for _, e := range entries {
+			res = append(res, e.Receiver+"/"+string(e.Status))
+		}
+		return res
+	}
+
+	cases := []struct {
+		name   string
+		query  NotificationHistoryQuery
+		exp    []string
+		expErr error
+	}{{
+		name:  "all entries, most recent first",
+		query: NotificationHistoryQuery{},
+		exp:   []string{"email/success", "slack/failed", "slack/success"},
+	}, {
+		name:  "time range",
+		query: NotificationHistoryQuery{From: now.Add(-150 * time.Minute), To: now.Add(-30 * time.Minute)},
+		exp:   []string{"email/success", "slack/failed"},
+	}, {
+		name:  "receiver",
+		query: NotificationHistoryQuery{Receivers: "sl.*"},
+		exp:   []string{"slack/failed", "slack/success"},
+	}, {
+		name:  "status",
+		query: NotificationHistoryQuery{Status: NotificationStatusFailed},
+		exp:   []string{"slack/failed"},
+	}, {
+		name:   "invalid receiver regex",
+		query:  NotificationHistoryQuery{Receivers: "("},
+		expErr: ErrNotificationHistoryBadPayload,
+	}, {
+		name:   "unknown status",
+		query:  NotificationHistoryQuery{Status: "unknown"},
+		expErr: ErrNotificationHistoryBadPayload,
+	}}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			entries, err := am.NotificationHistory(c.query)
+			if c.expErr != nil {
+				require.ErrorIs(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.exp, receivers(entries))
+		})
+	}
+}
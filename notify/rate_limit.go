@@ -0,0 +1,335 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/alertmanager/notify"
+	"github.com/prometheus/alertmanager/types"
+	"github.com/prometheus/common/model"
+
+	"github.com/grafana/alerting/definition"
+	"github.com/grafana/alerting/notify/nfstatus"
+	"github.com/grafana/alerting/templates"
+)
+
+const (
+	// rateLimitSummaryAlertName is the alert name of the summary notification of a rate limit.
+	rateLimitSummaryAlertName = "AlertGroupsSuppressed"
+	// rateLimitSummaryTimeout is how long the summary notification of a rate limit is retried for.
+	rateLimitSummaryTimeout = time.Minute
+)
+
+// RateLimitSummaryData is the data the summary notification template of a rate limit is executed with.
+type RateLimitSummaryData struct {
+	Receiver    string
+	Integration string
+	// Count is the number of alert groups that were not notified during the interval.
+	Count int
+	// Groups are the alert groups that were not notified during the interval, sorted by group key.
+	Groups []SuppressedAlertGroup
+}
+
+// SuppressedAlertGroup is an alert group that was not notified because of a rate limit.
+type SuppressedAlertGroup struct {
+	GroupKey    string
+	GroupLabels templates.KV
+	// Alerts is the number of alerts of the last notification of the group that was suppressed.
+	Alerts int
+}
+
+// validateRateLimits checks that the rate limits of every receiver and integration are valid.
+func validateRateLimits(receivers []*APIReceiver) error {
+	for _, r := range receivers {
+		if r.RateLimit != nil {
+			if err := r.RateLimit.Validate(); err != nil {
+				return fmt.Errorf("invalid rate limit of receiver %q: %w", r.Name, err)
+			}
+		}
+		for _, i := range r.Integrations {
+			if i.RateLimit == nil {
+				continue
+			}
+			if err := i.RateLimit.Validate(); err != nil {
+				return fmt.Errorf("invalid rate limit of integration %q of receiver %q: %w", i.Name, r.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// rateLimiterKey identifies the rate limiter of an integration across configurations, by the receiver and the type and
+// index of the integration. The rate limiter of a receiver, shared by its integrations without their own rate limit,
+// has an empty integration type.
+type rateLimiterKey struct {
+	receiver string
+	typ      string
+	idx      int
+}
+
+// rateLimiters are the rate limiters of a configuration. The next configuration reuses the rate limiters whose rate
+// limit is unchanged, so that their intervals carry on rather than start over at every configuration change.
+type rateLimiters map[rateLimiterKey]*rateLimiter
+
+// get returns the rate limiter of the previous configuration if its rate limit is the same, or a new one otherwise,
+// and adds it to the rate limiters of the next configuration.
+func (previous rateLimiters) get(key rateLimiterKey, cfg RateLimit, next rateLimiters) *rateLimiter {
+	l, ok := previous[key]
+	if !ok || !l.hasConfig(cfg) {
+		l = newRateLimiter(cfg)
+	}
+	next[key] = l
+	return l
+}
+
+// buildRateLimiters returns the rate limiter of every integration of the receiver, in the same order as the integrations.
+// Integrations without a rate limit have a nil rate limiter. Integrations that use the rate limit of the receiver share
+// the same rate limiter. The rate limiters of the previous configuration are reused if their rate limit is unchanged,
+// and all the rate limiters are added to next.
+func buildRateLimiters(receiver *APIReceiver, integrations []*Integration, previous, next rateLimiters) []*rateLimiter {
+	limiters := make([]*rateLimiter, len(integrations))
+	if receiver == nil {
+		return limiters
+	}
+
+	// Integrations are built grouped by type, and their index is their position among the configurations of the same type.
+	type key struct {
+		typ string
+		idx int
+	}
+	configs := make(map[key]*GrafanaIntegrationConfig, len(receiver.Integrations))
+	indexes := make(map[string]int)
+	for _, cfg := range receiver.Integrations {
+		configs[key{typ: cfg.Type, idx: indexes[cfg.Type]}] = cfg
+		indexes[cfg.Type]++
+	}
+
+	var shared *rateLimiter
+	for i, integration := range integrations {
+		if cfg, ok := configs[key{typ: integration.Name(), idx: integration.Index()}]; ok && cfg.RateLimit != nil {
+			limiters[i] = previous.get(rateLimiterKey{receiver: receiver.Name, typ: integration.Name(), idx: integration.Index()}, *cfg.RateLimit, next)
+			continue
+		}
+		if receiver.RateLimit == nil {
+			continue
+		}
+		if shared == nil {
+			shared = previous.get(rateLimiterKey{receiver: receiver.Name}, *receiver.RateLimit, next)
+		}
+		limiters[i] = shared
+	}
+	return limiters
+}
+
+// rateLimiter admits a limited number of alert groups per interval. The interval starts with the first alert group
+// admitted after the previous interval ended. A rate limiter can be shared by the pipelines of several integrations,
+// in which case an alert group admitted through one of them is admitted through all of them.
+type rateLimiter struct {
+	cfg RateLimit
+	now func() time.Time
+
+	mtx         sync.Mutex
+	windowStart time.Time
+	admitted    map[string]struct{}
+}
+
+func newRateLimiter(cfg RateLimit) *rateLimiter {
+	return &rateLimiter{
+		cfg:      withRateLimitDefaults(cfg),
+		now:      time.Now,
+		admitted: make(map[string]struct{}),
+	}
+}
+
+func withRateLimitDefaults(cfg RateLimit) RateLimit {
+	if cfg.SummaryTemplate == "" {
+		cfg.SummaryTemplate = definition.DefaultRateLimitSummaryTemplate
+	}
+	return cfg
+}
+
+// hasConfig returns true if the rate limiter enforces the rate limit.
+func (l *rateLimiter) hasConfig(cfg RateLimit) bool {
+	return l.cfg == withRateLimitDefaults(cfg)
+}
+
+// allow returns true if the alert group can be notified in the current interval, and the end of the current interval.
+func (l *rateLimiter) allow(groupKey string) (bool, time.Time) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	now := l.now()
+	interval := time.Duration(l.cfg.Interval)
+	if now.Sub(l.windowStart) >= interval {
+		l.windowStart = now
+		l.admitted = make(map[string]struct{})
+	}
+	windowEnd := l.windowStart.Add(interval)
+
+	if _, ok := l.admitted[groupKey]; ok {
+		return true, windowEnd
+	}
+	if len(l.admitted) >= l.cfg.Limit {
+		return false, windowEnd
+	}
+	l.admitted[groupKey] = struct{}{}
+	return true, windowEnd
+}
+
+// rateLimitStage notifies an integration only for the alert groups admitted by its rate limiter. The alert groups over
+// the limit are not recorded in the notification log, so they are notified again once the limit allows it, and are
+// folded into a single summary notification sent through the integration at the end of the interval.
+//
+// The summary is not sent when the configuration is replaced or the Alertmanager stops, as the alert groups it would
+// report were not recorded in the notification log and go through the next pipeline and its rate limits. A summary
+// being sent when the Alertmanager stops is canceled, so that stopping does not wait for the integration.
+type rateLimitStage struct {
+	next        notify.Stage
+	limiter     *rateLimiter
+	tmpl        *templates.Template
+	receiver    string
+	integration *nfstatus.Integration
+	lifecycle   stageLifecycle
+	logger      log.Logger
+
+	mtx        sync.Mutex
+	suppressed map[string]SuppressedAlertGroup
+	// scheduled is whether the summary of the current interval is scheduled.
+	scheduled bool
+}
+
+func newRateLimitStage(next notify.Stage, limiter *rateLimiter, tmpl *templates.Template, receiver string, integration *nfstatus.Integration, lifecycle stageLifecycle, logger log.Logger) *rateLimitStage {
+	return &rateLimitStage{
+		next:        next,
+		limiter:     limiter,
+		tmpl:        tmpl,
+		receiver:    receiver,
+		integration: integration,
+		lifecycle:   lifecycle,
+		logger:      logger,
+		suppressed:  make(map[string]SuppressedAlertGroup),
+	}
+}
+
+// Exec implements the Stage interface.
+func (s *rateLimitStage) Exec(ctx context.Context, l log.Logger, alerts ...*types.Alert) (context.Context, []*types.Alert, error) {
+	groupKey, ok := notify.GroupKey(ctx)
+	if !ok {
+		return ctx, nil, fmt.Errorf("group key missing")
+	}
+
+	allowed, windowEnd := s.limiter.allow(groupKey)
+	if allowed {
+		return s.next.Exec(ctx, l, alerts...)
+	}
+
+	groupLabels := templates.KV{}
+	if lset, ok := notify.GroupLabels(ctx); ok {
+		for k, v := range lset {
+			groupLabels[string(k)] = string(v)
+		}
+	}
+	level.Debug(l).Log("msg", "Rate limit reached, suppressing notification", "receiver", s.receiver, "integration", s.integration.String(), "aggrGroup", groupKey)
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.suppressed[groupKey] = SuppressedAlertGroup{
+		GroupKey:    groupKey,
+		GroupLabels: groupLabels,
+		Alerts:      len(alerts),
+	}
+	if !s.scheduled {
+		s.scheduled = true
+		s.lifecycle.after(time.Until(windowEnd), s.onWindowEnd)
+	}
+	// No alerts are passed on, so that the notification log does not record the alert group as notified.
+	return ctx, nil, nil
+}
+
+// onWindowEnd sends the summary at the end of the interval, and drops it when the configuration is replaced or the
+// Alertmanager stops.
+func (s *rateLimitStage) onWindowEnd(e lifecycleEvent) {
+	if e == lifecycleTimer {
+		s.sendSummary()
+		return
+	}
+
+	s.mtx.Lock()
+	dropped := len(s.suppressed)
+	s.suppressed = make(map[string]SuppressedAlertGroup)
+	s.scheduled = false
+	s.mtx.Unlock()
+	level.Debug(s.logger).Log("msg", "Dropping rate limit summary", "receiver", s.receiver, "integration", s.integration.String(), "groups", dropped)
+}
+
+// sendSummary sends the summary notification of the alert groups suppressed during the interval.
+func (s *rateLimitStage) sendSummary() {
+	s.mtx.Lock()
+	suppressed := s.suppressed
+	s.suppressed = make(map[string]SuppressedAlertGroup)
+	s.scheduled = false
+	s.mtx.Unlock()
+
+	if len(suppressed) == 0 {
+		return
+	}
+
+	data := RateLimitSummaryData{
+		Receiver:    s.receiver,
+		Integration: s.integration.String(),
+		Count:       len(suppressed),
+		Groups:      make([]SuppressedAlertGroup, 0, len(suppressed)),
+	}
+	for _, g := range suppressed {
+		data.Groups = append(data.Groups, g)
+	}
+	sort.Slice(data.Groups, func(i, j int) bool {
+		return data.Groups[i].GroupKey < data.Groups[j].GroupKey
+	})
+
+	ctx, cancel := s.lifecycle.context(rateLimitSummaryTimeout)
+	defer cancel()
+
+	ctx, alert := s.summaryNotification(ctx, data)
+	if _, _, err := s.next.Exec(ctx, s.logger, alert); err != nil {
+		level.Error(s.logger).Log("msg", "Failed to send rate limit summary notification", "receiver", s.receiver, "integration", s.integration.String(), "err", err)
+	}
+}
+
+// summaryNotification returns the alert of the summary notification and the context to send it with.
+func (s *rateLimitStage) summaryNotification(ctx context.Context, data RateLimitSummaryData) (context.Context, *types.Alert) {
+	text, err := s.tmpl.ExecuteTextString(s.limiter.cfg.SummaryTemplate, data)
+	if err != nil {
+		level.Warn(s.logger).Log("msg", "Failed to execute rate limit summary template, using the default template", "receiver", s.receiver, "err", err)
+		text = fmt.Sprintf("%d more alert groups suppressed", data.Count)
+	}
+
+	now := time.Now()
+	labels := model.LabelSet{
+		model.AlertNameLabel: rateLimitSummaryAlertName,
+		"receiver":           model.LabelValue(s.receiver),
+	}
+	alert := &types.Alert{
+		Alert: model.Alert{
+			Labels:      labels,
+			Annotations: model.LabelSet{"summary": model.LabelValue(text)},
+			StartsAt:    now,
+			EndsAt:      now.Add(time.Duration(s.limiter.cfg.Interval)),
+		},
+		UpdatedAt: now,
+	}
+
+	ctx = notify.WithGroupKey(ctx, fmt.Sprintf("{}/rate_limit:%s", labels))
+	ctx = notify.WithGroupLabels(ctx, labels)
+	ctx = notify.WithReceiverName(ctx, s.receiver)
+	ctx = notify.WithRepeatInterval(ctx, time.Duration(s.limiter.cfg.Interval))
+	ctx = notify.WithNow(ctx, now)
+	ctx = notify.WithFiringAlerts(ctx, []uint64{uint64(alert.Fingerprint())})
+	ctx = notify.WithResolvedAlerts(ctx, []uint64{})
+	return ctx, alert
+}
@@ -0,0 +1,260 @@
+package notify
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/alertmanager/notify"
+	"github.com/prometheus/alertmanager/types"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/alerting/notify/nfstatus"
+)
+
+type recordingStage struct {
+	mtx    sync.Mutex
+	alerts [][]*types.Alert
+	keys   []string
+}
+
+func (s *recordingStage) Exec(ctx context.Context, _ log.Logger, alerts ...*types.Alert) (context.Context, []*types.Alert, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	key, _ := notify.GroupKey(ctx)
+	s.keys = append(s.keys, key)
+	s.alerts = append(s.alerts, alerts)
+	return ctx, alerts, nil
+}
+
+func (s *recordingStage) calls() ([]string, [][]*types.Alert) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return append([]string{}, s.keys...), append([][]*types.Alert{}, s.alerts...)
+}
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Now()
+	l := newRateLimiter(RateLimit{Limit: 2, Interval: model.Duration(time.Minute)})
+	l.now = func() time.Time { return now }
+
+	allowed, windowEnd := l.allow("a")
+	require.True(t, allowed)
+	require.Equal(t, now.Add(time.Minute), windowEnd)
+
+	allowed, _ = l.allow("b")
+	require.True(t, allowed)
+	// Alert groups already notified in the interval are still allowed.
+	allowed, _ = l.allow("a")
+	require.True(t, allowed)
+	allowed, _ = l.allow("c")
+	require.False(t, allowed)
+
+	now = now.Add(time.Minute)
+	allowed, windowEnd = l.allow("c")
+	require.True(t, allowed)
+	require.Equal(t, now.Add(time.Minute), windowEnd)
+}
+
+func TestRateLimitStage(t *testing.T) {
+	am, _ := setupAMTest(t)
+	tmpl, err := am.buildTemplate(nil)
+	require.NoError(t, err)
+
+	alert := &types.Alert{Alert: model.Alert{
+		Labels:   model.LabelSet{"alertname": "test"},
+		StartsAt: time.Now(),
+		EndsAt:   time.Now().Add(time.Hour),
+	}}
+	newCtx := func(groupKey string) context.Context {
+		ctx := notify.WithGroupKey(context.Background(), groupKey)
+		return notify.WithGroupLabels(ctx, model.LabelSet{"group": model.LabelValue(groupKey)})
+	}
+
+	cases := []struct {
+		name       string
+		template   string
+		expSummary string
+	}{{
+		name:       "default summary template",
+		expSummary: "2 more alert groups suppressed",
+	}, {
+		name:       "custom summary template",
+		template:   `{{ .Count }} groups suppressed for {{ .Receiver }}:{{ range .Groups }} {{ .GroupLabels.group }}{{ end }}`,
+		expSummary: "2 groups suppressed for receiver: b c",
+	}}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			next := &recordingStage{}
+			limiter := newRateLimiter(RateLimit{Limit: 1, Interval: model.Duration(100 * time.Millisecond), SummaryTemplate: c.template})
+			integration := nfstatus.NewIntegration(&fakeNotifier{}, &fakeNotifier{}, "webhook", 0, "receiver")
+			stage := newRateLimitStage(next, limiter, tmpl, "receiver", integration, am.stageLifecycle(), log.NewNopLogger())
+
+			for _, key := range []string{"a", "b", "c", "a"} {
+				_, alerts, err := stage.Exec(newCtx(key), log.NewNopLogger(), alert)
+				require.NoError(t, err)
+				// Suppressed alert groups are not reported as notified, so that the notification log does not record them.
+				if key == "a" {
+					require.Len(t, alerts, 1)
+				} else {
+					require.Empty(t, alerts)
+				}
+			}
+
+			keys, _ := next.calls()
+			require.Equal(t, []string{"a", "a"}, keys)
+
+			require.Eventually(t, func() bool {
+				keys, _ := next.calls()
+				return len(keys) == 3
+			}, 5*time.Second, 10*time.Millisecond)
+
+			_, alerts := next.calls()
+			require.Len(t, alerts[2], 1)
+			summary := alerts[2][0]
+			require.Equal(t, model.LabelValue(rateLimitSummaryAlertName), summary.Labels[model.AlertNameLabel])
+			require.Equal(t, model.LabelValue(c.expSummary), summary.Annotations["summary"])
+		})
+	}
+}
+
+func TestRateLimitStage_Lifecycle(t *testing.T) {
+	am, _ := setupAMTest(t)
+	tmpl, err := am.buildTemplate(nil)
+	require.NoError(t, err)
+
+	alert := &types.Alert{Alert: model.Alert{
+		Labels:   model.LabelSet{"alertname": "test"},
+		StartsAt: time.Now(),
+		EndsAt:   time.Now().Add(time.Hour),
+	}}
+	newSuppressingStage := func(next notify.Stage, lifecycle stageLifecycle) *rateLimitStage {
+		limiter := newRateLimiter(RateLimit{Limit: 1, Interval: model.Duration(time.Hour)})
+		integration := nfstatus.NewIntegration(&fakeNotifier{}, &fakeNotifier{}, "webhook", 0, "receiver")
+		stage := newRateLimitStage(next, limiter, tmpl, "receiver", integration, lifecycle, log.NewNopLogger())
+		for _, key := range []string{"a", "b"} {
+			_, _, err := stage.Exec(notify.WithGroupKey(context.Background(), key), log.NewNopLogger(), alert)
+			require.NoError(t, err)
+		}
+		return stage
+	}
+
+	// The summary is dropped when the configuration is replaced.
+	reloaded := &recordingStage{}
+	stage := newSuppressingStage(reloaded, am.stageLifecycle())
+	require.NoError(t, am.ApplyConfig(&testConfiguration{
+		receivers: []*APIReceiver{{ConfigReceiver: ConfigReceiver{Name: "receiver"}}},
+		route:     &Route{Receiver: "receiver"},
+	}))
+	require.Eventually(t, func() bool {
+		stage.mtx.Lock()
+		defer stage.mtx.Unlock()
+		return !stage.scheduled && len(stage.suppressed) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+	keys, _ := reloaded.calls()
+	require.Equal(t, []string{"a"}, keys)
+
+	// The summary is dropped when the Alertmanager stops too.
+	var wg sync.WaitGroup
+	stopc := make(chan struct{})
+	stopped := &recordingStage{}
+	newSuppressingStage(stopped, stageLifecycle{wg: &wg, reloadc: make(chan struct{}), stopc: stopc})
+	close(stopc)
+	wg.Wait()
+	keys, _ = stopped.calls()
+	require.Equal(t, []string{"a"}, keys)
+}
+
+func TestStageLifecycle_Context(t *testing.T) {
+	stopc := make(chan struct{})
+	l := stageLifecycle{wg: &sync.WaitGroup{}, reloadc: make(chan struct{}), stopc: stopc}
+
+	ctx, cancel := l.context(time.Hour)
+	defer cancel()
+	require.NoError(t, ctx.Err())
+
+	// Notifications sent in the background are canceled when the Alertmanager stops.
+	close(stopc)
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("context not canceled when the Alertmanager stopped")
+	}
+}
+
+func TestBuildRateLimiters(t *testing.T) {
+	receiverLimit := &RateLimit{Limit: 10, Interval: model.Duration(time.Hour)}
+	integrationLimit := &RateLimit{Limit: 1, Interval: model.Duration(time.Minute)}
+	receiver := &APIReceiver{
+		ConfigReceiver: ConfigReceiver{Name: "receiver"},
+		GrafanaIntegrations: GrafanaIntegrations{
+			Integrations: []*GrafanaIntegrationConfig{
+				{Type: "webhook"},
+				{Type: "email"},
+				{Type: "webhook", RateLimit: integrationLimit},
+			},
+			RateLimit: receiverLimit,
+		},
+	}
+	// Integrations are ordered by type, and indexed among the integrations of the same type.
+	integrations := []*Integration{
+		nfstatus.NewIntegration(&fakeNotifier{}, &fakeNotifier{}, "email", 0, "receiver"),
+		nfstatus.NewIntegration(&fakeNotifier{}, &fakeNotifier{}, "webhook", 0, "receiver"),
+		nfstatus.NewIntegration(&fakeNotifier{}, &fakeNotifier{}, "webhook", 1, "receiver"),
+	}
+
+	previous := make(rateLimiters)
+	limiters := buildRateLimiters(receiver, integrations, nil, previous)
+	require.Len(t, limiters, 3)
+	require.Same(t, limiters[0], limiters[1])
+	require.Equal(t, receiverLimit.Limit, limiters[0].cfg.Limit)
+	require.NotSame(t, limiters[0], limiters[2])
+	require.Equal(t, integrationLimit.Limit, limiters[2].cfg.Limit)
+	require.Len(t, previous, 2)
+
+	// The rate limiters whose rate limit is unchanged are reused, so their intervals carry on.
+	receiver.Integrations[2].RateLimit = &RateLimit{Limit: 2, Interval: model.Duration(time.Minute)}
+	next := make(rateLimiters)
+	reused := buildRateLimiters(receiver, integrations, previous, next)
+	require.Same(t, limiters[0], reused[0])
+	require.Same(t, limiters[1], reused[1])
+	require.NotSame(t, limiters[2], reused[2])
+	require.Equal(t, 2, reused[2].cfg.Limit)
+
+	receiver.RateLimit = nil
+	limiters = buildRateLimiters(receiver, integrations, next, make(rateLimiters))
+	require.Nil(t, limiters[0])
+	require.Nil(t, limiters[1])
+	require.Same(t, reused[2], limiters[2])
+}
+
+func TestApplyConfig_RateLimiters(t *testing.T) {
+	am, _ := setupAMTest(t)
+
+	cfg := &testConfiguration{
+		receivers: []*APIReceiver{{
+			ConfigReceiver: ConfigReceiver{Name: "receiver"},
+			GrafanaIntegrations: GrafanaIntegrations{
+				Integrations: []*GrafanaIntegrationConfig{{Type: "webhook"}},
+				RateLimit:    &RateLimit{Limit: 1, Interval: model.Duration(time.Hour)},
+			},
+		}},
+		route: &Route{Receiver: "receiver"},
+	}
+	require.NoError(t, am.ApplyConfig(cfg))
+	key := rateLimiterKey{receiver: "receiver"}
+	limiter := am.rateLimiters[key]
+	require.NotNil(t, limiter)
+
+	// The rate limiter is kept when the configuration is applied again with the same rate limit.
+	require.NoError(t, am.ApplyConfig(cfg))
+	require.Same(t, limiter, am.rateLimiters[key])
+
+	cfg.receivers[0].RateLimit = &RateLimit{Limit: 2, Interval: model.Duration(time.Hour)}
+	require.NoError(t, am.ApplyConfig(cfg))
+	require.NotSame(t, limiter, am.rateLimiters[key])
+}
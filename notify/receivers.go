@@ -73,6 +73,8 @@ type GrafanaIntegrationConfig struct {
 	DisableResolveMessage bool              `json:"disableResolveMessage" yaml:"disableResolveMessage"`
 	Settings              json.RawMessage   `json:"settings" yaml:"settings"`
 	SecureSettings        map[string]string `json:"secureSettings" yaml:"secureSettings"`
+	// RateLimit limits the notifications of the integration. It overrides the rate limit of the receiver.
+	RateLimit *RateLimit `json:"rateLimit,omitempty" yaml:"rateLimit,omitempty"`
 }
 
 type ConfigReceiver = config.Receiver
@@ -84,6 +86,14 @@ type APIReceiver struct {
 
 type GrafanaIntegrations struct {
 	Integrations []*GrafanaIntegrationConfig `yaml:"grafana_managed_receiver_configs,omitempty" json:"grafana_managed_receiver_configs,omitempty"`
+	// FallbackReceiver is the name of the receiver that is notified when all the integrations fail. Some of the time of
+	// the notification is kept for it: half of it, but no more than the fallback timeout of the Alertmanager, so the
+	// integrations retry for a little less time than without a fallback receiver.
+	FallbackReceiver string `yaml:"fallback_receiver,omitempty" json:"fallback_receiver,omitempty"`
+	// RateLimit limits the notifications of the integrations that do not have their own rate limit.
+	RateLimit *RateLimit `yaml:"rate_limit,omitempty" json:"rate_limit,omitempty"`
+	// Digest sends the notifications of the receiver as periodic digests instead of one notification per alert group.
+	Digest *Digest `yaml:"digest,omitempty" json:"digest,omitempty"`
 }
 
 type TestReceiversConfigBodyParams struct {
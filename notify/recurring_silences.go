@@ -0,0 +1,504 @@
+package notify
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/go-openapi/strfmt"
+	"github.com/google/uuid"
+	v2 "github.com/prometheus/alertmanager/api/v2"
+	amv2 "github.com/prometheus/alertmanager/api/v2/models"
+	"github.com/prometheus/alertmanager/pkg/labels"
+	"github.com/prometheus/alertmanager/silence"
+	"github.com/prometheus/alertmanager/silence/silencepb"
+	"github.com/prometheus/alertmanager/timeinterval"
+	"github.com/prometheus/alertmanager/types"
+)
+
+var (
+	ErrRecurringSilenceNotFound = fmt.Errorf("recurring silence not found")
+)
+
+// DefaultRecurringSilencesLookahead is how far ahead recurring silences are materialized by default.
+const DefaultRecurringSilencesLookahead = 24 * time.Hour
+
+// recurringSilenceNamespace is the namespace of the IDs of materialized silences.
+var recurringSilenceNamespace = uuid.MustParse("1f0e4f7e-3c1a-4b8e-9a55-6d2f3c8b7a10")
+
+// RecurringSilence is the definition of a silence that recurs on a schedule. It is materialized into a regular silence
+// for every period in which the time intervals of the schedule are active, ahead of time.
+type RecurringSilence struct {
+	ID       string                      `json:"id"`
+	Matchers amv2.Matchers               `json:"matchers"`
+	Schedule []timeinterval.TimeInterval `json:"schedule"`
+
+	CreatedBy string `json:"createdBy"`
+	Comment   string `json:"comment"`
+
+	// A timestamp indicating when the recurring silence was last updated.
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// ListRecurringSilences returns the recurring silences sorted by ID.
+func (am *GrafanaAlertmanager) ListRecurringSilences() []RecurringSilence {
+	return am.recurringSilences.list()
+}
+
+// GetRecurringSilence returns the recurring silence with the given ID. It returns ErrRecurringSilenceNotFound if the
+// recurring silence is not present.
+func (am *GrafanaAlertmanager) GetRecurringSilence(id string) (RecurringSilence, error) {
+	rs, ok := am.recurringSilences.get(id)
+	if !ok {
+		return RecurringSilence{}, ErrRecurringSilenceNotFound
+	}
+	return rs, nil
+}
+
+// CreateRecurringSilence persists the recurring silence and returns its ID if successful. If the recurring silence has
+// an ID, the existing recurring silence is replaced and its future silences are materialized again.
+func (am *GrafanaAlertmanager) CreateRecurringSilence(rs RecurringSilence) (string, error) {
+	now := time.Now()
+	if err := validateRecurringSilence(rs, now); err != nil {
+		level.Error(am.logger).Log("msg", "invalid recurring silence", "err", err)
+		return "", fmt.Errorf("%s: %w", err.Error(), ErrCreateSilenceBadPayload)
+	}
+	if err := am.validateRecurringSilencePolicy(rs, now); err != nil {
+		level.Error(am.logger).Log("msg", "recurring silence violates the silence policy", "err", err)
+		return "", err
+	}
+
+	if rs.ID == "" {
+		rs.ID = uuid.NewString()
+	} else if _, ok := am.recurringSilences.get(rs.ID); !ok {
+		return "", ErrRecurringSilenceNotFound
+	}
+	rs.UpdatedAt = now
+	am.recurringSilences.set(recurringSilenceEntry{RecurringSilence: rs})
+
+	am.materializeRecurringSilences(now)
+	return rs.ID, nil
+}
+
+// DeleteRecurringSilence deletes the recurring silence with the given ID and expires the silences materialized from it.
+// It returns ErrRecurringSilenceNotFound if the recurring silence is not present.
+func (am *GrafanaAlertmanager) DeleteRecurringSilence(id string) error {
+	now := time.Now()
+	if !am.recurringSilences.delete(id, now) {
+		return ErrRecurringSilenceNotFound
+	}
+
+	am.materializeRecurringSilences(now)
+	return nil
+}
+
+func validateRecurringSilence(rs RecurringSilence, now time.Time) error {
+	if _, err := recurringSilenceMatchers(rs); err != nil {
+		return err
+	}
+	if len(rs.Schedule) == 0 {
+		return errors.New("schedule is required")
+	}
+	if nextTransition(rs.Schedule, now) == nil {
+		if containsTime(rs.Schedule, now) {
+			return errors.New("schedule must not always be active")
+		}
+		return errors.New("schedule is never active")
+	}
+	return nil
+}
+
+// validateRecurringSilencePolicy checks the silence of the next occurrence of the recurring silence against the silence
+// policy, as the policy applies to the silences materialized from it.
+func (am *GrafanaAlertmanager) validateRecurringSilencePolicy(rs RecurringSilence, now time.Time) error {
+	start := now
+	if !containsTime(rs.Schedule, now) {
+		next := nextActivation(rs.Schedule, now)
+		if next == nil {
+			return nil
+		}
+		start = *next
+	}
+	end := nextTransition(rs.Schedule, start)
+	if end == nil {
+		return nil
+	}
+	sil, err := materializedSilence("", rs, occurrence{start: start, end: *end})
+	if err != nil {
+		return fmt.Errorf("%s: %w", err.Error(), ErrCreateSilenceBadPayload)
+	}
+	return am.silencePolicy.validate(sil)
+}
+
+// recurringSilenceMatchers validates the matchers of the recurring silence the same way as the matchers of silences.
+func recurringSilenceMatchers(rs RecurringSilence) (labels.Matchers, error) {
+	if len(rs.Matchers) == 0 {
+		return nil, errors.New("at least one matcher required")
+	}
+	res, err := silenceMatchers(rs.Matchers)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range res {
+		if !m.Matches("") {
+			return res, nil
+		}
+	}
+	return nil, errors.New("at least one matcher must not match the empty string")
+}
+
+// occurrence is a period in which the time intervals of a schedule are active.
+type occurrence struct {
+	start, end time.Time
+}
+
+// occurrences returns the periods in which the time intervals are active that end after now and start before until. The
+// start of a period that is in progress is now.
+func occurrences(intervals []timeinterval.TimeInterval, now, until time.Time) []occurrence {
+	var res []occurrence
+	t := now
+	if containsTime(intervals, t) {
+		end := nextTransition(intervals, t)
+		if end == nil {
+			return nil
+		}
+		res = append(res, occurrence{start: t, end: *end})
+		t = *end
+	}
+	for {
+		start := nextActivation(intervals, t)
+		if start == nil || start.After(until) {
+			return res
+		}
+		end := nextTransition(intervals, *start)
+		if end == nil {
+			return res
+		}
+		res = append(res, occurrence{start: *start, end: *end})
+		t = *end
+	}
+}
+
+// materializedSilenceID returns the ID of the silence materialized from a version of the recurring silence for the
+// occurrence that ends at the given time. The end of an occurrence, unlike its start, does not change while it is in
+// progress. IDs are deterministic so that peers materialize the same silences.
+func materializedSilenceID(rs RecurringSilence, end time.Time) string {
+	name := fmt.Sprintf("%s/%d/%d", rs.ID, rs.UpdatedAt.UnixNano(), end.Unix())
+	return uuid.NewSHA1(recurringSilenceNamespace, []byte(name)).String()
+}
+
+// materializeRecurringSilences creates the silences of the occurrences of every recurring silence that start within the
+// lookahead, and expires the silences of deleted or updated recurring silences. Silences that were expired by users are
+// not created again.
+func (am *GrafanaAlertmanager) materializeRecurringSilences(now time.Time) {
+	am.materializeMtx.Lock()
+	defer am.materializeMtx.Unlock()
+
+	for _, entry := range am.recurringSilences.entries() {
+		desired := make(map[string]occurrence)
+		if !entry.Deleted {
+			for _, o := range occurrences(entry.Schedule, now, now.Add(am.recurringSilencesLookahead)) {
+				desired[materializedSilenceID(entry.RecurringSilence, o.end)] = o
+			}
+		}
+
+		var removed []string
+		for _, id := range entry.Silences {
+			sil, err := am.silences.QueryOne(silence.QIDs(id))
+			if err != nil {
+				// The silence was garbage collected.
+				removed = append(removed, id)
+				continue
+			}
+			if _, ok := desired[id]; ok || types.CalcSilenceState(sil.StartsAt, sil.EndsAt) == types.SilenceStateExpired {
+				continue
+			}
+			if err := am.silences.Expire(id); err != nil {
+				level.Error(am.logger).Log("msg", "failed to expire silence of recurring silence", "recurring_silence", entry.ID, "silence", id, "err", err)
+				continue
+			}
+			am.events.publish(Event{Type: EventSilenceExpired, SilenceID: id})
+		}
+
+		var created []string
+		for id, o := range desired {
+			if _, err := am.silences.QueryOne(silence.QIDs(id)); err == nil {
+				continue
+			}
+			if err := am.createMaterializedSilence(id, entry.RecurringSilence, o); err != nil {
+				level.Error(am.logger).Log("msg", "failed to materialize recurring silence", "recurring_silence", entry.ID, "silence", id, "err", err)
+				continue
+			}
+			created = append(created, id)
+			am.events.publish(Event{Type: EventSilenceCreated, SilenceID: id})
+		}
+
+		am.recurringSilences.updateSilences(entry.ID, removed, created)
+	}
+}
+
+func (am *GrafanaAlertmanager) createMaterializedSilence(id string, rs RecurringSilence, o occurrence) error {
+	sil, err := materializedSilence(id, rs, o)
+	if err != nil {
+		return err
+	}
+	return am.silences.Upsert(sil)
+}
+
+// materializedSilence returns the silence of the occurrence of the recurring silence.
+func materializedSilence(id string, rs RecurringSilence, o occurrence) (*silencepb.Silence, error) {
+	startsAt, endsAt := strfmt.DateTime(o.start), strfmt.DateTime(o.end)
+	return v2.PostableSilenceToProto(&PostableSilence{
+		ID: id,
+		Silence: Silence{
+			Matchers:  rs.Matchers,
+			StartsAt:  &startsAt,
+			EndsAt:    &endsAt,
+			CreatedBy: &rs.CreatedBy,
+			Comment:   &rs.Comment,
+		},
+	})
+}
+
+// recurringSilencesMaintenance periodically materializes the recurring silences, garbage collects deleted ones and, if
+// snapshot is not nil, snapshots them. It runs until the Alertmanager is stopped, and takes a final snapshot on shutdown.
+func (am *GrafanaAlertmanager) recurringSilencesMaintenance(frequency, retention time.Duration, snapshot func(State) (int64, error)) {
+	t := time.NewTicker(frequency)
+	defer t.Stop()
+
+	runMaintenance := func(materialize bool) {
+		start := time.Now()
+		if materialize {
+			am.materializeRecurringSilences(start)
+		}
+		am.recurringSilences.gc(start, retention)
+		if snapshot == nil {
+			return
+		}
+		size, err := snapshot(am.recurringSilences)
+		if err != nil {
+			level.Error(am.logger).Log("msg", "running recurring silences maintenance failed", "err", err)
+			return
+		}
+		level.Debug(am.logger).Log("msg", "recurring silences maintenance done", "duration", time.Since(start), "size", size)
+	}
+
+	am.materializeRecurringSilences(time.Now())
+	for {
+		select {
+		case <-am.stopc:
+			runMaintenance(false)
+			return
+		case <-t.C:
+			runMaintenance(true)
+		}
+	}
+}
+
+// recurringSilenceEntry is the state of a recurring silence.
+type recurringSilenceEntry struct {
+	RecurringSilence
+	// Deleted is true once the recurring silence is deleted. The entry is kept until its silences are garbage collected,
+	// so that peers learn about it and its silences are expired.
+	Deleted bool `json:"deleted,omitempty"`
+	// Silences are the IDs of the silences materialized from every version of the recurring silence, until they are
+	// garbage collected.
+	Silences []string `json:"silences,omitempty"`
+}
+
+// recurringSilences holds the recurring silences. They are replicated to peers, and the most recently updated version
+// of a recurring silence wins. The silences materialized from a recurring silence are merged, as any peer can
+// materialize them.
+type recurringSilences struct {
+	mtx       sync.Mutex
+	st        map[string]recurringSilenceEntry
+	broadcast func([]byte)
+}
+
+func newRecurringSilences() *recurringSilences {
+	return &recurringSilences{
+		st:        make(map[string]recurringSilenceEntry),
+		broadcast: func([]byte) {},
+	}
+}
+
+// loadRecurringSilences decodes a snapshot produced by MarshalBinary. An empty snapshot results in an empty state.
+func loadRecurringSilences(r io.Reader) (*recurringSilences, error) {
+	s := newRecurringSilences()
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return s, nil
+	}
+	if err := s.Merge(b); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// SetBroadcast sets the function used to send updates to peers.
+func (s *recurringSilences) SetBroadcast(f func([]byte)) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.broadcast = f
+}
+
+// MarshalBinary implements the cluster.State interface.
+func (s *recurringSilences) MarshalBinary() ([]byte, error) {
+	return json.Marshal(s.entries())
+}
+
+// Merge implements the cluster.State interface.
+func (s *recurringSilences) Merge(b []byte) error {
+	// Removed Alertmanagers of peers send empty states.
+	if len(b) == 0 {
+		return nil
+	}
+	var entries []recurringSilenceEntry
+	if err := json.Unmarshal(b, &entries); err != nil {
+		return err
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	for _, entry := range entries {
+		existing, ok := s.st[entry.ID]
+		if !ok {
+			s.st[entry.ID] = entry
+			continue
+		}
+		if entry.UpdatedAt.After(existing.UpdatedAt) {
+			existing.RecurringSilence, existing.Deleted = entry.RecurringSilence, entry.Deleted
+		}
+		existing.Silences = mergeSilenceIDs(existing.Silences, entry.Silences)
+		s.st[entry.ID] = existing
+	}
+	return nil
+}
+
+func mergeSilenceIDs(a, b []string) []string {
+	res := append([]string{}, a...)
+	for _, id := range b {
+		if !slices.Contains(a, id) {
+			res = append(res, id)
+		}
+	}
+	return res
+}
+
+// entries returns a copy of every entry, including the deleted ones.
+func (s *recurringSilences) entries() []recurringSilenceEntry {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	res := make([]recurringSilenceEntry, 0, len(s.st))
+	for _, entry := range s.st {
+		entry.Silences = append([]string{}, entry.Silences...)
+		res = append(res, entry)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].ID < res[j].ID })
+	return res
+}
+
+func (s *recurringSilences) list() []RecurringSilence {
+	res := []RecurringSilence{}
+	for _, entry := range s.entries() {
+		if !entry.Deleted {
+			res = append(res, entry.RecurringSilence)
+		}
+	}
+	return res
+}
+
+func (s *recurringSilences) get(id string) (RecurringSilence, bool) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	entry, ok := s.st[id]
+	if !ok || entry.Deleted {
+		return RecurringSilence{}, false
+	}
+	return entry.RecurringSilence, true
+}
+
+// set stores the recurring silence, keeping the silences materialized from its previous versions, and sends it to peers.
+func (s *recurringSilences) set(entry recurringSilenceEntry) {
+	s.mtx.Lock()
+	entry.Silences = mergeSilenceIDs(s.st[entry.ID].Silences, entry.Silences)
+	s.st[entry.ID] = entry
+	broadcast := s.broadcast
+	s.mtx.Unlock()
+
+	b, err := json.Marshal([]recurringSilenceEntry{entry})
+	if err != nil {
+		return
+	}
+	broadcast(b)
+}
+
+// delete marks the recurring silence as deleted and sends it to peers. It returns false if the recurring silence is not
+// present.
+func (s *recurringSilences) delete(id string, now time.Time) bool {
+	s.mtx.Lock()
+	entry, ok := s.st[id]
+	s.mtx.Unlock()
+	if !ok || entry.Deleted {
+		return false
+	}
+	entry.Deleted = true
+	entry.UpdatedAt = now
+	s.set(entry)
+	return true
+}
+
+// updateSilences removes the silences that were garbage collected from the recurring silence and adds the created ones.
+// The entry is sent to peers only if silences were created.
+func (s *recurringSilences) updateSilences(id string, removed, created []string) {
+	s.mtx.Lock()
+	entry, ok := s.st[id]
+	if !ok {
+		s.mtx.Unlock()
+		return
+	}
+	silences := make([]string, 0, len(entry.Silences)+len(created))
+	for _, sid := range entry.Silences {
+		if !slices.Contains(removed, sid) {
+			silences = append(silences, sid)
+		}
+	}
+	entry.Silences = mergeSilenceIDs(silences, created)
+	s.st[id] = entry
+	broadcast := s.broadcast
+	s.mtx.Unlock()
+
+	if len(created) == 0 {
+		return
+	}
+	b, err := json.Marshal([]recurringSilenceEntry{entry})
+	if err != nil {
+		return
+	}
+	broadcast(b)
+}
+
+// gc removes the deleted recurring silences that have no silences left and were deleted longer than the retention ago.
+func (s *recurringSilences) gc(now time.Time, retention time.Duration) int {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	n := 0
+	for id, entry := range s.st {
+		if entry.Deleted && len(entry.Silences) == 0 && now.Sub(entry.UpdatedAt) > retention {
+			delete(s.st, id)
+			n++
+		}
+	}
+	return n
+}
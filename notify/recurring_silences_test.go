@@ -0,0 +1,140 @@
+package notify
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	amv2 "github.com/prometheus/alertmanager/api/v2/models"
+	"github.com/prometheus/alertmanager/timeinterval"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOccurrences(t *testing.T) {
+	// Every day from 02:00 to 04:00 UTC.
+	schedule := []timeinterval.TimeInterval{{
+		Times: []timeinterval.TimeRange{{StartMinute: 2 * 60, EndMinute: 4 * 60}},
+	}}
+	date := func(day, hour int) time.Time { return time.Date(2024, 3, day, hour, 0, 0, 0, time.UTC) }
+
+	require.Equal(t, []occurrence{
+		{start: date(4, 2), end: date(4, 4)},
+		{start: date(5, 2), end: date(5, 4)},
+	}, occurrences(schedule, date(4, 0), date(5, 12)))
+
+	// An occurrence in progress starts now.
+	now := date(4, 3)
+	require.Equal(t, []occurrence{{start: now, end: date(4, 4)}}, occurrences(schedule, now, date(4, 12)))
+}
+
+func TestRecurringSilences(t *testing.T) {
+	am, _ := setupAMTest(t)
+
+	matchers := amv2.Matchers{{Name: ptr("job"), Value: ptr("db-backup"), IsEqual: ptr(true), IsRegex: ptr(false)}}
+	// Every day, in a minute from now, for one minute.
+	start := time.Now().UTC().Add(time.Minute).Truncate(time.Minute)
+	minute := start.Hour()*60 + start.Minute()
+	rs := RecurringSilence{
+		Matchers:  matchers,
+		Schedule:  []timeinterval.TimeInterval{{Times: []timeinterval.TimeRange{{StartMinute: minute, EndMinute: minute + 1}}}},
+		CreatedBy: "user",
+		Comment:   "DB backup",
+	}
+
+	_, err := am.CreateRecurringSilence(RecurringSilence{Matchers: matchers})
+	require.ErrorIs(t, err, ErrCreateSilenceBadPayload)
+	_, err = am.CreateRecurringSilence(RecurringSilence{Matchers: matchers, Schedule: []timeinterval.TimeInterval{{}}})
+	require.ErrorContains(t, err, "schedule must not always be active")
+	_, err = am.CreateRecurringSilence(RecurringSilence{ID: "unknown", Matchers: matchers, Schedule: rs.Schedule})
+	require.ErrorIs(t, err, ErrRecurringSilenceNotFound)
+
+	id, err := am.CreateRecurringSilence(rs)
+	require.NoError(t, err)
+	stored, err := am.GetRecurringSilence(id)
+	require.NoError(t, err)
+	require.Equal(t, "DB backup", stored.Comment)
+	require.Len(t, am.ListRecurringSilences(), 1)
+
+	// The next occurrence is materialized.
+	sils, err := am.ListSilences(nil)
+	require.NoError(t, err)
+	require.Len(t, sils, 1)
+	require.Equal(t, amv2.SilenceStatusStatePending, *sils[0].Status.State)
+	require.Equal(t, start, time.Time(*sils[0].StartsAt).UTC())
+	require.Equal(t, start.Add(time.Minute), time.Time(*sils[0].EndsAt).UTC())
+	require.Equal(t, "DB backup", *sils[0].Comment)
+
+	// Materializing again does not create the silence again.
+	am.materializeRecurringSilences(time.Now())
+	sils, err = am.ListSilences(nil)
+	require.NoError(t, err)
+	require.Len(t, sils, 1)
+
+	// Updating the recurring silence replaces its silences.
+	stored.Comment = "Weekly DB backup"
+	_, err = am.CreateRecurringSilence(stored)
+	require.NoError(t, err)
+	sils, err = am.ListSilences(nil)
+	require.NoError(t, err)
+	require.Len(t, sils, 2)
+	for _, s := range sils {
+		if *s.Comment == "Weekly DB backup" {
+			require.Equal(t, amv2.SilenceStatusStatePending, *s.Status.State)
+		} else {
+			require.Equal(t, amv2.SilenceStatusStateExpired, *s.Status.State)
+		}
+	}
+
+	// Deleting the recurring silence expires its silences.
+	require.NoError(t, am.DeleteRecurringSilence(id))
+	require.ErrorIs(t, am.DeleteRecurringSilence(id), ErrRecurringSilenceNotFound)
+	_, err = am.GetRecurringSilence(id)
+	require.ErrorIs(t, err, ErrRecurringSilenceNotFound)
+	require.Empty(t, am.ListRecurringSilences())
+	sils, err = am.ListSilences(nil)
+	require.NoError(t, err)
+	for _, s := range sils {
+		require.Equal(t, amv2.SilenceStatusStateExpired, *s.Status.State)
+	}
+}
+
+func TestRecurringSilences_MergeAndSnapshot(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	schedule := []timeinterval.TimeInterval{{Times: []timeinterval.TimeRange{{StartMinute: 60, EndMinute: 120}}}}
+
+	a := newRecurringSilences()
+	a.set(recurringSilenceEntry{RecurringSilence: RecurringSilence{ID: "1", Comment: "old", Schedule: schedule, UpdatedAt: now}, Silences: []string{"a"}})
+
+	b := newRecurringSilences()
+	var broadcast []byte
+	b.SetBroadcast(func(msg []byte) { broadcast = msg })
+	b.set(recurringSilenceEntry{RecurringSilence: RecurringSilence{ID: "1", Comment: "new", Schedule: schedule, UpdatedAt: now.Add(time.Minute)}, Silences: []string{"b"}})
+
+	// The most recent version wins, and the silences of every version are kept.
+	state, err := a.MarshalBinary()
+	require.NoError(t, err)
+	require.NoError(t, b.Merge(state))
+	rs, ok := b.get("1")
+	require.True(t, ok)
+	require.Equal(t, "new", rs.Comment)
+	require.Equal(t, []string{"b", "a"}, b.entries()[0].Silences)
+
+	require.NoError(t, a.Merge(broadcast))
+	rs, _ = a.get("1")
+	require.Equal(t, "new", rs.Comment)
+	require.Equal(t, schedule, rs.Schedule)
+
+	// Snapshots are restored.
+	state, err = b.MarshalBinary()
+	require.NoError(t, err)
+	restored, err := loadRecurringSilences(bytes.NewReader(state))
+	require.NoError(t, err)
+	require.Equal(t, b.entries(), restored.entries())
+
+	// Deleted recurring silences are garbage collected once their silences are.
+	require.True(t, restored.delete("1", now))
+	require.Zero(t, restored.gc(now.Add(time.Hour), time.Minute))
+	restored.updateSilences("1", []string{"a", "b"}, nil)
+	require.Equal(t, 1, restored.gc(now.Add(time.Hour), time.Minute))
+	require.Empty(t, restored.entries())
+}
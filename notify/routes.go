@@ -0,0 +1,340 @@
+package notify
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/go-kit/log/level"
+	v2 "github.com/prometheus/alertmanager/api/v2"
+	"github.com/prometheus/alertmanager/dispatch"
+	"github.com/prometheus/alertmanager/inhibit"
+	"github.com/prometheus/alertmanager/silence"
+	"github.com/prometheus/alertmanager/timeinterval"
+	"github.com/prometheus/alertmanager/types"
+	"github.com/prometheus/common/model"
+
+	"github.com/grafana/alerting/definition"
+)
+
+// timeIntervalTransitionHorizon is how far ahead the next transition of a time interval is searched for.
+const timeIntervalTransitionHorizon = 5 * 366 * 24 * time.Hour
+
+var (
+	ErrTestRoutesUnavailable = fmt.Errorf("unable to test routes as alertmanager is not initialised yet")
+	ErrTestRoutesInternal    = fmt.Errorf("unable to test routes due to an internal error")
+)
+
+// RouteMatch is a route of the routing tree that an alert with a given label set would be routed to.
+type RouteMatch struct {
+	// ID uniquely identifies the route by its position in the routing tree.
+	ID string `json:"id"`
+	// Path contains the IDs of the routes from the root of the routing tree to the matched route, inclusive.
+	Path     []string `json:"path"`
+	Receiver string   `json:"receiver"`
+	Continue bool     `json:"continue"`
+
+	// GroupBy, GroupWait, GroupInterval and RepeatInterval are the effective values, including those inherited from parent routes.
+	GroupBy        []string       `json:"groupBy"`
+	GroupByAll     bool           `json:"groupByAll"`
+	GroupWait      model.Duration `json:"groupWait"`
+	GroupInterval  model.Duration `json:"groupInterval"`
+	RepeatInterval model.Duration `json:"repeatInterval"`
+
+	MuteTimeIntervals   []TimeIntervalMatch `json:"muteTimeIntervals"`
+	ActiveTimeIntervals []TimeIntervalMatch `json:"activeTimeIntervals"`
+	// Muted is whether notifications for this route would be muted by its time intervals at the time of the test.
+	Muted bool `json:"muted"`
+
+	// Integrations are the integrations of the receiver. They are only known when testing the routes of a running Alertmanager.
+	Integrations []RouteMatchIntegration `json:"integrations,omitempty"`
+}
+
+// TimeIntervalMatch is a time interval referenced by a route and whether it contains the time of the test.
+type TimeIntervalMatch struct {
+	Name   string `json:"name"`
+	Active bool   `json:"active"`
+	// NextTransition is the next time at which the time interval becomes active or inactive. It is nil if that does not
+	// happen within timeIntervalTransitionHorizon.
+	NextTransition *time.Time `json:"nextTransition,omitempty"`
+}
+
+type RouteMatchIntegration struct {
+	Name  string `json:"name"`
+	Index int    `json:"index"`
+	UID   string `json:"uid,omitempty"`
+}
+
+// InhibitionMatch is an inhibition rule that inhibits a label set, and the alerts that inhibit it.
+type InhibitionMatch struct {
+	Rule         InhibitRule       `json:"rule"`
+	SourceAlerts []InhibitingAlert `json:"sourceAlerts"`
+}
+
+type InhibitingAlert struct {
+	Fingerprint string         `json:"fingerprint"`
+	Labels      model.LabelSet `json:"labels"`
+}
+
+// RouteTestResult is the outcome of routing a label set through the routing tree of a running Alertmanager.
+type RouteTestResult struct {
+	Labels model.LabelSet `json:"labels"`
+	Routes []RouteMatch   `json:"routes"`
+	// Silences are the active silences that would silence the alert.
+	Silences GettableSilences `json:"silences"`
+	// Inhibitions are the inhibition rules that would inhibit the alert given the alerts that are currently firing.
+	Inhibitions []InhibitionMatch `json:"inhibitions"`
+}
+
+// TestRoutes returns the routes that an alert with the given labels would be routed to, in the order in which they would be matched,
+// together with the silences and inhibitions that would currently suppress it.
+func (am *GrafanaAlertmanager) TestRoutes(lset model.LabelSet) (*RouteTestResult, error) {
+	am.reloadConfigMtx.RLock()
+	defer am.reloadConfigMtx.RUnlock()
+
+	if !am.ready() {
+		return nil, ErrTestRoutesUnavailable
+	}
+
+	now := time.Now()
+	routes, err := matchRoutes(am.route, am.timeIntervals, lset, now)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", err.Error(), ErrTestRoutesInternal)
+	}
+
+	receivers := make(map[string][]RouteMatchIntegration, len(am.receivers))
+	for _, r := range am.receivers {
+		integrations := make([]RouteMatchIntegration, 0, len(r.Integrations()))
+		for _, i := range r.Integrations() {
+			integrations = append(integrations, RouteMatchIntegration{Name: i.Name(), Index: i.Index(), UID: i.UID()})
+		}
+		receivers[r.Name()] = integrations
+	}
+	for i := range routes {
+		routes[i].Integrations = receivers[routes[i].Receiver]
+	}
+
+	silences, err := am.matchingSilences(lset)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RouteTestResult{
+		Labels:      lset,
+		Routes:      routes,
+		Silences:    silences,
+		Inhibitions: am.matchingInhibitions(lset),
+	}, nil
+}
+
+// MatchRoutes returns the routes of the routing tree that an alert with the given labels would be routed to, in the order in which
+// they would be matched. The time intervals referenced by the routes are evaluated at the given time. The route is validated first.
+func MatchRoutes(route *definition.Route, timeIntervals []TimeInterval, lset model.LabelSet, now time.Time) ([]RouteMatch, error) {
+	if err := route.Validate(); err != nil {
+		return nil, err
+	}
+
+	intervals := make(map[string][]timeinterval.TimeInterval, len(timeIntervals))
+	for _, ti := range timeIntervals {
+		intervals[ti.Name] = ti.TimeIntervals
+	}
+
+	return matchRoutes(dispatch.NewRoute(route.AsAMRoute(), nil), intervals, lset, now)
+}
+
+func matchRoutes(root *dispatch.Route, timeIntervals map[string][]timeinterval.TimeInterval, lset model.LabelSet, now time.Time) ([]RouteMatch, error) {
+	parents := map[*dispatch.Route]*dispatch.Route{}
+	root.Walk(func(r *dispatch.Route) {
+		for _, child := range r.Routes {
+			parents[child] = r
+		}
+	})
+
+	matches := root.Match(lset)
+	res := make([]RouteMatch, 0, len(matches))
+	for _, r := range matches {
+		var path []string
+		for p := r; p != nil; p = parents[p] {
+			path = append([]string{p.ID()}, path...)
+		}
+
+		// Routes grouping by all labels inherit the group_by of their parent, but it is not used.
+		groupBy := make([]string, 0, len(r.RouteOpts.GroupBy))
+		if !r.RouteOpts.GroupByAll {
+			for ln := range r.RouteOpts.GroupBy {
+				groupBy = append(groupBy, string(ln))
+			}
+			sort.Strings(groupBy)
+		}
+
+		m := RouteMatch{
+			ID:             r.ID(),
+			Path:           path,
+			Receiver:       r.RouteOpts.Receiver,
+			Continue:       r.Continue,
+			GroupBy:        groupBy,
+			GroupByAll:     r.RouteOpts.GroupByAll,
+			GroupWait:      model.Duration(r.RouteOpts.GroupWait),
+			GroupInterval:  model.Duration(r.RouteOpts.GroupInterval),
+			RepeatInterval: model.Duration(r.RouteOpts.RepeatInterval),
+		}
+
+		var err error
+		if m.MuteTimeIntervals, err = matchTimeIntervals(r.RouteOpts.MuteTimeIntervals, timeIntervals, now); err != nil {
+			return nil, err
+		}
+		if m.ActiveTimeIntervals, err = matchTimeIntervals(r.RouteOpts.ActiveTimeIntervals, timeIntervals, now); err != nil {
+			return nil, err
+		}
+
+		// As in the TimeMuteStage, a route is muted during any of its mute time intervals and outside all of its active time intervals.
+		for _, ti := range m.MuteTimeIntervals {
+			m.Muted = m.Muted || ti.Active
+		}
+		if len(m.ActiveTimeIntervals) > 0 {
+			active := false
+			for _, ti := range m.ActiveTimeIntervals {
+				active = active || ti.Active
+			}
+			m.Muted = m.Muted || !active
+		}
+
+		res = append(res, m)
+	}
+
+	return res, nil
+}
+
+func matchTimeIntervals(names []string, timeIntervals map[string][]timeinterval.TimeInterval, now time.Time) ([]TimeIntervalMatch, error) {
+	res := make([]TimeIntervalMatch, 0, len(names))
+	for _, name := range names {
+		intervals, ok := timeIntervals[name]
+		if !ok {
+			return nil, fmt.Errorf("time interval %s doesn't exist in config", name)
+		}
+		res = append(res, TimeIntervalMatch{
+			Name:           name,
+			Active:         containsTime(intervals, now),
+			NextTransition: nextTransition(intervals, now),
+		})
+	}
+	return res, nil
+}
+
+// containsTime returns true if any of the time intervals contains the time, as in the Intervener.
+func containsTime(intervals []timeinterval.TimeInterval, t time.Time) bool {
+	for _, ti := range intervals {
+		if ti.ContainsTime(t.UTC()) {
+			return true
+		}
+	}
+	return false
+}
+
+// nextTransition returns the first time after now at which containsTime changes, or nil if it does not change within
+// timeIntervalTransitionHorizon. Time intervals have a resolution of one minute and can only start or end at midnight or
+// at the start or end of one of their time ranges, in their own location, so only those times are checked.
+func nextTransition(intervals []timeinterval.TimeInterval, now time.Time) *time.Time {
+	active := containsTime(intervals, now)
+
+	var next *time.Time
+	for _, ti := range intervals {
+		loc := time.UTC
+		if ti.Location != nil {
+			loc = ti.Location.Location
+		}
+		minutes := []int{0}
+		for _, r := range ti.Times {
+			minutes = append(minutes, r.StartMinute, r.EndMinute)
+		}
+		sort.Ints(minutes)
+
+		local := now.In(loc)
+	Days:
+		for d := 0; d <= int(timeIntervalTransitionHorizon/(24*time.Hour)); d++ {
+			for _, m := range minutes {
+				// time.Date normalizes the minutes, which also accounts for days that are not 24 hours long.
+				c := time.Date(local.Year(), local.Month(), local.Day()+d, 0, m, 0, 0, loc)
+				if next != nil && !c.Before(*next) {
+					break Days
+				}
+				if !c.After(now) {
+					continue
+				}
+				if containsTime(intervals, c) != active {
+					next = &c
+					break Days
+				}
+			}
+		}
+	}
+
+	return next
+}
+
+// matchingSilences returns the active silences that match the label set.
+func (am *GrafanaAlertmanager) matchingSilences(lset model.LabelSet) (GettableSilences, error) {
+	psils, _, err := am.silences.Query(silence.QState(types.SilenceStateActive), silence.QMatches(lset))
+	if err != nil {
+		level.Error(am.logger).Log("msg", ErrGetSilencesInternal.Error(), "err", err)
+		return nil, fmt.Errorf("%s: %w", ErrGetSilencesInternal.Error(), err)
+	}
+
+	sils := GettableSilences{}
+	for _, ps := range psils {
+		s, err := v2.GettableSilenceFromProto(ps)
+		if err != nil {
+			level.Error(am.logger).Log("msg", "unmarshaling from protobuf failed", "err", err)
+			return nil, fmt.Errorf("%s: failed to convert internal silence to API silence: %w", ErrGetSilencesInternal.Error(), err)
+		}
+		sils = append(sils, &s)
+	}
+	v2.SortSilences(sils)
+
+	return sils, nil
+}
+
+// matchingInhibitions returns the inhibition rules that inhibit the label set given the alerts that are currently firing.
+// Unlike the inhibitor, it does not update the marker. It must be called with the configuration lock held.
+func (am *GrafanaAlertmanager) matchingInhibitions(lset model.LabelSet) []InhibitionMatch {
+	var alerts []*types.Alert
+	it := am.alerts.GetPending()
+	for a := range it.Next() {
+		if !a.Resolved() {
+			alerts = append(alerts, a)
+		}
+	}
+	it.Close()
+
+	res := []InhibitionMatch{}
+	for _, cr := range am.inhibitRules {
+		r := inhibit.NewInhibitRule(cr)
+		if !r.TargetMatchers.Matches(lset) {
+			continue
+		}
+		// As in the inhibitor, an alert matching both sides of a rule cannot be inhibited by alerts that also match both sides.
+		twoSided := r.SourceMatchers.Matches(lset)
+
+		var sources []InhibitingAlert
+	Outer:
+		for _, a := range alerts {
+			if !r.SourceMatchers.Matches(a.Labels) {
+				continue
+			}
+			for ln := range r.Equal {
+				if a.Labels[ln] != lset[ln] {
+					continue Outer
+				}
+			}
+			if twoSided && r.TargetMatchers.Matches(a.Labels) {
+				continue
+			}
+			sources = append(sources, InhibitingAlert{Fingerprint: a.Fingerprint().String(), Labels: a.Labels})
+		}
+		if len(sources) > 0 {
+			res = append(res, InhibitionMatch{Rule: cr, SourceAlerts: sources})
+		}
+	}
+
+	return res
+}
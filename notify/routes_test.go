@@ -0,0 +1,236 @@
+package notify
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-openapi/strfmt"
+	amv2 "github.com/prometheus/alertmanager/api/v2/models"
+	"github.com/prometheus/alertmanager/config"
+	"github.com/prometheus/alertmanager/pkg/labels"
+	"github.com/prometheus/alertmanager/timeinterval"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/alerting/definition"
+)
+
+func TestMatchRoutes(t *testing.T) {
+	matchers := func(pairs ...string) config.Matchers {
+		var res config.Matchers
+		for i := 0; i < len(pairs); i += 2 {
+			m, err := labels.NewMatcher(labels.MatchEqual, pairs[i], pairs[i+1])
+			require.NoError(t, err)
+			res = append(res, m)
+		}
+		return res
+	}
+
+	route := &definition.Route{
+		Receiver:   "default",
+		GroupByStr: []string{"alertname"},
+		GroupWait:  ptr(model.Duration(10 * time.Second)),
+		Routes: []*definition.Route{{
+			Receiver:          "team-a",
+			Matchers:          matchers("team", "a"),
+			Continue:          true,
+			MuteTimeIntervals: []string{"never"},
+		}, {
+			Receiver:            "pager",
+			ObjectMatchers:      definition.ObjectMatchers(matchers("team", "a", "severity", "critical")),
+			GroupByStr:          []string{"..."},
+			RepeatInterval:      ptr(model.Duration(time.Minute)),
+			ActiveTimeIntervals: []string{"never"},
+		}, {
+			Receiver:          "team-b",
+			Matchers:          matchers("team", "b"),
+			MuteTimeIntervals: []string{"always", "never"},
+		}},
+	}
+	timeIntervals := []TimeInterval{
+		{Name: "always", TimeIntervals: []timeinterval.TimeInterval{{}}},
+		{Name: "never", TimeIntervals: []timeinterval.TimeInterval{{Years: []timeinterval.YearRange{{InclusiveRange: timeinterval.InclusiveRange{Begin: 1990, End: 1990}}}}}},
+	}
+
+	t.Run("continue", func(t *testing.T) {
+		routes, err := MatchRoutes(route, timeIntervals, model.LabelSet{"alertname": "test", "team": "a", "severity": "critical"}, time.Now())
+		require.NoError(t, err)
+		require.Equal(t, []RouteMatch{{
+			ID:                  `{}/{team="a"}/0`,
+			Path:                []string{"{}", `{}/{team="a"}/0`},
+			Receiver:            "team-a",
+			Continue:            true,
+			GroupBy:             []string{"alertname"},
+			GroupWait:           model.Duration(10 * time.Second),
+			GroupInterval:       model.Duration(5 * time.Minute),
+			RepeatInterval:      model.Duration(4 * time.Hour),
+			MuteTimeIntervals:   []TimeIntervalMatch{{Name: "never", Active: false}},
+			ActiveTimeIntervals: []TimeIntervalMatch{},
+		}, {
+			ID:                  `{}/{severity="critical",team="a"}/1`,
+			Path:                []string{"{}", `{}/{severity="critical",team="a"}/1`},
+			Receiver:            "pager",
+			GroupBy:             []string{},
+			GroupByAll:          true,
+			GroupWait:           model.Duration(10 * time.Second),
+			GroupInterval:       model.Duration(5 * time.Minute),
+			RepeatInterval:      model.Duration(time.Minute),
+			MuteTimeIntervals:   []TimeIntervalMatch{},
+			ActiveTimeIntervals: []TimeIntervalMatch{{Name: "never", Active: false}},
+			Muted:               true,
+		}}, routes)
+	})
+
+	t.Run("muted route", func(t *testing.T) {
+		routes, err := MatchRoutes(route, timeIntervals, model.LabelSet{"alertname": "test", "team": "b"}, time.Now())
+		require.NoError(t, err)
+		require.Len(t, routes, 1)
+		require.Equal(t, "team-b", routes[0].Receiver)
+		require.Equal(t, []TimeIntervalMatch{{Name: "always", Active: true}, {Name: "never", Active: false}}, routes[0].MuteTimeIntervals)
+		require.True(t, routes[0].Muted)
+	})
+
+	t.Run("default route", func(t *testing.T) {
+		routes, err := MatchRoutes(route, timeIntervals, model.LabelSet{"alertname": "test"}, time.Now())
+		require.NoError(t, err)
+		require.Len(t, routes, 1)
+		require.Equal(t, "default", routes[0].Receiver)
+		require.Equal(t, []string{"{}"}, routes[0].Path)
+		require.False(t, routes[0].Muted)
+	})
+
+	t.Run("undefined time interval", func(t *testing.T) {
+		_, err := MatchRoutes(route, nil, model.LabelSet{"team": "b"}, time.Now())
+		require.ErrorContains(t, err, "time interval always doesn't exist in config")
+	})
+}
+
+func TestGrafanaAlertmanager_TestRoutes(t *testing.T) {
+	am, _ := setupAMTest(t)
+
+	_, err := am.TestRoutes(model.LabelSet{"alertname": "test"})
+	require.ErrorIs(t, err, ErrTestRoutesUnavailable)
+
+	matchers := func(name, value string) config.Matchers {
+		m, err := labels.NewMatcher(labels.MatchEqual, name, value)
+		require.NoError(t, err)
+		return config.Matchers{m}
+	}
+	cfg := &testConfiguration{
+		receivers: []*APIReceiver{{
+			ConfigReceiver: ConfigReceiver{Name: "default"},
+			GrafanaIntegrations: GrafanaIntegrations{Integrations: []*GrafanaIntegrationConfig{
+				{UID: "email-uid", Type: "email"},
+				{UID: "slack-uid", Type: "slack"},
+			}},
+		}},
+		route: &Route{Receiver: "default"},
+		inhibitRules: []InhibitRule{{
+			SourceMatchers: matchers("severity", "critical"),
+			TargetMatchers: matchers("severity", "warning"),
+			Equal:          model.LabelNames{"cluster"},
+		}},
+	}
+	require.NoError(t, am.ApplyConfig(cfg))
+
+	now := time.Now()
+	require.NoError(t, am.PutAlerts(amv2.PostableAlerts{{
+		Alert:    amv2.Alert{Labels: amv2.LabelSet{"alertname": "source", "severity": "critical", "cluster": "a"}},
+		StartsAt: strfmt.DateTime(now),
+		EndsAt:   strfmt.DateTime(now.Add(time.Hour)),
+	}, {
+		Alert:    amv2.Alert{Labels: amv2.LabelSet{"alertname": "other", "severity": "critical", "cluster": "b"}},
+		StartsAt: strfmt.DateTime(now),
+		EndsAt:   strfmt.DateTime(now.Add(time.Hour)),
+	}}))
+
+	silenceID, err := am.CreateSilence(&PostableSilence{Silence: amv2.Silence{
+		Comment:   ptr("comment"),
+		CreatedBy: ptr("test"),
+		StartsAt:  ptr(strfmt.DateTime(now)),
+		EndsAt:    ptr(strfmt.DateTime(now.Add(time.Hour))),
+		Matchers:  amv2.Matchers{{Name: ptr("alertname"), Value: ptr("test"), IsEqual: ptr(true), IsRegex: ptr(false)}},
+	}})
+	require.NoError(t, err)
+
+	res, err := am.TestRoutes(model.LabelSet{"alertname": "test", "severity": "warning", "cluster": "a"})
+	require.NoError(t, err)
+
+	require.Len(t, res.Routes, 1)
+	require.Equal(t, "default", res.Routes[0].Receiver)
+	require.Equal(t, []RouteMatchIntegration{
+		{Name: "email", Index: 0, UID: "email-uid"},
+		{Name: "slack", Index: 1, UID: "slack-uid"},
+	}, res.Routes[0].Integrations)
+
+	require.Len(t, res.Silences, 1)
+	require.Equal(t, silenceID, *res.Silences[0].ID)
+
+	require.Len(t, res.Inhibitions, 1)
+	require.Equal(t, cfg.inhibitRules[0], res.Inhibitions[0].Rule)
+	require.Len(t, res.Inhibitions[0].SourceAlerts, 1)
+	require.Equal(t, model.LabelValue("source"), res.Inhibitions[0].SourceAlerts[0].Labels["alertname"])
+
+	// Neither silenced nor inhibited.
+	res, err = am.TestRoutes(model.LabelSet{"alertname": "other", "severity": "warning", "cluster": "c"})
+	require.NoError(t, err)
+	require.Empty(t, res.Silences)
+	require.Empty(t, res.Inhibitions)
+}
+
+func TestNextTransition(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	businessHours := []timeinterval.TimeInterval{{
+		Times:    []timeinterval.TimeRange{{StartMinute: 9 * 60, EndMinute: 17 * 60}},
+		Weekdays: []timeinterval.WeekdayRange{{InclusiveRange: timeinterval.InclusiveRange{Begin: 1, End: 5}}},
+	}}
+	inBerlin := []timeinterval.TimeInterval{{
+		Times:    []timeinterval.TimeRange{{StartMinute: 9 * 60, EndMinute: 17 * 60}},
+		Location: &timeinterval.Location{Location: berlin},
+	}}
+
+	cases := []struct {
+		name      string
+		intervals []timeinterval.TimeInterval
+		now       time.Time
+		exp       *time.Time
+	}{{
+		name:      "inactive, becomes active later in the day",
+		intervals: businessHours,
+		now:       time.Date(2024, 1, 8, 7, 30, 0, 0, time.UTC), // Monday
+		exp:       ptr(time.Date(2024, 1, 8, 9, 0, 0, 0, time.UTC)),
+	}, {
+		name:      "active, becomes inactive at the end of the range",
+		intervals: businessHours,
+		now:       time.Date(2024, 1, 8, 12, 0, 0, 0, time.UTC),
+		exp:       ptr(time.Date(2024, 1, 8, 17, 0, 0, 0, time.UTC)),
+	}, {
+		name:      "inactive over the weekend",
+		intervals: businessHours,
+		now:       time.Date(2024, 1, 6, 12, 0, 0, 0, time.UTC), // Saturday
+		exp:       ptr(time.Date(2024, 1, 8, 9, 0, 0, 0, time.UTC)),
+	}, {
+		name:      "location",
+		intervals: inBerlin,
+		now:       time.Date(2024, 1, 8, 7, 30, 0, 0, time.UTC),
+		exp:       ptr(time.Date(2024, 1, 8, 9, 0, 0, 0, berlin)),
+	}, {
+		name:      "always active",
+		intervals: []timeinterval.TimeInterval{{}},
+		now:       time.Date(2024, 1, 8, 7, 30, 0, 0, time.UTC),
+	}}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			next := nextTransition(c.intervals, c.now)
+			if c.exp == nil {
+				require.Nil(t, next)
+				return
+			}
+			require.NotNil(t, next)
+			require.True(t, c.exp.Equal(*next), "expected %s, got %s", c.exp, next)
+		})
+	}
+}
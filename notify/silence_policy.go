@@ -0,0 +1,148 @@
+package notify
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/prometheus/alertmanager/pkg/labels"
+	"github.com/prometheus/alertmanager/silence/silencepb"
+)
+
+// SilencePolicy restricts the silences of a tenant. It is enforced when silences and recurring silences are created or
+// updated. The zero value allows every silence.
+type SilencePolicy struct {
+	// MaxDuration is the maximum duration of silences. Zero means no limit.
+	MaxDuration time.Duration
+	// RequireComment requires silences to have a non-empty comment.
+	RequireComment bool
+	// CommentPattern, if not nil, must match the comment of silences, for example to require a ticket ID. It can match
+	// any part of the comment.
+	CommentPattern *regexp.Regexp
+	// ForbiddenMatchers are matchers that silences must not consist of only, such as alertname=~".*".
+	ForbiddenMatchers []*labels.Matcher
+	// RequiredLabels are the label names that every silence must have a matcher for.
+	RequiredLabels []string
+}
+
+// SilenceDurationError is returned when a silence is longer than the maximum duration of the silence policy.
+type SilenceDurationError struct {
+	Duration    time.Duration
+	MaxDuration time.Duration
+}
+
+func (e SilenceDurationError) Error() string {
+	return fmt.Sprintf("silence duration %s exceeds the maximum duration %s", e.Duration, e.MaxDuration)
+}
+
+func (e SilenceDurationError) Unwrap() error {
+	return ErrCreateSilenceBadPayload
+}
+
+// SilenceCommentError is returned when the comment of a silence is empty or does not match the comment pattern of the
+// silence policy.
+type SilenceCommentError struct {
+	// Pattern is the comment pattern of the silence policy, or empty if the comment is only required.
+	Pattern string
+}
+
+func (e SilenceCommentError) Error() string {
+	if e.Pattern == "" {
+		return "silence comment is required"
+	}
+	return fmt.Sprintf("silence comment must match %q", e.Pattern)
+}
+
+func (e SilenceCommentError) Unwrap() error {
+	return ErrCreateSilenceBadPayload
+}
+
+// SilenceForbiddenMatchersError is returned when a silence consists only of forbidden matchers.
+type SilenceForbiddenMatchersError struct {
+	Matchers []string
+}
+
+func (e SilenceForbiddenMatchersError) Error() string {
+	return fmt.Sprintf("silence must not consist only of the forbidden matchers %s", strings.Join(e.Matchers, ", "))
+}
+
+func (e SilenceForbiddenMatchersError) Unwrap() error {
+	return ErrCreateSilenceBadPayload
+}
+
+// SilenceRequiredLabelsError is returned when a silence has no matcher for some of the required labels of the silence
+// policy.
+type SilenceRequiredLabelsError struct {
+	Missing []string
+}
+
+func (e SilenceRequiredLabelsError) Error() string {
+	return fmt.Sprintf("silence must have matchers for the labels %s", strings.Join(e.Missing, ", "))
+}
+
+func (e SilenceRequiredLabelsError) Unwrap() error {
+	return ErrCreateSilenceBadPayload
+}
+
+// validate returns the first violation of the policy by the silence.
+func (p SilencePolicy) validate(sil *silencepb.Silence) error {
+	if d := sil.EndsAt.Sub(sil.StartsAt); p.MaxDuration > 0 && d > p.MaxDuration {
+		return SilenceDurationError{Duration: d, MaxDuration: p.MaxDuration}
+	}
+
+	if p.RequireComment && strings.TrimSpace(sil.Comment) == "" {
+		return SilenceCommentError{}
+	}
+	if p.CommentPattern != nil && !p.CommentPattern.MatchString(sil.Comment) {
+		return SilenceCommentError{Pattern: p.CommentPattern.String()}
+	}
+
+	if len(p.ForbiddenMatchers) > 0 && len(sil.Matchers) > 0 {
+		forbidden := make([]string, 0, len(sil.Matchers))
+		for _, m := range sil.Matchers {
+			s, ok := p.forbidden(m)
+			if !ok {
+				break
+			}
+			forbidden = append(forbidden, s)
+		}
+		if len(forbidden) == len(sil.Matchers) {
+			return SilenceForbiddenMatchersError{Matchers: forbidden}
+		}
+	}
+
+	var missing []string
+	for _, name := range p.RequiredLabels {
+		if !slices.ContainsFunc(sil.Matchers, func(m *silencepb.Matcher) bool { return m.Name == name }) {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return SilenceRequiredLabelsError{Missing: missing}
+	}
+
+	return nil
+}
+
+// forbidden returns the matcher as a string if it is one of the forbidden matchers.
+func (p SilencePolicy) forbidden(m *silencepb.Matcher) (string, bool) {
+	var t labels.MatchType
+	switch m.Type {
+	case silencepb.Matcher_EQUAL:
+		t = labels.MatchEqual
+	case silencepb.Matcher_NOT_EQUAL:
+		t = labels.MatchNotEqual
+	case silencepb.Matcher_REGEXP:
+		t = labels.MatchRegexp
+	case silencepb.Matcher_NOT_REGEXP:
+		t = labels.MatchNotRegexp
+	}
+	for _, f := range p.ForbiddenMatchers {
+		if f.Type == t && f.Name == m.Name && f.Value == m.Pattern {
+			return f.String(), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,121 @@
+package notify
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-openapi/strfmt"
+	amv2 "github.com/prometheus/alertmanager/api/v2/models"
+	"github.com/prometheus/alertmanager/pkg/labels"
+	"github.com/prometheus/alertmanager/timeinterval"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSilencePolicy(t *testing.T) {
+	forbidden, err := labels.ParseMatcher(`alertname=~".*"`)
+	require.NoError(t, err)
+	policy := SilencePolicy{
+		MaxDuration:       24 * time.Hour,
+		RequireComment:    true,
+		CommentPattern:    regexp.MustCompile(`INC-\d+`),
+		ForbiddenMatchers: []*labels.Matcher{forbidden},
+		RequiredLabels:    []string{"team"},
+	}
+
+	reg := prometheus.NewPedanticRegistry()
+	am, err := NewGrafanaAlertmanager("org", 1, &GrafanaAlertmanagerConfig{
+		Silences: newFakeMaintanenceOptions(t),
+		Nflog:    newFakeMaintanenceOptions(t),
+		Limits:   Limits{SilencePolicy: policy},
+	}, &NilPeer{}, log.NewNopLogger(), NewGrafanaAlertmanagerMetrics(reg, log.NewNopLogger()))
+	require.NoError(t, err)
+
+	now := time.Now()
+	matcher := func(name, value string, isRegex bool) *amv2.Matcher {
+		return &amv2.Matcher{Name: ptr(name), Value: ptr(value), IsEqual: ptr(true), IsRegex: ptr(isRegex)}
+	}
+	silence := func(comment string, d time.Duration, matchers ...*amv2.Matcher) *PostableSilence {
+		return &PostableSilence{Silence: amv2.Silence{
+			Comment:   ptr(comment),
+			CreatedBy: ptr("user"),
+			StartsAt:  ptr(strfmt.DateTime(now)),
+			EndsAt:    ptr(strfmt.DateTime(now.Add(d))),
+			Matchers:  matchers,
+		}}
+	}
+
+	cases := []struct {
+		name     string
+		silence  *PostableSilence
+		expected error
+	}{
+		{
+			name:    "valid silence",
+			silence: silence("INC-123", time.Hour, matcher("team", "a", false)),
+		},
+		{
+			name:     "too long",
+			silence:  silence("INC-123", 48*time.Hour, matcher("team", "a", false)),
+			expected: SilenceDurationError{Duration: 48 * time.Hour, MaxDuration: 24 * time.Hour},
+		},
+		{
+			name:     "no comment",
+			silence:  silence(" ", time.Hour, matcher("team", "a", false)),
+			expected: SilenceCommentError{},
+		},
+		{
+			name:     "comment without ticket",
+			silence:  silence("maintenance", time.Hour, matcher("team", "a", false)),
+			expected: SilenceCommentError{Pattern: `INC-\d+`},
+		},
+		{
+			name:     "forbidden matchers only",
+			silence:  silence("INC-123", time.Hour, matcher("alertname", ".*", true)),
+			expected: SilenceForbiddenMatchersError{Matchers: []string{`alertname=~".*"`}},
+		},
+		{
+			name:     "missing required label",
+			silence:  silence("INC-123", time.Hour, matcher("alertname", ".*", true), matcher("severity", "low", false)),
+			expected: SilenceRequiredLabelsError{Missing: []string{"team"}},
+		},
+		{
+			name:    "forbidden matcher with other matchers",
+			silence: silence("INC-123", time.Hour, matcher("alertname", ".*", true), matcher("team", "a", false)),
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := am.CreateSilence(c.silence)
+			if c.expected == nil {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, ErrCreateSilenceBadPayload)
+			require.Equal(t, c.expected, err)
+
+			_, err = am.UpsertSilence(c.silence)
+			require.ErrorIs(t, err, ErrCreateSilenceBadPayload)
+		})
+	}
+
+	t.Run("recurring silences", func(t *testing.T) {
+		// Every day from 02:00 to 04:00 UTC.
+		rs := RecurringSilence{
+			Matchers: amv2.Matchers{matcher("team", "a", false)},
+			Schedule: []timeinterval.TimeInterval{{Times: []timeinterval.TimeRange{{StartMinute: 2 * 60, EndMinute: 4 * 60}}}},
+			Comment:  "backups",
+		}
+		_, err := am.CreateRecurringSilence(rs)
+		var commentErr SilenceCommentError
+		require.True(t, errors.As(err, &commentErr))
+
+		rs.Comment = "INC-123 backups"
+		_, err = am.CreateRecurringSilence(rs)
+		require.NoError(t, err)
+	})
+}
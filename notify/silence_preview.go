@@ -0,0 +1,161 @@
+package notify
+
+import (
+	"fmt"
+	"regexp/syntax"
+	"sort"
+	"time"
+
+	"github.com/go-kit/log/level"
+	v2 "github.com/prometheus/alertmanager/api/v2"
+	amv2 "github.com/prometheus/alertmanager/api/v2/models"
+	"github.com/prometheus/alertmanager/dispatch"
+	"github.com/prometheus/alertmanager/pkg/labels"
+	"github.com/prometheus/alertmanager/types"
+)
+
+var (
+	ErrPreviewSilenceUnavailable = fmt.Errorf("unable to preview silence as alertmanager is not initialised yet")
+)
+
+// DefaultSilencePreviewWarningFraction is the default fraction of active alerts above which a silence is considered too
+// broad.
+const DefaultSilencePreviewWarningFraction = 0.5
+
+// SilencePreview describes what a silence would mute if it was created.
+type SilencePreview struct {
+	// Alerts are the active alerts that match the silence.
+	Alerts GettableAlerts `json:"alerts"`
+	// AlertGroups are the alert groups with alerts that match the silence, with only those alerts.
+	AlertGroups AlertGroups `json:"alertGroups"`
+	// TotalAlerts is the number of active alerts.
+	TotalAlerts int `json:"totalAlerts"`
+	// Warnings describe why the silence is likely broader than intended.
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// PreviewSilence returns the active alerts and alert groups the silence would mute if it was created, and warns if its
+// matchers match every alert or more than the configured fraction of the active alerts. The silence is validated as if
+// it was created, but nothing is persisted.
+func (am *GrafanaAlertmanager) PreviewSilence(ps *PostableSilence) (*SilencePreview, error) {
+	sil, err := v2.PostableSilenceToProto(ps)
+	if err != nil {
+		level.Error(am.logger).Log("msg", "marshaling to protobuf failed", "err", err)
+		return nil, fmt.Errorf("%s: failed to convert API silence to internal silence: %w",
+			ErrCreateSilenceBadPayload.Error(), err)
+	}
+
+	if err := am.validateSilence(sil); err != nil {
+		return nil, err
+	}
+
+	matchers, err := silenceMatchers(ps.Matchers)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", err.Error(), ErrCreateSilenceBadPayload)
+	}
+
+	am.reloadConfigMtx.RLock()
+	defer am.reloadConfigMtx.RUnlock()
+
+	if !am.ready() {
+		return nil, ErrPreviewSilenceUnavailable
+	}
+
+	res := &SilencePreview{Alerts: GettableAlerts{}}
+
+	alerts := am.alerts.GetPending()
+	defer alerts.Close()
+	for a := range alerts.Next() {
+		if err := alerts.Err(); err != nil {
+			level.Error(am.logger).Log("failed to iterate through the alerts", "err", err)
+			return nil, fmt.Errorf("%s: %w", err.Error(), ErrGetAlertsInternal)
+		}
+		if a.Resolved() {
+			continue
+		}
+		res.TotalAlerts++
+		if !matchers.Matches(a.Labels) {
+			continue
+		}
+
+		routes := am.route.Match(a.Labels)
+		receivers := make([]string, 0, len(routes))
+		for _, r := range routes {
+			receivers = append(receivers, r.RouteOpts.Receiver)
+		}
+		res.Alerts = append(res.Alerts, v2.AlertToOpenAPIAlert(a, am.marker.Status(a.Fingerprint()), receivers))
+	}
+	sort.Slice(res.Alerts, func(i, j int) bool {
+		return *res.Alerts[i].Fingerprint < *res.Alerts[j].Fingerprint
+	})
+
+	alertGroups, allReceivers := am.dispatcher.Groups(
+		func(*dispatch.Route) bool { return true },
+		func(a *types.Alert, now time.Time) bool {
+			return !a.ResolvedAt(now) && matchers.Matches(a.Labels)
+		},
+	)
+	res.AlertGroups = am.toAlertGroups(alertGroups, allReceivers)
+
+	if matchesEverything(matchers) {
+		res.Warnings = append(res.Warnings, "the matchers of the silence match every alert")
+	} else if res.TotalAlerts > 0 && float64(len(res.Alerts)) > am.silencePreviewWarningFraction*float64(res.TotalAlerts) {
+		res.Warnings = append(res.Warnings, fmt.Sprintf("the silence matches %d of %d active alerts, more than %.0f%%",
+			len(res.Alerts), res.TotalAlerts, am.silencePreviewWarningFraction*100))
+	}
+
+	return res, nil
+}
+
+// silenceMatchers converts the matchers of a silence of the API to label matchers, which match label sets the same way
+// as silences.
+func silenceMatchers(matchers amv2.Matchers) (labels.Matchers, error) {
+	res := make(labels.Matchers, 0, len(matchers))
+	for i, m := range matchers {
+		if m.Name == nil || m.Value == nil {
+			return nil, fmt.Errorf("invalid label matcher %d: name and value are required", i)
+		}
+		isEqual := m.IsEqual == nil || *m.IsEqual
+		isRegex := m.IsRegex != nil && *m.IsRegex
+
+		var t labels.MatchType
+		switch {
+		case isEqual && !isRegex:
+			t = labels.MatchEqual
+		case !isEqual && !isRegex:
+			t = labels.MatchNotEqual
+		case isEqual && isRegex:
+			t = labels.MatchRegexp
+		case !isEqual && isRegex:
+			t = labels.MatchNotRegexp
+		}
+		matcher, err := labels.NewMatcher(t, *m.Name, *m.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid label matcher %d: %w", i, err)
+		}
+		res = append(res, matcher)
+	}
+	return res, nil
+}
+
+// matchesEverything returns true if the matchers match any label set, which is the case if every matcher is a regular
+// expression that matches any string, such as `.*`.
+func matchesEverything(matchers labels.Matchers) bool {
+	for _, m := range matchers {
+		if m.Type != labels.MatchRegexp {
+			return false
+		}
+		re, err := syntax.Parse(m.Value, syntax.Perl)
+		if err != nil {
+			return false
+		}
+		re = re.Simplify()
+		for re.Op == syntax.OpCapture && len(re.Sub) == 1 {
+			re = re.Sub[0]
+		}
+		if re.Op != syntax.OpStar || (re.Sub[0].Op != syntax.OpAnyChar && re.Sub[0].Op != syntax.OpAnyCharNotNL) {
+			return false
+		}
+	}
+	return true
+}
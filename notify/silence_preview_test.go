@@ -0,0 +1,114 @@
+package notify
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-openapi/strfmt"
+	amv2 "github.com/prometheus/alertmanager/api/v2/models"
+	"github.com/prometheus/alertmanager/pkg/labels"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPreviewSilence(t *testing.T) {
+	am, _ := setupAMTest(t)
+
+	now := time.Now()
+	silence := func(matchers ...*amv2.Matcher) *PostableSilence {
+		return &PostableSilence{Silence: amv2.Silence{
+			Comment:   ptr("comment"),
+			CreatedBy: ptr("user"),
+			StartsAt:  ptr(strfmt.DateTime(now)),
+			EndsAt:    ptr(strfmt.DateTime(now.Add(time.Hour))),
+			Matchers:  matchers,
+		}}
+	}
+	matcher := func(name, value string, isRegex bool) *amv2.Matcher {
+		return &amv2.Matcher{Name: ptr(name), Value: ptr(value), IsEqual: ptr(true), IsRegex: ptr(isRegex)}
+	}
+
+	_, err := am.PreviewSilence(silence(matcher("team", "a", false)))
+	require.ErrorIs(t, err, ErrPreviewSilenceUnavailable)
+
+	cfg := &testConfiguration{
+		receivers: []*APIReceiver{{ConfigReceiver: ConfigReceiver{Name: "default"}}},
+		route:     &Route{Receiver: "default", GroupBy: []model.LabelName{"alertname"}},
+	}
+	require.NoError(t, am.ApplyConfig(cfg))
+
+	var alerts amv2.PostableAlerts
+	for _, lset := range []amv2.LabelSet{
+		{"alertname": "cpu", "team": "a"},
+		{"alertname": "memory", "team": "a"},
+		{"alertname": "cpu", "team": "b"},
+		{"alertname": "disk", "team": "c"},
+	} {
+		alerts = append(alerts, &PostableAlert{
+			Alert:    amv2.Alert{Labels: lset},
+			StartsAt: strfmt.DateTime(now),
+			EndsAt:   strfmt.DateTime(now.Add(time.Hour)),
+		})
+	}
+	require.NoError(t, am.PutAlerts(alerts))
+	require.Eventually(t, func() bool {
+		groups, err := am.GetAlertGroups(true, true, true, nil, "")
+		return err == nil && len(groups) == 3
+	}, 5*time.Second, 10*time.Millisecond)
+
+	preview, err := am.PreviewSilence(silence(matcher("team", "a", false)))
+	require.NoError(t, err)
+	require.Len(t, preview.Alerts, 2)
+	require.Equal(t, 4, preview.TotalAlerts)
+	require.Len(t, preview.AlertGroups, 2)
+	for _, g := range preview.AlertGroups {
+		require.Len(t, g.Alerts, 1)
+		require.Equal(t, "a", g.Alerts[0].Labels["team"])
+	}
+	require.Empty(t, preview.Warnings)
+
+	// Silences that match more than half of the active alerts are too broad.
+	preview, err = am.PreviewSilence(silence(matcher("team", "a|b", true)))
+	require.NoError(t, err)
+	require.Len(t, preview.Alerts, 3)
+	require.Equal(t, []string{"the silence matches 3 of 4 active alerts, more than 50%"}, preview.Warnings)
+
+	preview, err = am.PreviewSilence(silence(matcher("alertname", ".*", true)))
+	require.NoError(t, err)
+	require.Len(t, preview.Alerts, 4)
+	require.Equal(t, []string{"the matchers of the silence match every alert"}, preview.Warnings)
+
+	// Silences are validated as if they were created.
+	invalid := silence(matcher("team", "a", false))
+	invalid.EndsAt = ptr(strfmt.DateTime(now.Add(-time.Minute)))
+	_, err = am.PreviewSilence(invalid)
+	require.ErrorIs(t, err, ErrCreateSilenceBadPayload)
+	_, err = am.PreviewSilence(silence(matcher("team", "(", true)))
+	require.ErrorIs(t, err, ErrCreateSilenceBadPayload)
+
+	// Nothing is persisted.
+	sils, err := am.ListSilences(nil)
+	require.NoError(t, err)
+	require.Empty(t, sils)
+}
+
+func TestMatchesEverything(t *testing.T) {
+	for _, tc := range []struct {
+		matchers []string
+		expected bool
+	}{
+		{matchers: []string{`foo=~".*"`}, expected: true},
+		{matchers: []string{`foo=~"(.*)"`, `bar=~"(?s:.*)"`}, expected: true},
+		{matchers: []string{`foo=~".+"`}, expected: false},
+		{matchers: []string{`foo=~".*"`, `bar="baz"`}, expected: false},
+		{matchers: []string{`foo!~"bar"`}, expected: false},
+	} {
+		var matchers labels.Matchers
+		for _, s := range tc.matchers {
+			m, err := labels.ParseMatcher(s)
+			require.NoError(t, err)
+			matchers = append(matchers, m)
+		}
+		require.Equal(t, tc.expected, matchesEverything(matchers), tc.matchers)
+	}
+}
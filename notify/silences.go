@@ -85,7 +85,8 @@ func (am *GrafanaAlertmanager) GetSilence(silenceID string) (GettableSilence, er
 	return sil, nil
 }
 
-// CreateSilence persists the provided silence and returns the silence ID if successful.
+// CreateSilence persists the provided silence and returns the silence ID if successful. It returns ErrSilenceNotFound
+// if the silence has the ID of a silence that is not present.
 func (am *GrafanaAlertmanager) CreateSilence(ps *PostableSilence) (string, error) {
 	sil, err := v2.PostableSilenceToProto(ps)
 	if err != nil {
@@ -100,8 +101,12 @@ func (am *GrafanaAlertmanager) CreateSilence(ps *PostableSilence) (string, error
 
 	if err := am.silences.Set(sil); err != nil {
 		level.Error(am.logger).Log("msg", "unable to save silence", "err", err)
+		if errors.Is(err, silence.ErrNotFound) {
+			return "", ErrSilenceNotFound
+		}
 		return "", fmt.Errorf("unable to save silence: %s: %w", err.Error(), ErrCreateSilenceBadPayload)
 	}
+	am.events.publish(Event{Type: EventSilenceCreated, SilenceID: sil.Id})
 
 	return sil.Id, nil
 }
@@ -121,8 +126,12 @@ func (am *GrafanaAlertmanager) UpsertSilence(ps *PostableSilence) (string, error
 
 	if err := am.silences.Upsert(sil); err != nil {
 		level.Error(am.logger).Log("msg", "unable to upsert silence", "err", err)
+		if errors.Is(err, silence.ErrNotFound) {
+			return "", ErrSilenceNotFound
+		}
 		return "", fmt.Errorf("unable to upsert silence: %s: %w", err.Error(), ErrCreateSilenceBadPayload)
 	}
+	am.events.publish(Event{Type: EventSilenceCreated, SilenceID: sil.Id})
 
 	return sil.Id, nil
 }
@@ -140,6 +149,11 @@ func (am *GrafanaAlertmanager) validateSilence(sil *silencepb.Silence) error {
 		return fmt.Errorf("%s: %w", msg, ErrCreateSilenceBadPayload)
 	}
 
+	if err := am.silencePolicy.validate(sil); err != nil {
+		level.Error(am.logger).Log("msg", "silence violates the silence policy", "err", err)
+		return err
+	}
+
 	return nil
 }
 
@@ -151,6 +165,7 @@ func (am *GrafanaAlertmanager) DeleteSilence(silenceID string) error {
 		}
 		return fmt.Errorf("%s: %w", err.Error(), ErrDeleteSilenceInternal)
 	}
+	am.events.publish(Event{Type: EventSilenceExpired, SilenceID: silenceID})
 
 	return nil
 }
@@ -0,0 +1,280 @@
+package notify
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/go-kit/log/level"
+	v2 "github.com/prometheus/alertmanager/api/v2"
+	"github.com/prometheus/alertmanager/silence"
+	"github.com/prometheus/alertmanager/silence/silencepb"
+	"github.com/prometheus/alertmanager/types"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	ErrExpireSilencesBadPayload = fmt.Errorf("unable to expire silences")
+	ErrImportSilencesBadPayload = fmt.Errorf("unable to import silences")
+)
+
+// SilenceFormat is the format of exported silences.
+type SilenceFormat string
+
+const (
+	SilenceFormatJSON SilenceFormat = "json"
+	SilenceFormatYAML SilenceFormat = "yaml"
+)
+
+// SilenceConflictMode decides what happens to imported silences with the ID of an existing silence.
+type SilenceConflictMode string
+
+const (
+	// SilenceConflictSkip keeps the existing silence.
+	SilenceConflictSkip SilenceConflictMode = "skip"
+	// SilenceConflictOverwrite replaces the existing silence.
+	SilenceConflictOverwrite SilenceConflictMode = "overwrite"
+	// SilenceConflictNewID creates the imported silence with a new ID, next to the existing silence.
+	SilenceConflictNewID SilenceConflictMode = "new_id"
+)
+
+// SilencesExport is the portable form of a set of silences. Silences keep their IDs, so that importing them again
+// detects conflicts.
+type SilencesExport struct {
+	Silences []*PostableSilence `json:"silences"`
+}
+
+// SilencesImportResult describes what happened to every imported silence.
+type SilencesImportResult struct {
+	// Created are the IDs of the created silences.
+	Created []string `json:"created"`
+	// Overwritten are the IDs of the silences that replaced existing ones. A replaced silence that cannot be updated in
+	// place is expired and the silence gets a new ID, as with UpsertSilence.
+	Overwritten []string `json:"overwritten"`
+	// Skipped are the IDs of the imported silences that conflicted with existing ones.
+	Skipped []string `json:"skipped"`
+}
+
+// ExpireSilences expires the active and pending silences that match the filter, and returns their IDs. The filter is
+// the same as the filter of ListSilences, and must not be empty.
+func (am *GrafanaAlertmanager) ExpireSilences(filter []string) ([]string, error) {
+	matchers, err := parseFilter(filter)
+	if err != nil {
+		level.Error(am.logger).Log("msg", "failed to parse matchers", "err", err)
+		return nil, fmt.Errorf("%s: %w", err.Error(), ErrExpireSilencesBadPayload)
+	}
+	if len(matchers) == 0 {
+		return nil, fmt.Errorf("at least one filter is required: %w", ErrExpireSilencesBadPayload)
+	}
+
+	psils, _, err := am.silences.Query(silence.QState(types.SilenceStateActive, types.SilenceStatePending))
+	if err != nil {
+		level.Error(am.logger).Log("msg", ErrGetSilencesInternal.Error(), "err", err)
+		return nil, fmt.Errorf("%s: %w", ErrGetSilencesInternal.Error(), err)
+	}
+
+	expired := []string{}
+	var errs []error
+	for _, ps := range psils {
+		if !v2.CheckSilenceMatchesFilterLabels(ps, matchers) {
+			continue
+		}
+		if err := am.silences.Expire(ps.Id); err != nil {
+			errs = append(errs, fmt.Errorf("silence %s: %w", ps.Id, err))
+			continue
+		}
+		expired = append(expired, ps.Id)
+		am.events.publish(Event{Type: EventSilenceExpired, SilenceID: ps.Id})
+	}
+	if len(errs) > 0 {
+		return expired, fmt.Errorf("%w: %w", errors.Join(errs...), ErrDeleteSilenceInternal)
+	}
+	level.Info(am.logger).Log("msg", "Expired silences", "count", len(expired))
+	return expired, nil
+}
+
+// CreateSilences creates the silences and returns their IDs, in the same order. Every silence is validated before any
+// is created. If one cannot be saved, the IDs of the silences created so far are returned with the error.
+func (am *GrafanaAlertmanager) CreateSilences(pss []*PostableSilence) ([]string, error) {
+	sils, err := am.toValidSilences(pss)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(sils))
+	for i, sil := range sils {
+		if err := am.silences.Set(sil); err != nil {
+			level.Error(am.logger).Log("msg", "unable to save silence", "err", err)
+			return ids, fmt.Errorf("unable to save silence %d: %s: %w", i, err.Error(), ErrCreateSilenceBadPayload)
+		}
+		ids = append(ids, sil.Id)
+		am.events.publish(Event{Type: EventSilenceCreated, SilenceID: sil.Id})
+	}
+	return ids, nil
+}
+
+// toValidSilences converts the silences of the API to internal silences and validates them.
+func (am *GrafanaAlertmanager) toValidSilences(pss []*PostableSilence) ([]*silencepb.Silence, error) {
+	sils := make([]*silencepb.Silence, 0, len(pss))
+	for i, ps := range pss {
+		if ps == nil || ps.StartsAt == nil || ps.EndsAt == nil || ps.Comment == nil || ps.CreatedBy == nil {
+			return nil, fmt.Errorf("silence %d: starts at, ends at, comment and created by are required: %w", i, ErrCreateSilenceBadPayload)
+		}
+		if _, err := silenceMatchers(ps.Matchers); err != nil {
+			return nil, fmt.Errorf("silence %d: %s: %w", i, err.Error(), ErrCreateSilenceBadPayload)
+		}
+		sil, err := v2.PostableSilenceToProto(ps)
+		if err != nil {
+			level.Error(am.logger).Log("msg", "marshaling to protobuf failed", "err", err)
+			return nil, fmt.Errorf("%s: failed to convert API silence %d to internal silence: %w",
+				ErrCreateSilenceBadPayload.Error(), i, err)
+		}
+		if err := am.validateSilence(sil); err != nil {
+			return nil, fmt.Errorf("silence %d: %w", i, err)
+		}
+		sils = append(sils, sil)
+	}
+	return sils, nil
+}
+
+// ExportSilences exports the active and pending silences in the given format.
+func (am *GrafanaAlertmanager) ExportSilences(format SilenceFormat) ([]byte, error) {
+	psils, _, err := am.silences.Query(silence.QState(types.SilenceStateActive, types.SilenceStatePending))
+	if err != nil {
+		level.Error(am.logger).Log("msg", ErrGetSilencesInternal.Error(), "err", err)
+		return nil, fmt.Errorf("%s: %w", ErrGetSilencesInternal.Error(), err)
+	}
+
+	sils := make(GettableSilences, 0, len(psils))
+	for _, ps := range psils {
+		sil, err := v2.GettableSilenceFromProto(ps)
+		if err != nil {
+			level.Error(am.logger).Log("msg", "unmarshaling from protobuf failed", "err", err)
+			return nil, fmt.Errorf("%s: failed to convert internal silence to API silence: %w",
+				ErrGetSilencesInternal.Error(), err)
+		}
+		sils = append(sils, &sil)
+	}
+	v2.SortSilences(sils)
+
+	export := SilencesExport{Silences: make([]*PostableSilence, 0, len(sils))}
+	for _, sil := range sils {
+		export.Silences = append(export.Silences, &PostableSilence{ID: *sil.ID, Silence: sil.Silence})
+	}
+	b, err := encodeSilencesExport(export, format)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", err.Error(), ErrListSilencesBadPayload)
+	}
+	return b, nil
+}
+
+// ImportSilences imports silences exported by ExportSilences. Silences keep their IDs unless they conflict with an
+// existing silence, in which case the conflict mode applies. Every silence is validated before any is imported. If one
+// cannot be saved, what was imported so far is returned with the error.
+func (am *GrafanaAlertmanager) ImportSilences(data []byte, format SilenceFormat, mode SilenceConflictMode) (SilencesImportResult, error) {
+	res := SilencesImportResult{Created: []string{}, Overwritten: []string{}, Skipped: []string{}}
+	switch mode {
+	case SilenceConflictSkip, SilenceConflictOverwrite, SilenceConflictNewID:
+	default:
+		return res, fmt.Errorf("unknown conflict mode %q: %w", mode, ErrImportSilencesBadPayload)
+	}
+
+	export, err := decodeSilencesExport(data, format)
+	if err != nil {
+		return res, fmt.Errorf("%s: %w", err.Error(), ErrImportSilencesBadPayload)
+	}
+	sils, err := am.toValidSilences(export.Silences)
+	if err != nil {
+		return res, err
+	}
+
+	for i, sil := range sils {
+		var (
+			conflict = false
+			save     = am.silences.Upsert
+			result   = &res.Created
+		)
+		if sil.Id != "" {
+			_, err := am.silences.QueryOne(silence.QIDs(sil.Id))
+			conflict = err == nil
+		}
+		if conflict {
+			switch mode {
+			case SilenceConflictSkip:
+				res.Skipped = append(res.Skipped, sil.Id)
+				continue
+			case SilenceConflictOverwrite:
+				save, result = am.silences.Set, &res.Overwritten
+			case SilenceConflictNewID:
+				sil.Id = ""
+				save = am.silences.Set
+			}
+		} else if sil.Id == "" {
+			save = am.silences.Set
+		}
+
+		if err := save(sil); err != nil {
+			level.Error(am.logger).Log("msg", "unable to import silence", "err", err)
+			return res, fmt.Errorf("unable to import silence %d: %s: %w", i, err.Error(), ErrImportSilencesBadPayload)
+		}
+		*result = append(*result, sil.Id)
+		am.events.publish(Event{Type: EventSilenceCreated, SilenceID: sil.Id})
+	}
+
+	level.Info(am.logger).Log("msg", "Imported silences", "created", len(res.Created), "overwritten", len(res.Overwritten), "skipped", len(res.Skipped))
+	return res, nil
+}
+
+func encodeSilencesExport(export SilencesExport, format SilenceFormat) ([]byte, error) {
+	b, err := json.Marshal(export)
+	if err != nil {
+		return nil, err
+	}
+	switch format {
+	case SilenceFormatJSON:
+		return b, nil
+	case SilenceFormatYAML:
+		// The API models only have JSON tags, so YAML documents are converted from their JSON form, which is valid
+		// YAML, to keep the same field names.
+		var doc yaml.Node
+		if err := yaml.Unmarshal(b, &doc); err != nil {
+			return nil, err
+		}
+		setBlockStyle(&doc)
+		return yaml.Marshal(&doc)
+	default:
+		return nil, fmt.Errorf("unknown silence format %q", format)
+	}
+}
+
+func decodeSilencesExport(data []byte, format SilenceFormat) (SilencesExport, error) {
+	var export SilencesExport
+	switch format {
+	case SilenceFormatJSON:
+	case SilenceFormatYAML:
+		var doc interface{}
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return export, err
+		}
+		b, err := json.Marshal(doc)
+		if err != nil {
+			return export, err
+		}
+		data = b
+	default:
+		return export, fmt.Errorf("unknown silence format %q", format)
+	}
+	err := json.Unmarshal(data, &export)
+	return export, err
+}
+
+// setBlockStyle makes YAML nodes parsed from JSON render in block style.
+func setBlockStyle(n *yaml.Node) {
+	n.Style &^= yaml.FlowStyle
+	if n.Kind == yaml.ScalarNode && n.Tag == "!!str" {
+		n.Style &^= yaml.DoubleQuotedStyle
+	}
+	for _, c := range n.Content {
+		setBlockStyle(c)
+	}
+}
@@ -0,0 +1,126 @@
+package notify
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-openapi/strfmt"
+	amv2 "github.com/prometheus/alertmanager/api/v2/models"
+	"github.com/stretchr/testify/require"
+)
+
+func testSilence(now time.Time, name, value string) *PostableSilence {
+	return &PostableSilence{Silence: amv2.Silence{
+		Comment:   ptr("comment"),
+		CreatedBy: ptr("user"),
+		StartsAt:  ptr(strfmt.DateTime(now)),
+		EndsAt:    ptr(strfmt.DateTime(now.Add(time.Hour))),
+		Matchers:  amv2.Matchers{{Name: ptr(name), Value: ptr(value), IsEqual: ptr(true), IsRegex: ptr(false)}},
+	}}
+}
+
+func TestCreateAndExpireSilences(t *testing.T) {
+	am, _ := setupAMTest(t)
+	now := time.Now()
+
+	// Nothing is created if a silence is invalid.
+	invalid := testSilence(now, "team", "b")
+	invalid.EndsAt = ptr(strfmt.DateTime(now.Add(-time.Minute)))
+	_, err := am.CreateSilences([]*PostableSilence{testSilence(now, "team", "a"), invalid})
+	require.ErrorIs(t, err, ErrCreateSilenceBadPayload)
+	require.ErrorContains(t, err, "silence 1")
+	sils, err := am.ListSilences(nil)
+	require.NoError(t, err)
+	require.Empty(t, sils)
+
+	ids, err := am.CreateSilences([]*PostableSilence{
+		testSilence(now, "team", "a"),
+		testSilence(now, "team", "a"),
+		testSilence(now, "team", "b"),
+	})
+	require.NoError(t, err)
+	require.Len(t, ids, 3)
+
+	_, err = am.ExpireSilences(nil)
+	require.ErrorIs(t, err, ErrExpireSilencesBadPayload)
+	_, err = am.ExpireSilences([]string{"team=~("})
+	require.ErrorIs(t, err, ErrExpireSilencesBadPayload)
+
+	expired, err := am.ExpireSilences([]string{"team=a"})
+	require.NoError(t, err)
+	require.ElementsMatch(t, ids[:2], expired)
+
+	for i, id := range ids {
+		sil, err := am.GetSilence(id)
+		require.NoError(t, err)
+		if i < 2 {
+			require.Equal(t, amv2.SilenceStatusStateExpired, *sil.Status.State)
+		} else {
+			require.Equal(t, amv2.SilenceStatusStateActive, *sil.Status.State)
+		}
+	}
+
+	// Expired silences are not expired again.
+	expired, err = am.ExpireSilences([]string{"team=a"})
+	require.NoError(t, err)
+	require.Empty(t, expired)
+}
+
+func TestExportImportSilences(t *testing.T) {
+	now := time.Now()
+	for _, format := range []SilenceFormat{SilenceFormatJSON, SilenceFormatYAML} {
+		t.Run(string(format), func(t *testing.T) {
+			src, _ := setupAMTest(t)
+			ids, err := src.CreateSilences([]*PostableSilence{testSilence(now, "team", "a"), testSilence(now, "team", "b")})
+			require.NoError(t, err)
+			expiredID, err := src.CreateSilence(testSilence(now, "team", "c"))
+			require.NoError(t, err)
+			require.NoError(t, src.DeleteSilence(expiredID))
+
+			data, err := src.ExportSilences(format)
+			require.NoError(t, err)
+
+			// Silences keep their IDs, and only active and pending silences are exported.
+			dst, _ := setupAMTest(t)
+			res, err := dst.ImportSilences(data, format, SilenceConflictSkip)
+			require.NoError(t, err)
+			require.ElementsMatch(t, ids, res.Created)
+			for _, id := range ids {
+				exported, err := src.GetSilence(id)
+				require.NoError(t, err)
+				imported, err := dst.GetSilence(id)
+				require.NoError(t, err)
+				require.Equal(t, exported.Matchers, imported.Matchers)
+				require.Equal(t, *exported.Comment, *imported.Comment)
+				require.Equal(t, time.Time(*exported.EndsAt).Unix(), time.Time(*imported.EndsAt).Unix())
+			}
+
+			res, err = dst.ImportSilences(data, format, SilenceConflictSkip)
+			require.NoError(t, err)
+			require.Empty(t, res.Created)
+			require.ElementsMatch(t, ids, res.Skipped)
+
+			res, err = dst.ImportSilences(data, format, SilenceConflictOverwrite)
+			require.NoError(t, err)
+			require.Len(t, res.Overwritten, 2)
+
+			res, err = dst.ImportSilences(data, format, SilenceConflictNewID)
+			require.NoError(t, err)
+			require.Len(t, res.Created, 2)
+			require.NotContains(t, res.Created, ids[0])
+			require.NotContains(t, res.Created, ids[1])
+
+			sils, err := dst.ListSilences([]string{"team=a"})
+			require.NoError(t, err)
+			require.Len(t, sils, 2)
+		})
+	}
+
+	am, _ := setupAMTest(t)
+	_, err := am.ExportSilences("xml")
+	require.ErrorIs(t, err, ErrListSilencesBadPayload)
+	_, err = am.ImportSilences([]byte(`{}`), SilenceFormatJSON, "replace")
+	require.ErrorIs(t, err, ErrImportSilencesBadPayload)
+	_, err = am.ImportSilences([]byte(`silences: [`), SilenceFormatYAML, SilenceConflictSkip)
+	require.ErrorIs(t, err, ErrImportSilencesBadPayload)
+}
@@ -1,13 +1,217 @@
 package notify
 
-// TODO(gotjosh): I don't think this is right, make sure you evaluate it.
-func (am *GrafanaAlertmanager) GetStatus() []byte {
+import (
+	"encoding/hex"
+	"runtime/debug"
+	"sort"
+	"time"
+
+	"github.com/go-openapi/strfmt"
+	amv2 "github.com/prometheus/alertmanager/api/v2/models"
+	"github.com/prometheus/alertmanager/dispatch"
+	"github.com/prometheus/alertmanager/types"
+	"github.com/prometheus/common/version"
+
+	"github.com/grafana/alerting/cluster"
+)
+
+// libraryModule is the module path of this library, used to find its version in the build information.
+const libraryModule = "github.com/grafana/alerting"
+
+// Status is the status of the Alertmanager. It has the fields of the status of the upstream Alertmanager API, see
+// APIStatus, and the state of the Grafana Alertmanager.
+type Status struct {
+	Config  ConfigStatus  `json:"config"`
+	Cluster ClusterStatus `json:"cluster"`
+	// StartedAt is the time the Alertmanager was created, from which its uptime is counted.
+	StartedAt   time.Time   `json:"startedAt"`
+	VersionInfo VersionInfo `json:"versionInfo"`
+	// Templates are the names of the templates of the current configuration.
+	Templates []string `json:"templates"`
+	// Alerts are the numbers of alerts by state, as of their last evaluation by the silencer and the inhibitor.
+	Alerts AlertCounts `json:"alerts"`
+	// AlertGroups is the number of aggregation groups of the dispatcher.
+	AlertGroups int `json:"alertGroups"`
+	// Silences are the numbers of silences by state.
+	Silences SilenceCounts `json:"silences"`
+}
+
+// ConfigStatus describes the current configuration and the outcome of the last attempt to apply one.
+type ConfigStatus struct {
+	// Hash is the hex-encoded hash of the current configuration. It is empty until a configuration is applied.
+	Hash      string    `json:"hash"`
+	AppliedAt time.Time `json:"appliedAt"`
+	// Original is the raw current configuration.
+	Original string `json:"original"`
+	// LastError is the error of the last call to ApplyConfig, if it failed, and LastErrorAt when it happened. Errors
+	// are cleared by the next configuration that is applied successfully.
+	LastError   string    `json:"lastError,omitempty"`
+	LastErrorAt time.Time `json:"lastErrorAt,omitempty"`
+}
+
+// ClusterStatus describes the cluster the Alertmanager is a member of.
+type ClusterStatus struct {
+	// Name is the name of the peer in the cluster. It is empty if the peer cannot describe the cluster.
+	Name string `json:"name,omitempty"`
+	// Status is "ready" or "settling", or "disabled" if the peer cannot describe the cluster, as with NilPeer.
+	Status   string       `json:"status"`
+	Ready    bool         `json:"ready"`
+	Position int          `json:"position"`
+	Peers    []PeerStatus `json:"peers"`
+}
+
+type PeerStatus struct {
+	Name    string `json:"name"`
+	Address string `json:"address"`
+}
+
+// VersionInfo describes the build. Version is the version of this library, the other fields are those of the binary.
+type VersionInfo struct {
+	Version   string `json:"version"`
+	Revision  string `json:"revision"`
+	Branch    string `json:"branch"`
+	BuildUser string `json:"buildUser"`
+	BuildDate string `json:"buildDate"`
+	GoVersion string `json:"goVersion"`
+}
+
+type AlertCounts struct {
+	Active      int `json:"active"`
+	Suppressed  int `json:"suppressed"`
+	Unprocessed int `json:"unprocessed"`
+}
+
+type SilenceCounts struct {
+	Active  int `json:"active"`
+	Pending int `json:"pending"`
+	Expired int `json:"expired"`
+}
+
+// statusPeer is implemented by cluster peers that can describe the cluster, such as cluster.Peer.
+type statusPeer interface {
+	Name() string
+	Status() string
+	Peers() []cluster.ClusterMember
+}
+
+// GetStatus returns the status of the Alertmanager.
+func (am *GrafanaAlertmanager) GetStatus() Status {
 	am.reloadConfigMtx.RLock()
 	defer am.reloadConfigMtx.RUnlock()
 
-	if am.ready() {
-		return am.config
+	s := Status{
+		Config: ConfigStatus{
+			LastErrorAt: am.lastApplyConfigErrorAt,
+		},
+		Cluster:     am.clusterStatus(),
+		StartedAt:   am.startedAt,
+		VersionInfo: versionInfo(),
+		Templates:   []string{},
+	}
+	if am.lastApplyConfigError != nil {
+		s.Config.LastError = am.lastApplyConfigError.Error()
+	}
+
+	s.Alerts = AlertCounts{
+		Active:      am.marker.Count(types.AlertStateActive),
+		Suppressed:  am.marker.Count(types.AlertStateSuppressed),
+		Unprocessed: am.marker.Count(types.AlertStateUnprocessed),
+	}
+	for state, n := range map[types.SilenceState]*int{
+		types.SilenceStateActive:  &s.Silences.Active,
+		types.SilenceStatePending: &s.Silences.Pending,
+		types.SilenceStateExpired: &s.Silences.Expired,
+	} {
+		// Counting silences only fails for unknown states.
+		*n, _ = am.silences.CountState(state)
+	}
+
+	if !am.ready() {
+		return s
 	}
 
-	return nil
+	s.Config.Hash = hex.EncodeToString(am.configHash[:])
+	s.Config.AppliedAt = am.configAppliedAt
+	s.Config.Original = string(am.config)
+	for _, t := range am.templates {
+		s.Templates = append(s.Templates, t.Name)
+	}
+	groups, _ := am.dispatcher.Groups(func(*dispatch.Route) bool { return true }, func(*types.Alert, time.Time) bool { return true })
+	s.AlertGroups = len(groups)
+
+	return s
+}
+
+func (am *GrafanaAlertmanager) clusterStatus() ClusterStatus {
+	s := ClusterStatus{
+		Status:   amv2.ClusterStatusStatusDisabled,
+		Ready:    true,
+		Position: am.peer.Position(),
+		Peers:    []PeerStatus{},
+	}
+	p, ok := am.peer.(statusPeer)
+	if !ok {
+		return s
+	}
+
+	s.Name = p.Name()
+	s.Status = p.Status()
+	s.Ready = s.Status == amv2.ClusterStatusStatusReady
+	for _, m := range p.Peers() {
+		s.Peers = append(s.Peers, PeerStatus{Name: m.Name(), Address: m.Address()})
+	}
+	sort.Slice(s.Peers, func(i, j int) bool {
+		return s.Peers[i].Name < s.Peers[j].Name
+	})
+	return s
+}
+
+func versionInfo() VersionInfo {
+	v := VersionInfo{
+		Version:   version.Version,
+		Revision:  version.Revision,
+		Branch:    version.Branch,
+		BuildUser: version.BuildUser,
+		BuildDate: version.BuildDate,
+		GoVersion: version.GoVersion,
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if info.Main.Path == libraryModule {
+			v.Version = info.Main.Version
+		}
+		for _, dep := range info.Deps {
+			if dep.Path == libraryModule {
+				v.Version = dep.Version
+			}
+		}
+	}
+	return v
+}
+
+// APIStatus converts the status to the status of the upstream Alertmanager API.
+func (s Status) APIStatus() *amv2.AlertmanagerStatus {
+	startedAt := strfmt.DateTime(s.StartedAt)
+	peers := make([]*amv2.PeerStatus, 0, len(s.Cluster.Peers))
+	for _, p := range s.Cluster.Peers {
+		peers = append(peers, &amv2.PeerStatus{Name: ptr(p.Name), Address: ptr(p.Address)})
+	}
+	return &amv2.AlertmanagerStatus{
+		Cluster: &amv2.ClusterStatus{
+			Name:   s.Cluster.Name,
+			Status: ptr(s.Cluster.Status),
+			Peers:  peers,
+		},
+		Config: &amv2.AlertmanagerConfig{
+			Original: ptr(s.Config.Original),
+		},
+		Uptime: &startedAt,
+		VersionInfo: &amv2.VersionInfo{
+			Version:   ptr(s.VersionInfo.Version),
+			Revision:  ptr(s.VersionInfo.Revision),
+			Branch:    ptr(s.VersionInfo.Branch),
+			BuildUser: ptr(s.VersionInfo.BuildUser),
+			BuildDate: ptr(s.VersionInfo.BuildDate),
+			GoVersion: ptr(s.VersionInfo.GoVersion),
+		},
+	}
 }
@@ -0,0 +1,106 @@
+package notify
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-openapi/strfmt"
+	amv2 "github.com/prometheus/alertmanager/api/v2/models"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/alerting/cluster"
+	"github.com/grafana/alerting/templates"
+)
+
+type fakeMember struct{ name, address string }
+
+func (m fakeMember) Name() string    { return m.name }
+func (m fakeMember) Address() string { return m.address }
+
+type fakeStatusPeer struct {
+	NilPeer
+	status string
+}
+
+func (p *fakeStatusPeer) Name() string   { return "b" }
+func (p *fakeStatusPeer) Status() string { return p.status }
+func (p *fakeStatusPeer) Peers() []cluster.ClusterMember {
+	return []cluster.ClusterMember{fakeMember{"b", "10.0.0.2"}, fakeMember{"a", "10.0.0.1"}}
+}
+
+func TestGetStatus(t *testing.T) {
+	am, _ := setupAMTest(t)
+
+	s := am.GetStatus()
+	require.Empty(t, s.Config.Hash)
+	require.Empty(t, s.Config.LastError)
+	require.Equal(t, ClusterStatus{Status: amv2.ClusterStatusStatusDisabled, Ready: true, Peers: []PeerStatus{}}, s.Cluster)
+	require.False(t, s.StartedAt.IsZero())
+	require.Empty(t, s.Templates)
+
+	cfg := &testConfiguration{
+		receivers: []*APIReceiver{{ConfigReceiver: ConfigReceiver{Name: "default"}}},
+		route:     &Route{Receiver: "default", GroupBy: []model.LabelName{"alertname"}},
+		templates: []templates.TemplateDefinition{{Name: "invalid", Template: `{{ define "invalid" }}`}},
+	}
+	require.Error(t, am.ApplyConfig(cfg))
+	s = am.GetStatus()
+	require.NotEmpty(t, s.Config.LastError)
+	require.False(t, s.Config.LastErrorAt.IsZero())
+	require.Empty(t, s.Config.Hash)
+
+	cfg.templates = []templates.TemplateDefinition{{Name: "valid", Template: `{{ define "valid" }}{{ end }}`}}
+	require.NoError(t, am.ApplyConfig(cfg))
+
+	now := time.Now()
+	require.NoError(t, am.PutAlerts(amv2.PostableAlerts{
+		{Alert: amv2.Alert{Labels: amv2.LabelSet{"alertname": "a"}}, StartsAt: strfmt.DateTime(now), EndsAt: strfmt.DateTime(now.Add(time.Hour))},
+		{Alert: amv2.Alert{Labels: amv2.LabelSet{"alertname": "b"}}, StartsAt: strfmt.DateTime(now), EndsAt: strfmt.DateTime(now.Add(time.Hour))},
+	}))
+	_, err := am.CreateSilence(testSilence(now, "alertname", "a"))
+	require.NoError(t, err)
+	_, err = am.GetAlerts(true, true, true, nil, "")
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		return am.GetStatus().AlertGroups == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	s = am.GetStatus()
+	require.Equal(t, strings.Repeat("0", 32), s.Config.Hash)
+	require.False(t, s.Config.AppliedAt.IsZero())
+	require.Equal(t, "{}", s.Config.Original)
+	require.Empty(t, s.Config.LastError)
+	require.Equal(t, []string{"valid"}, s.Templates)
+	require.Equal(t, AlertCounts{Active: 1, Suppressed: 1}, s.Alerts)
+	require.Equal(t, SilenceCounts{Active: 1}, s.Silences)
+
+	api := s.APIStatus()
+	require.Equal(t, "{}", *api.Config.Original)
+	require.Equal(t, amv2.ClusterStatusStatusDisabled, *api.Cluster.Status)
+	require.Equal(t, s.VersionInfo.GoVersion, *api.VersionInfo.GoVersion)
+	require.Equal(t, s.StartedAt.UnixNano(), time.Time(*api.Uptime).UnixNano())
+}
+
+func TestGetStatus_Cluster(t *testing.T) {
+	peer := &fakeStatusPeer{status: amv2.ClusterStatusStatusSettling}
+	am := &GrafanaAlertmanager{peer: peer}
+
+	s := am.clusterStatus()
+	require.Equal(t, ClusterStatus{
+		Name:   "b",
+		Status: amv2.ClusterStatusStatusSettling,
+		Peers:  []PeerStatus{{Name: "a", Address: "10.0.0.1"}, {Name: "b", Address: "10.0.0.2"}},
+	}, s)
+
+	peer.status = amv2.ClusterStatusStatusReady
+	s = am.clusterStatus()
+	require.True(t, s.Ready)
+
+	api := Status{Cluster: s}.APIStatus()
+	require.Equal(t, "b", api.Cluster.Name)
+	require.Len(t, api.Cluster.Peers, 2)
+	require.Equal(t, "10.0.0.1", *api.Cluster.Peers[0].Address)
+}
@@ -46,10 +46,11 @@ func newFakeMaintanenceOptions(t *testing.T) *fakeMaintenanceOptions {
 }
 
 type fakeMaintenanceOptions struct {
+	initialState string
 }
 
 func (f *fakeMaintenanceOptions) InitialState() string {
-	return ""
+	return f.initialState
 }
 
 func (f *fakeMaintenanceOptions) Retention() time.Duration {
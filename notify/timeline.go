@@ -0,0 +1,120 @@
+package notify
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/common/model"
+)
+
+// DefaultAlertTimelineSize is the number of events kept in the timeline of an alert when no size is configured.
+const DefaultAlertTimelineSize = 100
+
+var (
+	ErrAlertTimelineBadPayload = fmt.Errorf("unable to retrieve alert timeline")
+)
+
+// AlertTimeline returns the events of the alert with the given fingerprint, oldest first: every time it was received,
+// its state changes, and the notifications that included it. Events are kept for the retention of the notification
+// log, and only the most recent ones are kept for alerts with many events. It returns ErrAlertNotFound if there are no
+// events for the alert.
+func (am *GrafanaAlertmanager) AlertTimeline(fingerprint string) ([]Event, error) {
+	fp, err := model.ParseFingerprint(fingerprint)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", err.Error(), ErrAlertTimelineBadPayload)
+	}
+	events := am.timeline.events(fp.String())
+	if len(events) == 0 {
+		return nil, ErrAlertNotFound
+	}
+	return events, nil
+}
+
+// alertTimeline keeps the most recent events of every alert, by fingerprint.
+type alertTimeline struct {
+	mtx    sync.RWMutex
+	size   int
+	alerts map[string][]Event
+}
+
+func newAlertTimeline(size int) *alertTimeline {
+	if size <= 0 {
+		size = DefaultAlertTimelineSize
+	}
+	return &alertTimeline{
+		size:   size,
+		alerts: make(map[string][]Event),
+	}
+}
+
+// record adds the event to the timelines of its alerts. Notification events belong to every alert of the notification,
+// and events of no alert are ignored. It is a no-op on a nil timeline.
+func (t *alertTimeline) record(e Event) {
+	if t == nil {
+		return
+	}
+	fingerprints := []string{e.Fingerprint}
+	if e.Notification != nil {
+		fingerprints = e.Notification.Fingerprints
+	}
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	for _, fp := range fingerprints {
+		if fp == "" {
+			continue
+		}
+		events := append(t.alerts[fp], e)
+		if len(events) > t.size {
+			events = events[len(events)-t.size:]
+		}
+		t.alerts[fp] = events
+	}
+}
+
+// events returns a copy of the events of the alert, oldest first.
+func (t *alertTimeline) events(fingerprint string) []Event {
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+	return append([]Event(nil), t.alerts[fingerprint]...)
+}
+
+// gc removes the events older than the retention, and the timelines left without events. It returns the number of
+// removed events.
+func (t *alertTimeline) gc(now time.Time, retention time.Duration) int {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	removed := 0
+	for fp, events := range t.alerts {
+		i := 0
+		for i < len(events) && now.Sub(events[i].Timestamp) > retention {
+			i++
+		}
+		removed += i
+		if i == len(events) {
+			delete(t.alerts, fp)
+		} else if i > 0 {
+			t.alerts[fp] = append([]Event(nil), events[i:]...)
+		}
+	}
+	return removed
+}
+
+func (am *GrafanaAlertmanager) alertTimelineMaintenance(frequency, retention time.Duration) {
+	t := time.NewTicker(frequency)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-am.stopc:
+			return
+		case <-t.C:
+			start := time.Now()
+			removed := am.timeline.gc(start, retention)
+			level.Debug(am.logger).Log("msg", "alert timeline maintenance done", "duration", time.Since(start), "removed", removed)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package notify
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-openapi/strfmt"
+	amv2 "github.com/prometheus/alertmanager/api/v2/models"
+	"github.com/prometheus/alertmanager/types"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlertTimeline(t *testing.T) {
+	am, _ := setupAMTest(t)
+	cfg := &testConfiguration{
+		receivers: []*APIReceiver{{ConfigReceiver: ConfigReceiver{Name: "default"}}},
+		route:     &Route{Receiver: "default", GroupBy: []model.LabelName{"alertname"}},
+	}
+	require.NoError(t, am.ApplyConfig(cfg))
+
+	_, err := am.AlertTimeline("invalid")
+	require.ErrorIs(t, err, ErrAlertTimelineBadPayload)
+	_, err = am.AlertTimeline("0000000000000001")
+	require.ErrorIs(t, err, ErrAlertNotFound)
+
+	now := time.Now()
+	alert := &PostableAlert{
+		Alert:    amv2.Alert{Labels: amv2.LabelSet{"alertname": "test"}},
+		StartsAt: strfmt.DateTime(now),
+		EndsAt:   strfmt.DateTime(now.Add(time.Hour)),
+	}
+	require.NoError(t, am.PutAlerts(amv2.PostableAlerts{alert}))
+	require.NoError(t, am.PutAlerts(amv2.PostableAlerts{alert}))
+	_, err = am.GetAlerts(true, true, true, nil, "")
+	require.NoError(t, err)
+
+	id, err := am.CreateSilence(testSilence(now, "alertname", "test"))
+	require.NoError(t, err)
+	_, err = am.GetAlerts(true, true, true, nil, "")
+	require.NoError(t, err)
+
+	alert.EndsAt = strfmt.DateTime(now)
+	require.NoError(t, am.PutAlerts(amv2.PostableAlerts{alert}))
+
+	fingerprint := model.LabelSet{"alertname": "test"}.Fingerprint().String()
+	events, err := am.AlertTimeline(fingerprint)
+	require.NoError(t, err)
+
+	// Silence events are not part of the timeline of alerts.
+	type transition struct {
+		Type       EventType
+		State      string
+		SilencedBy []string
+	}
+	transitions := make([]transition, 0, len(events))
+	for _, e := range events {
+		require.Equal(t, fingerprint, e.Fingerprint)
+		transitions = append(transitions, transition{Type: e.Type, State: e.State, SilencedBy: e.SilencedBy})
+	}
+	require.Equal(t, []transition{
+		{Type: EventAlertReceived},
+		{Type: EventAlertReceived},
+		{Type: EventAlertStateChanged, State: string(types.AlertStateActive)},
+		{Type: EventAlertStateChanged, State: string(types.AlertStateSuppressed), SilencedBy: []string{id}},
+		{Type: EventAlertReceived},
+		{Type: EventAlertStateChanged, State: AlertStateResolved},
+	}, transitions)
+}
+
+func TestAlertTimeline_Record(t *testing.T) {
+	timeline := newAlertTimeline(2)
+	now := time.Now()
+
+	timeline.record(Event{Type: EventAlertReceived, Timestamp: now.Add(-3 * time.Hour), Fingerprint: "a"})
+	timeline.record(Event{Type: EventAlertReceived, Timestamp: now.Add(-2 * time.Hour), Fingerprint: "b"})
+	timeline.record(Event{Type: EventSilenceCreated, Timestamp: now, SilenceID: "silence"})
+	timeline.record(Event{
+		Type:         EventNotificationSent,
+		Timestamp:    now.Add(-time.Hour),
+		Notification: &NotificationHistoryEntry{Fingerprints: []string{"a", "b"}},
+	})
+	timeline.record(Event{Type: EventAlertStateChanged, Timestamp: now, Fingerprint: "a", State: AlertStateResolved})
+
+	// Only the most recent events are kept.
+	events := timeline.events("a")
+	require.Len(t, events, 2)
+	require.Equal(t, EventNotificationSent, events[0].Type)
+	require.Equal(t, EventAlertStateChanged, events[1].Type)
+	require.Len(t, timeline.events("b"), 2)
+	require.Empty(t, timeline.events(""))
+
+	// Events older than the retention are removed, and so are timelines left empty.
+	require.Equal(t, 1, timeline.gc(now, 90*time.Minute))
+	require.Len(t, timeline.events("a"), 2)
+	require.Len(t, timeline.events("b"), 1)
+	require.Equal(t, 3, timeline.gc(now.Add(time.Hour), 30*time.Minute))
+	require.Empty(t, timeline.alerts)
+}
+
+func TestAlertTimeline_RecordReceived(t *testing.T) {
+	timeline := newAlertTimeline(3)
+	now := time.Now()
+
+	for i := 0; i < 5; i++ {
+		timeline.record(Event{Type: EventAlertReceived, Timestamp: now.Add(time.Duration(i) * time.Minute), Fingerprint: "a"})
+	}
+
+	// Every update of the alert is kept, up to the size of the timeline.
+	events := timeline.events("a")
+	require.Len(t, events, 3)
+	for i, e := range events {
+		require.Equal(t, EventAlertReceived, e.Type)
+		require.Equal(t, now.Add(time.Duration(i+2)*time.Minute), e.Timestamp)
+	}
+}
@@ -63,6 +63,41 @@ type ExtendedData struct {
 	CommonAnnotations KV `json:"commonAnnotations"`
 
 	ExternalURL string `json:"externalURL"`
+
+	// Groups are the alert groups of a digest notification, which covers several alert groups. It is empty otherwise.
+	Groups []*ExtendedData `json:"groups,omitempty"`
+
+	// Acknowledgement is the acknowledgement of the alert group, if it is acknowledged.
+	Acknowledgement *models.Acknowledgement `json:"acknowledgement,omitempty"`
+}
+
+type contextKey int
+
+const (
+	keyDigestGroups contextKey = iota
+	keyAcknowledgement
+)
+
+// WithAcknowledgement populates a context with the acknowledgement of the alert group.
+func WithAcknowledgement(ctx context.Context, ack *models.Acknowledgement) context.Context {
+	return context.WithValue(ctx, keyAcknowledgement, ack)
+}
+
+// Acknowledgement extracts the acknowledgement of the alert group from the context.
+func Acknowledgement(ctx context.Context) (*models.Acknowledgement, bool) {
+	v, ok := ctx.Value(keyAcknowledgement).(*models.Acknowledgement)
+	return v, ok
+}
+
+// WithDigestGroups populates a context with the template data of the alert groups of a digest notification.
+func WithDigestGroups(ctx context.Context, groups []*ExtendedData) context.Context {
+	return context.WithValue(ctx, keyDigestGroups, groups)
+}
+
+// DigestGroups extracts the template data of the alert groups of a digest notification from the context.
+func DigestGroups(ctx context.Context) ([]*ExtendedData, bool) {
+	v, ok := ctx.Value(keyDigestGroups).([]*ExtendedData)
+	return v, ok
 }
 
 // FromContent calls Parse on all provided template content and returns the resulting Template. Content equivalent to templates.FromGlobs.
@@ -232,6 +267,12 @@ func ExtendData(data *Data, logger log.Logger) *ExtendedData {
 func TmplText(ctx context.Context, tmpl *Template, alerts []*types.Alert, l log.Logger, tmplErr *error) (func(string) string, *ExtendedData) {
 	promTmplData := notify.GetTemplateData(ctx, tmpl, alerts, l)
 	data := ExtendData(promTmplData, l)
+	if groups, ok := DigestGroups(ctx); ok {
+		data.Groups = groups
+	}
+	if ack, ok := Acknowledgement(ctx); ok {
+		data.Acknowledgement = ack
+	}
 
 	return func(name string) (s string) {
 		if *tmplErr != nil {